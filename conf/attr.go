@@ -0,0 +1,146 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AttrOverrides holds only the device attributes which differ from model defaults.
+// Keys are the YAML attribute names, as shown in DevAttributes dumps.
+type AttrOverrides map[string]interface{}
+
+// AttrLayer is a named set of overrides applied on top of model defaults.
+type AttrLayer struct {
+	Origin    string // "device", "profile:name", etc
+	Overrides AttrOverrides
+}
+
+// Origins of effective attributes.
+const (
+	OriginModel  = "model"
+	OriginDevice = "device"
+)
+
+// ProfileOrigin names the origin for attributes provided by a profile.
+func ProfileOrigin(name string) string {
+	return "profile:" + name
+}
+
+func attrToMap(a DevAttributes) (map[string]interface{}, error) {
+	b, err := yaml.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func attrFromMap(m map[string]interface{}) (DevAttributes, error) {
+	var a DevAttributes
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return a, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&a); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// MergeAttr computes effective attributes by applying layers of overrides, in order, on top of base attributes.
+// The origins map tells which layer provided each attribute.
+func MergeAttr(base DevAttributes, layers ...AttrLayer) (DevAttributes, map[string]string, error) {
+	m, mapErr := attrToMap(base)
+	if mapErr != nil {
+		return base, nil, fmt.Errorf("MergeAttr: %v", mapErr)
+	}
+
+	origins := map[string]string{}
+	for k := range m {
+		origins[k] = OriginModel
+	}
+
+	for _, l := range layers {
+		for k, v := range l.Overrides {
+			if _, found := m[k]; !found {
+				return base, nil, fmt.Errorf("MergeAttr: %s: unknown attribute '%s'", l.Origin, k)
+			}
+			m[k] = v
+			origins[k] = l.Origin
+		}
+	}
+
+	a, decodeErr := attrFromMap(m)
+	if decodeErr != nil {
+		return base, nil, fmt.Errorf("MergeAttr: %v", decodeErr)
+	}
+
+	return a, origins, nil
+}
+
+// MinimizeOverrides drops overrides which do not change the base attributes.
+func MinimizeOverrides(base DevAttributes, o AttrOverrides) (AttrOverrides, error) {
+	ref, _, refErr := MergeAttr(base) // normalized base
+	if refErr != nil {
+		return o, refErr
+	}
+
+	var minimal AttrOverrides
+
+	for k, v := range o {
+		single := AttrOverrides{k: v}
+		a, _, err := MergeAttr(base, AttrLayer{Origin: OriginDevice, Overrides: single})
+		if err != nil {
+			return o, err
+		}
+		if reflect.DeepEqual(a, ref) {
+			continue // redundant
+		}
+		if minimal == nil {
+			minimal = AttrOverrides{}
+		}
+		minimal[k] = v
+	}
+
+	return minimal, nil
+}
+
+// DumpAttrWithOrigin exports effective attributes as YAML, marking each attribute with its origin.
+func DumpAttrWithOrigin(a DevAttributes, origins map[string]string) ([]byte, error) {
+	m, mapErr := attrToMap(a)
+	if mapErr != nil {
+		return nil, mapErr
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		b, err := yaml.Marshal(map[string]interface{}{k: m[k]})
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.SplitN(strings.TrimRight(string(b), "\n"), "\n", 2)
+		fmt.Fprintf(&buf, "%s # %s\n", lines[0], origins[k])
+		if len(lines) > 1 {
+			buf.WriteString(lines[1])
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -2,6 +2,11 @@
 package conf
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -21,8 +26,19 @@ type AppConfig struct {
 	MaxConfigFiles    int
 	Holdtime          time.Duration
 	ScanInterval      time.Duration
+	Schedule          string // default cron schedule, instead of holdtime: 0 2 * * *
+	ScheduleTimezone  string // timezone for cron schedules: America/Sao_Paulo - empty means local time
 	MaxConcurrency    int
+	FailureBackoff    time.Duration  // wait after a failed backup, doubled for every consecutive failure - zero disables backoff
+	FailureBackoffMax time.Duration  // limit for failure backoff - zero means no limit
+	QuarantineAfter   int            // consecutive failures moving device into quarantine, until manual release - zero disables quarantine
+	PoolConcurrency   map[string]int // per-pool limits for concurrent device sessions: console1: 5
+	DefaultPoolLimit  int            // limit for pools missing from PoolConcurrency - zero means unlimited
 	MaxConfigLoadSize int64
+	SecretCacheTTL    time.Duration // how long resolved secret references are cached
+	RestoreURL        string        // base URL for devices to reach -restoreStagePath on restore: scp://user@host/var/jazigo/restore
+	SecretMaskKey     string        // key for secret mask tokens, required for masking, might be a secret reference: env:JAZIGO_MASK_KEY
+	UnmaskedPath      string        // keep unmasked copies of masked configs under this restricted location
 	LastChange        Change
 	Comment           string // free user-defined field
 }
@@ -69,11 +85,16 @@ type DevAttributes struct {
 	CommandList                  []string      // "show version", "show run"
 	DisablePagerCommand          string        // term len 0
 	DisablePagerExtraPromptCount int           // consume N extra prompts
+	DisablePagerCommandList      []string      // pager commands tried in order until one is accepted, instead of DisablePagerCommand: screen-length disable, screen-length 0 temporary
+	DisablePagerErrorPattern     string        // output from rejected pager command: % Unrecognized command
 	SupressAutoLF                bool          // do not send auto LF
 	QuoteSentCommandsFormat      string        // !![%s] - empty means omitting
 	KeepControlChars             bool          // enable if you want to capture control chars (backspace, etc)
-	LineFilter                   string        // line filter name - applied to every saved line
+	MultiLinePrompt              bool          // match prompts against output tail instead of single lines, then patterns may span lines: \[/\]\n\S+#\s*$
+	LineFilter                   string        // line filter chain - comma-separated filter names applied in order to every saved line
+	SecretMask                   string        // secret mask name - replaces secrets in every saved line with hash tokens - empty means disabled
 	ChangesOnly                  bool          // save new file only if it differs from previous one
+	IgnoreForComparison          []string      // line patterns ignored by ChangesOnly when comparing with previous file - still saved
 	S3ContentType                string        // ""=none "detect"=http.Detect "text/plain" etc
 	RunProg                      []string      // "/path/to/external/command", "arg1", "arg2" for the run model
 	RunTimeout                   time.Duration // 60s - time allowed for external program to complete
@@ -81,6 +102,27 @@ type DevAttributes struct {
 	PostLoginPromptPattern       string        // mikrotik: Please press "Enter" to continue!
 	PostLoginPromptResponse      string        // mikrotik: \r\n
 	UsernameAppend               string        // mikrotik: +cte
+	RestoreCommands              []string      // restore recipe: "configure replace {url}" - "{config}" pastes saved config line by line
+	RestoreConfigCommand         string        // command whose saved output is pasted by "{config}" or staged for "{url}": show run
+	RestorePromptPattern         string        // extra prompt found while restoring: configuration mode
+	ContextListCommand           string        // multi-context: command listing security contexts: show context
+	ContextListPattern           string        // multi-context: pattern capturing context name from context list: ^[ *](\S+)\s
+	ContextChangeCommand         string        // multi-context: command entering context, %s is context name: changeto context %s
+	ContextExitCommand           string        // multi-context: command returning to system: changeto system
+	ContextCommandList           []string      // multi-context: commands saved for every context, apart from system output
+	APIInsecureTLS               bool          // device API: skip verification of device TLS certificate
+	ExportDeviceState            bool          // panos: also export device state bundle as binary artifact
+	ExportUCS                    bool          // f5-bigip: also download UCS archive as binary artifact
+	APIHostPort                  string        // device API address, when distinct from HostPort: host:8443
+	MaxArtifactFiles             int           // retention for binary artifacts, since they might be large. 0 means MaxConfigFiles
+	MaxArtifactSize              int64         // size limit for a binary artifact, buffered in memory while downloaded. 0 means no limit
+	APIFormat                    string        // arista-eos: eAPI output format: text or json
+	APIPaths                     []string      // gnmi: paths fetched through device API: /interfaces - restconf: top-level modules: ietf-interfaces:interfaces
+	APIStrict                    bool          // restconf: fail fetch when any module fails, instead of reporting warning
+	Schedule                     string        // cron schedule, instead of global schedule or holdtime: */30 8-17 * * 1-5
+	ScheduleTimezone             string        // timezone for cron schedule: America/Sao_Paulo - empty means global schedule timezone
+	SeparateCommands             []string      // commands whose output is saved apart from main output: show configuration
+	ConcurrencyPool              string        // concurrency pool shared with other devices: console1 - "{host}" is replaced by resolved HostPort address
 
 	// readTimeout: per-read timeout (protection against inactivity)
 	// matchTimeout: full match timeout (protection against slow sender -- think 1 byte per second)
@@ -93,20 +135,23 @@ type DevAttributes struct {
 
 // DevConfig is full set of device properties.
 type DevConfig struct {
-	Debug           bool
-	Deleted         bool
-	Model           string
-	ID              string
-	HostPort        string
-	Transports      string
-	LoginUser       string
-	LoginPassword   string
-	EnablePassword  string
-	SSHClearCiphers bool
-	SSHAddCiphers   []string
-	Comment         string // free user-defined field
-	LastChange      Change
-	Attr            DevAttributes
+	Debug            bool
+	Deleted          bool
+	Model            string
+	ID               string
+	HostPort         string
+	Transports       string
+	LoginUser        string
+	LoginPassword    string
+	EnablePassword   string
+	SSHKeyFile       string // optional private key for SSH public key authentication
+	SSHKeyPassphrase string // passphrase for encrypted SSHKeyFile
+	SSHClearCiphers  bool
+	SSHAddCiphers    []string
+	Profiles         []string // profiles applied in order between model defaults and device overrides
+	Comment          string   // free user-defined field
+	LastChange       Change
+	Attr             AttrOverrides // only attributes overriding model defaults
 }
 
 // NewDeviceFromString creates device configuration from string.
@@ -128,10 +173,145 @@ func (c *DevConfig) Dump() ([]byte, error) {
 	return b, nil
 }
 
+// ModelConfig is a user-defined device model loaded at runtime.
+type ModelConfig struct {
+	Name         string
+	Fingerprints []string          // patterns identifying the model for autodetection
+	Facts        map[string]string // fact name (hostname, version, serial, platform, uptime) => pattern capturing fact value
+	Attr         DevAttributes
+}
+
+// UnmarshalYAML fills unspecified model attributes with NewDevAttr defaults.
+func (m *ModelConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain ModelConfig // avoid recursion
+	p := plain{Attr: NewDevAttr()}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*m = ModelConfig(p)
+	return nil
+}
+
+// ModelFile is the format for files holding user-defined models.
+type ModelFile struct {
+	Models []ModelConfig
+}
+
+// LoadModelDir loads user-defined models from all YAML files (*.yaml, *.yml) under a directory.
+// A missing directory is not an error.
+func LoadModelDir(dir string, maxSize int64) ([]ModelConfig, error) {
+	paths, dirErr := listYAMLDir(dir)
+	if dirErr != nil {
+		return nil, fmt.Errorf("LoadModelDir: %v", dirErr)
+	}
+
+	var models []ModelConfig
+	for _, path := range paths {
+		b, readErr := store.FileRead(path, maxSize)
+		if readErr != nil {
+			return nil, fmt.Errorf("LoadModelDir: '%s': %v", path, readErr)
+		}
+		var f ModelFile
+		if err := yaml.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("LoadModelDir: '%s': %v", path, err)
+		}
+		models = append(models, f.Models...)
+	}
+
+	return models, nil
+}
+
+// listYAMLDir finds YAML files (*.yaml, *.yml) under a directory, sorted by name.
+// A missing directory yields an empty list.
+func listYAMLDir(dir string) ([]string, error) {
+	entries, dirErr := os.ReadDir(dir)
+	if dirErr != nil {
+		if os.IsNotExist(dirErr) {
+			return nil, nil
+		}
+		return nil, dirErr
+	}
+
+	var names []string
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !(strings.HasSuffix(n, ".yaml") || strings.HasSuffix(n, ".yml")) {
+			continue
+		}
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	paths := make([]string, len(names))
+	for i, n := range names {
+		paths[i] = filepath.Join(dir, n)
+	}
+
+	return paths, nil
+}
+
+// Profile is a named set of attribute and credential overrides shared by many devices.
+// Empty credential fields are not applied.
+type Profile struct {
+	Name             string
+	LoginUser        string
+	LoginPassword    string
+	EnablePassword   string
+	SSHKeyFile       string
+	SSHKeyPassphrase string
+	Comment          string // free user-defined field
+	Attr             AttrOverrides
+}
+
+// ProfileList is the editable form of the profile table.
+type ProfileList struct {
+	Profiles []Profile
+}
+
+// NewProfileListFromString creates ProfileList from string.
+func NewProfileListFromString(str string) (*ProfileList, error) {
+	b := []byte(str)
+	c := &ProfileList{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Dump exports ProfileList as YAML.
+func (p *ProfileList) Dump() ([]byte, error) {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ValidateProfiles checks profiles for missing or duplicate names and invalid attributes.
+func ValidateProfiles(profiles []Profile) error {
+	seen := map[string]bool{}
+	for i, p := range profiles {
+		if p.Name == "" {
+			return fmt.Errorf("profile #%d: missing name", i)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("profile '%s': duplicate name", p.Name)
+		}
+		seen[p.Name] = true
+		if _, _, err := MergeAttr(NewDevAttr(), AttrLayer{Origin: ProfileOrigin(p.Name), Overrides: p.Attr}); err != nil {
+			return fmt.Errorf("profile '%s': %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
 // Config is full (global+devices) app configuration.
 type Config struct {
-	Options AppConfig
-	Devices []DevConfig
+	Options  AppConfig
+	Models   []ModelConfig  // user-defined models
+	Filters  []FilterConfig // user-defined line filters
+	Profiles []Profile
+	Devices  []DevConfig
 }
 
 // New creates new full app configuration.
@@ -141,8 +321,12 @@ func New() *Config {
 			Holdtime:          12 * time.Hour,   // do not retry a successful device backup before this holdtime
 			ScanInterval:      10 * time.Minute, // interval for scanning device table
 			MaxConcurrency:    20,               // limit for concurrent backup jobs
+			FailureBackoff:    10 * time.Minute, // retry a failed device backup after this period, doubled for every consecutive failure
+			FailureBackoffMax: 24 * time.Hour,   // limit for failure backoff
+			QuarantineAfter:   30,               // stop trying a device after this many consecutive failures
 			MaxConfigFiles:    120,              // limit for per-device saved files
 			MaxConfigLoadSize: 10000000,         // 10M limit max config file size for loading to memory
+			SecretCacheTTL:    5 * time.Minute,  // resolved secret references are reused for this period
 		},
 		Devices: []DevConfig{},
 	}
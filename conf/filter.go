@@ -0,0 +1,65 @@
+package conf
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/udhos/jazigo/store"
+)
+
+// Actions for user-defined line filter rules.
+const (
+	FilterDrop    = "drop"    // remove line
+	FilterReplace = "replace" // substitute regex matches, then try next rules
+	FilterKeep    = "keep"    // keep line as is, skipping remaining rules
+
+	FilterDropBlock     = "drop-block"     // remove whole block
+	FilterCollapseBlock = "collapse-block" // keep block header (and end line), replace block body with a single marker line
+)
+
+// FilterConfig is a user-defined line filter: an ordered list of rules tried on every saved line.
+// Device attribute LineFilter refers to filters by name.
+type FilterConfig struct {
+	Name  string
+	Rules []FilterRule
+}
+
+// FilterRule applies an action to lines matching a regular expression.
+// For block actions, Pattern matches the block header. The block extends up to the line matching End,
+// or, if End is empty, over the following lines indented deeper than the header.
+type FilterRule struct {
+	Action  string // drop, replace, keep, drop-block, collapse-block
+	Pattern string
+	Replace string // replacement for action replace, might refer to submatches: $1
+	End     string // block end pattern for block actions
+}
+
+// FilterFile is the format for files holding user-defined line filters.
+type FilterFile struct {
+	Filters []FilterConfig
+}
+
+// LoadFilterDir loads user-defined line filters from all YAML files (*.yaml, *.yml) under a directory.
+// A missing directory is not an error.
+func LoadFilterDir(dir string, maxSize int64) ([]FilterConfig, error) {
+	paths, dirErr := listYAMLDir(dir)
+	if dirErr != nil {
+		return nil, fmt.Errorf("LoadFilterDir: %v", dirErr)
+	}
+
+	var filters []FilterConfig
+	for _, path := range paths {
+		b, readErr := store.FileRead(path, maxSize)
+		if readErr != nil {
+			return nil, fmt.Errorf("LoadFilterDir: '%s': %v", path, readErr)
+		}
+		var f FilterFile
+		if err := yaml.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("LoadFilterDir: '%s': %v", path, err)
+		}
+		filters = append(filters, f.Filters...)
+	}
+
+	return filters, nil
+}
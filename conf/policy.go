@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/udhos/jazigo/store"
+)
+
+// Policy is a named set of compliance rules checked against the latest configuration of selected devices.
+// Empty selectors match any device.
+type Policy struct {
+	Name      string
+	Models    []string // select devices by model name
+	Profiles  []string // select devices using any of these profiles
+	IDPattern string   // select devices by regular expression on device ID
+	Comment   string   // free user-defined field
+	Rules     []Rule
+}
+
+// Rule is a compliance assertion over configuration lines.
+// All patterns are regular expressions matched against single lines.
+type Rule struct {
+	Name         string
+	Block        string   // block header: rule is checked within every block (header plus following indented lines)
+	MustMatch    []string // every pattern must match some line
+	MustNotMatch []string // no line may match any pattern...
+	Except       []string // ...unless the line also matches one of these
+}
+
+// PolicyFile is the format for files holding compliance policies.
+type PolicyFile struct {
+	Policies []Policy
+}
+
+// LoadPolicyDir loads compliance policies from all YAML files (*.yaml, *.yml) under a directory.
+// A missing directory is not an error.
+func LoadPolicyDir(dir string, maxSize int64) ([]Policy, error) {
+	paths, dirErr := listYAMLDir(dir)
+	if dirErr != nil {
+		return nil, fmt.Errorf("LoadPolicyDir: %v", dirErr)
+	}
+
+	var policies []Policy
+	for _, path := range paths {
+		b, readErr := store.FileRead(path, maxSize)
+		if readErr != nil {
+			return nil, fmt.Errorf("LoadPolicyDir: '%s': %v", path, readErr)
+		}
+		var f PolicyFile
+		if err := yaml.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("LoadPolicyDir: '%s': %v", path, err)
+		}
+		policies = append(policies, f.Policies...)
+	}
+
+	return policies, nil
+}
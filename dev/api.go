@@ -0,0 +1,158 @@
+package dev
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+)
+
+// apiFetchFunc retrieves device output through a device API, instead of CLI chat.
+// It fills the capture, then returns the transport used and the fetch error code.
+// An error along with fetchErrNone is a warning: output is saved, and the error is reported in the fetch result.
+type apiFetchFunc func(d *Device, logger hasPrintf, capture *dialog, opt *conf.AppConfig) (string, int, error)
+
+// artifactFetchFunc retrieves binary artifacts into the capture, after output was fetched through CLI chat.
+type artifactFetchFunc func(d *Device, logger hasPrintf, capture *dialog) error
+
+// artifact is a binary file fetched along with device output, like a backup archive.
+// Artifacts are saved apart, numbered after the configuration file fetched along with them.
+type artifact struct {
+	name        string
+	data        []byte
+	contentType string
+}
+
+// DeviceArtifactPrefix gets the path prefix for binary artifacts fetched along with device configurations.
+func DeviceArtifactPrefix(repository, id, name string) string {
+	return filepath.Join(deviceDirectory(repository, id), "artifacts", name, id+".")
+}
+
+// fetchAPI fetches device output through the model API fetcher, then saves it.
+func (d *Device) fetchAPI(logger hasPrintf, begin time.Time, repository string, opt *conf.AppConfig, ft *FilterTable) FetchResult {
+	modelName := d.devModel.name
+
+	// d is a private copy: profile credentials and resolved secrets do not leak into device table
+	d.applyProfileCredentials()
+
+	if secretErr := d.resolveSecrets(opt.SecretCacheTTL); secretErr != nil {
+		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Msg: fmt.Sprintf("fetch secret: %v", secretErr), Code: fetchErrSecret, Begin: begin}
+	}
+
+	capture := dialog{}
+
+	transport, code, fetchErr := d.devModel.apiFetch(d, logger, &capture, opt)
+	if fetchErr != nil && code != fetchErrNone {
+		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fetchErr.Error(), Code: code, Begin: begin}
+	}
+
+	var warning string
+	if fetchErr != nil {
+		warning = fetchErr.Error()
+	}
+
+	d.debugf("will save results")
+
+	if saveErr := d.saveCommit(logger, &capture, repository, opt, ft); saveErr != nil {
+		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("save commit: %v", saveErr), Code: fetchErrSave, Begin: begin}
+	}
+
+	return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: warning, Code: fetchErrNone, Begin: begin}
+}
+
+// apiReadBody reads an API response body up to maxSize bytes.
+// Larger bodies are refused, as store.FileRead does for files.
+func apiReadBody(body io.Reader, maxSize int64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > maxSize {
+		return nil, fmt.Errorf("response body exceeds max=%d", maxSize)
+	}
+	return buf, nil
+}
+
+// apiBaseURL parses a device API address as an URL.
+// Addresses missing the scheme default to https.
+func apiBaseURL(address string) (*url.URL, error) {
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("bad API address '%s': %v", address, err)
+	}
+	return u, nil
+}
+
+// apiAddress gets the device API address for models fetching through CLI chat:
+// APIHostPort, or the host from HostPort.
+func (d *Device) apiAddress() string {
+	if d.attr.APIHostPort != "" {
+		return d.attr.APIHostPort
+	}
+	if host, _, err := net.SplitHostPort(d.HostPort); err == nil {
+		return host
+	}
+	return d.HostPort
+}
+
+// apiClient creates an HTTP client for device API requests.
+// The whole request is limited by the command match timeout.
+func (d *Device) apiClient() *http.Client {
+	return &http.Client{
+		Timeout: d.attr.CommandMatchTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: d.attr.APIInsecureTLS},
+		},
+	}
+}
+
+// saveArtifacts saves binary artifacts, numbered after the configuration saved as path.
+// MaxArtifactFiles, when set, keeps fewer artifacts than configurations, since artifacts might be large.
+func (d *Device) saveArtifacts(logger hasPrintf, capture *dialog, path, repository string, maxFiles int) error {
+
+	if len(capture.artifacts) < 1 {
+		return nil
+	}
+
+	if d.attr.MaxArtifactFiles > 0 {
+		maxFiles = d.attr.MaxArtifactFiles
+	}
+
+	id, idErr := store.ExtractCommitIDFromFilename(path)
+	if idErr != nil {
+		return fmt.Errorf("saveArtifacts: %v", idErr)
+	}
+
+	for _, a := range capture.artifacts {
+		data := a.data
+		writeFunc := func(w store.HasWrite) error {
+			n, err := w.Write(data)
+			if err != nil {
+				return err
+			}
+			if n != len(data) {
+				return fmt.Errorf("partial: wrote=%d size=%d", n, len(data))
+			}
+			return nil
+		}
+		artPath, saveErr := store.SaveConfigCopy(DeviceArtifactPrefix(repository, d.ID, a.name), id, maxFiles, logger, writeFunc, a.contentType)
+		if saveErr != nil {
+			return fmt.Errorf("saveArtifacts: artifact '%s': %v", a.name, saveErr)
+		}
+		logger.Printf("saveArtifacts: dev '%s' artifact '%s' saved to '%s'", d.ID, a.name, artPath)
+	}
+
+	return nil
+}
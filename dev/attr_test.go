@@ -0,0 +1,105 @@
+package dev
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAttrInheritModel(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "cisco-ios", ID: "lab1", Attr: conf.AttrOverrides{"commandmatchtimeout": "120s"}}
+	d, migrated, err := NewDeviceFromConf(tab, logger, &cfg)
+	if err != nil {
+		t.Fatalf("new device: %v", err)
+	}
+	if migrated {
+		t.Errorf("unexpected migration")
+	}
+
+	a := d.Attributes()
+	if a.CommandMatchTimeout != 120*time.Second {
+		t.Errorf("override: wanted=120s got=%v", a.CommandMatchTimeout)
+	}
+	if a.EnabledPromptPattern != `\S+#\s*$` {
+		t.Errorf("inherited: got=%q", a.EnabledPromptPattern)
+	}
+	origin := d.AttributesOrigin()
+	if origin["commandmatchtimeout"] != conf.OriginDevice || origin["enabledpromptpattern"] != conf.OriginModel {
+		t.Errorf("unexpected origins: %v", origin)
+	}
+
+	// improve model defaults: device must pick up new default at fetch time
+	mod, _ := tab.GetModel("cisco-ios")
+	mod.defaultAttr.EnabledPromptPattern = `\S+#$`
+	d.devModel = mod
+	if err := d.refreshAttr(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if d.Attributes().EnabledPromptPattern != `\S+#$` {
+		t.Errorf("new model default not inherited: %q", d.Attributes().EnabledPromptPattern)
+	}
+	if d.Attributes().CommandMatchTimeout != 120*time.Second {
+		t.Errorf("override lost: %v", d.Attributes().CommandMatchTimeout)
+	}
+}
+
+func TestAttrMigration(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	mod, _ := tab.GetModel("junos")
+
+	// legacy config: full copy of model attributes, with one customized value
+	full := mod.defaultAttr
+	full.CommandReadTimeout = 90 * time.Second
+	b, _ := yaml.Marshal(full)
+	var legacy conf.AttrOverrides
+	if err := yaml.Unmarshal(b, &legacy); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	cfg := conf.DevConfig{Model: "junos", ID: "lab1", Attr: legacy}
+	d, migrated, err := NewDeviceFromConf(tab, logger, &cfg)
+	if err != nil {
+		t.Fatalf("new device: %v", err)
+	}
+	if !migrated {
+		t.Errorf("expected migration")
+	}
+	if len(d.Attr) != 1 {
+		t.Errorf("expected single override, got: %v", d.Attr)
+	}
+	if d.Attributes().CommandReadTimeout != 90*time.Second {
+		t.Errorf("customized value lost: %v", d.Attributes().CommandReadTimeout)
+	}
+}
+
+func TestAttrInvalid(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "cisco-ios", ID: "lab1", Attr: conf.AttrOverrides{"nosuchattribute": 1}}
+	if _, _, err := NewDeviceFromConf(tab, logger, &cfg); err == nil || !strings.Contains(err.Error(), "nosuchattribute") {
+		t.Errorf("unknown attribute: expected error, got: %v", err)
+	}
+
+	CreateDevice(tab, logger, "cisco-ios", "lab2", "localhost", "ssh", "lab", "pass", "", false, nil)
+	d, _ := tab.GetDevice("lab2")
+	bad := d.DevConfig
+	bad.Attr = conf.AttrOverrides{"readtimeout": "not-a-duration"}
+	if err := d.SetDevConfig(tab, &bad); err == nil {
+		t.Errorf("bad value: expected error")
+	}
+	if d.Attr != nil {
+		t.Errorf("failed SetDevConfig must not change device: %v", d.Attr)
+	}
+}
@@ -0,0 +1,190 @@
+package dev
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+// Status for ad-hoc command results.
+const (
+	CommandRunning = "running"
+	CommandOK      = "ok"
+	CommandFailed  = "failed"
+)
+
+// CommandResult holds the output of ad-hoc commands for a device.
+// Ad-hoc command outputs are never saved into the repository.
+type CommandResult struct {
+	DevID     string
+	Model     string
+	HostPort  string
+	Transport string
+	Status    string // running, ok, failed
+	Msg       string // error message
+	Output    string
+	Begin     time.Time
+	End       time.Time
+}
+
+// FilterDevices selects non-deleted devices whose model, id and host:port contain the given substrings.
+func FilterDevices(devices []*Device, model, id, host string) []*Device {
+	var list []*Device
+	for _, d := range devices {
+		if d.Deleted {
+			continue
+		}
+		if !strings.Contains(d.DevConfig.Model, model) || !strings.Contains(d.ID, id) || !strings.Contains(d.HostPort, host) {
+			continue
+		}
+		list = append(list, d)
+	}
+	return list
+}
+
+// RunCommands sends ad-hoc commands to a list of devices, keeping at most opt.MaxConcurrency devices
+// busy at a time. Device sessions count against the same global and pool limits as backups.
+// The progress function, if provided, is called for every status change of every device.
+// Results are sorted by device ID.
+func RunCommands(tab DeviceUpdater, logger hasPrintf, devices []*Device, commands []string, opt *conf.AppConfig, progress func(CommandResult)) []CommandResult {
+
+	if progress == nil {
+		progress = func(CommandResult) {}
+	}
+
+	resultCh := make(chan CommandResult)
+	maxConcurrency := opt.MaxConcurrency // alias
+	wait := 0
+	nextDevice := 0
+	var results []CommandResult
+
+	for nextDevice < len(devices) || wait > 0 {
+		// launch requests
+		for ; nextDevice < len(devices); nextDevice++ {
+			if maxConcurrency > 0 && wait >= maxConcurrency {
+				break // max concurrent limit reached
+			}
+			d := devices[nextDevice]
+			progress(CommandResult{DevID: d.ID, Model: d.DevConfig.Model, HostPort: d.HostPort, Status: CommandRunning, Begin: time.Now()})
+			go func(d *Device) {
+				pool := d.concurrencyPool()
+				tab.AcquirePool(pool, PoolLimit(opt, pool), true)
+				tab.AcquireSession(opt.MaxConcurrency) // shared with backups
+				r := d.runCommands(tab, logger, commands, opt.SecretCacheTTL)
+				tab.ReleaseSession()
+				tab.ReleasePool(pool)
+				resultCh <- r
+			}(d)
+			wait++
+		}
+
+		// wait results
+		if wait > 0 {
+			r := <-resultCh
+			wait--
+			progress(r)
+			results = append(results, r)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].DevID < results[j].DevID })
+
+	return results
+}
+
+// runCommands sends ad-hoc commands to a device, returning the outputs.
+func (d *Device) runCommands(tab DeviceUpdater, logger hasPrintf, commands []string, secretTTL time.Duration) CommandResult {
+
+	result := CommandResult{DevID: d.ID, Model: d.DevConfig.Model, HostPort: d.HostPort, Transport: d.Transports, Begin: time.Now()}
+
+	fail := func(format string, v ...interface{}) CommandResult {
+		result.Status = CommandFailed
+		result.Msg = fmt.Sprintf(format, v...)
+		result.End = time.Now()
+		return result
+	}
+
+	if attrErr := d.refresh(tab); attrErr != nil {
+		return fail("attributes: %v", attrErr)
+	}
+
+	capture := dialog{}
+
+	session, transport, _, _, err := d.connect(logger, &capture, secretTTL)
+	result.Transport = transport
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	defer session.Close()
+
+	if d.attr.NeedPagingOff {
+		if pagingErr := d.pagingOff(logger, session, &capture); pagingErr != nil {
+			return fail("pager off: %v", pagingErr)
+		}
+	}
+
+	d.attr.CommandList = commands
+
+	cmdErr := d.sendCommands(logger, session, &capture)
+
+	var output []byte
+	for _, b := range capture.save {
+		output = append(output, b...)
+	}
+	result.Output = string(output) // keep partial output on error
+
+	if cmdErr != nil {
+		return fail("commands: %v", cmdErr)
+	}
+
+	result.Status = CommandOK
+	result.End = time.Now()
+	return result
+}
+
+// ExportCommandResultsJSON writes ad-hoc command results as JSON.
+func ExportCommandResultsJSON(w io.Writer, results []CommandResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		return fmt.Errorf("ExportCommandResultsJSON: %v", err)
+	}
+	return nil
+}
+
+// ExportCommandResultsZip writes ad-hoc command results as a zip archive
+// holding one text file per device plus the full results as JSON.
+func ExportCommandResultsZip(w io.Writer, results []CommandResult) error {
+	z := zip.NewWriter(w)
+
+	for _, r := range results {
+		f, createErr := z.Create(r.DevID + ".txt")
+		if createErr != nil {
+			return fmt.Errorf("ExportCommandResultsZip: %v", createErr)
+		}
+		if _, writeErr := io.WriteString(f, r.Output); writeErr != nil {
+			return fmt.Errorf("ExportCommandResultsZip: %v", writeErr)
+		}
+	}
+
+	f, createErr := z.Create("results.json")
+	if createErr != nil {
+		return fmt.Errorf("ExportCommandResultsZip: %v", createErr)
+	}
+	if err := ExportCommandResultsJSON(f, results); err != nil {
+		return err
+	}
+
+	if closeErr := z.Close(); closeErr != nil {
+		return fmt.Errorf("ExportCommandResultsZip: %v", closeErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package dev
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func TestRunCommands(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2017"
+	s, listenErr := spawnServerFortiOS(t, addr, optionsFortiOS{requestPassword: true})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus FortiOS server: %v", listenErr)
+	}
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "fortios", "lab1", "localhost"+addr, "telnet", "lab", "pass", "", false, nil)
+	CreateDevice(tab, logger, "fortios", "lab2", "localhost"+addr, "telnet", "lab", "pass", "", false, nil)
+	CreateDevice(tab, logger, "cisco-ios", "lab3", "localhost:2099", "telnet", "lab", "pass", "", false, nil)
+
+	devices := FilterDevices(tab.ListDevices(), "fortios", "lab", "")
+	if len(devices) != 2 {
+		t.Fatalf("filter: wanted=2 got=%d", len(devices))
+	}
+
+	var running, done int
+	progress := func(r CommandResult) {
+		switch r.Status {
+		case CommandRunning:
+			running++
+		default:
+			done++
+		}
+	}
+
+	opt := &conf.AppConfig{MaxConcurrency: 1}
+	results := RunCommands(tab, logger, devices, []string{"show"}, opt, progress)
+
+	if running != 2 || done != 2 {
+		t.Errorf("progress: running=%d done=%d", running, done)
+	}
+	if len(results) != 2 || results[0].DevID != "lab1" || results[1].DevID != "lab2" {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	for _, r := range results {
+		if r.Status != CommandOK || !strings.Contains(r.Output, "full FortiOS config") {
+			t.Errorf("device %s: status=%s msg=%s output=%q", r.DevID, r.Status, r.Msg, r.Output)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportCommandResultsZip(&buf, results); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	z, zipErr := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if zipErr != nil {
+		t.Fatalf("zip read: %v", zipErr)
+	}
+	if len(z.File) != 3 {
+		t.Errorf("zip: wanted=3 files got=%d", len(z.File))
+	}
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+}
+
+func TestRunCommandsSessionLimit(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost:2033", "telnet", "lab", "pass", "en", false, nil) // nothing listening
+
+	opt := &conf.AppConfig{MaxConcurrency: 1}
+
+	tab.AcquireSession(opt.MaxConcurrency) // busy with a backup
+
+	done := make(chan []CommandResult)
+	go func() {
+		done <- RunCommands(tab, logger, tab.ListDevices(), []string{"show clock"}, opt, nil)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("ad-hoc command exceeded session limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tab.ReleaseSession()
+
+	select {
+	case results := <-done:
+		if len(results) != 1 || results[0].Status != CommandFailed {
+			t.Errorf("unexpected results: %v", results)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("ad-hoc command not released")
+	}
+}
@@ -0,0 +1,477 @@
+package dev
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+)
+
+// maxRuleFailures limits failures reported per rule.
+const maxRuleFailures = 5
+
+// policy is a compliance policy with compiled patterns.
+type policy struct {
+	conf.Policy
+	idPattern *regexp.Regexp
+	rules     []rule
+}
+
+type rule struct {
+	name         string
+	block        *regexp.Regexp
+	mustMatch    []*regexp.Regexp
+	mustNotMatch []*regexp.Regexp
+	except       []*regexp.Regexp
+}
+
+// RuleResult is the outcome of a compliance rule for a device.
+type RuleResult struct {
+	Policy string
+	Rule   string
+	Pass   bool
+	Msg    string // failure details
+}
+
+// ComplianceReport holds the compliance results for the latest configuration of a device.
+type ComplianceReport struct {
+	DevID   string
+	Model   string
+	File    string // configuration file evaluated
+	When    time.Time
+	Pass    bool // all rules passed
+	Results []RuleResult
+}
+
+// Failed counts failed rules.
+func (r *ComplianceReport) Failed() int {
+	var failed int
+	for _, rr := range r.Results {
+		if !rr.Pass {
+			failed++
+		}
+	}
+	return failed
+}
+
+// markInconclusive fails every rule evaluated against hash tokens instead of secret values,
+// since a forbidden secret would go unnoticed.
+func (r *ComplianceReport) markInconclusive(reason string) {
+	r.Pass = false
+	for i, rr := range r.Results {
+		msg := "inconclusive: secrets masked: " + reason
+		if rr.Msg != "" {
+			msg += ": " + rr.Msg
+		}
+		r.Results[i].Pass = false
+		r.Results[i].Msg = msg
+	}
+}
+
+func compilePatterns(list []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(list))
+	for _, p := range list {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad pattern '%s': %v", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// compilePolicies validates compliance policies and compiles their patterns.
+func compilePolicies(policies []conf.Policy) ([]*policy, error) {
+	var list []*policy
+	names := map[string]bool{}
+
+	for _, p := range policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policy with empty name")
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("policy '%s': duplicate name", p.Name)
+		}
+		names[p.Name] = true
+
+		pol := &policy{Policy: p}
+
+		if p.IDPattern != "" {
+			re, err := regexp.Compile(p.IDPattern)
+			if err != nil {
+				return nil, fmt.Errorf("policy '%s': bad id pattern '%s': %v", p.Name, p.IDPattern, err)
+			}
+			pol.idPattern = re
+		}
+
+		for i, r := range p.Rules {
+			name := r.Name
+			if name == "" {
+				name = "rule" + strconv.Itoa(i)
+			}
+			if len(r.MustMatch) < 1 && len(r.MustNotMatch) < 1 {
+				return nil, fmt.Errorf("policy '%s': rule '%s': missing both mustmatch and mustnotmatch", p.Name, name)
+			}
+			ru := rule{name: name}
+			if r.Block != "" {
+				re, err := regexp.Compile(r.Block)
+				if err != nil {
+					return nil, fmt.Errorf("policy '%s': rule '%s': bad block pattern '%s': %v", p.Name, name, r.Block, err)
+				}
+				ru.block = re
+			}
+			var err error
+			if ru.mustMatch, err = compilePatterns(r.MustMatch); err != nil {
+				return nil, fmt.Errorf("policy '%s': rule '%s': mustmatch: %v", p.Name, name, err)
+			}
+			if ru.mustNotMatch, err = compilePatterns(r.MustNotMatch); err != nil {
+				return nil, fmt.Errorf("policy '%s': rule '%s': mustnotmatch: %v", p.Name, name, err)
+			}
+			if ru.except, err = compilePatterns(r.Except); err != nil {
+				return nil, fmt.Errorf("policy '%s': rule '%s': except: %v", p.Name, name, err)
+			}
+			pol.rules = append(pol.rules, ru)
+		}
+
+		list = append(list, pol)
+	}
+
+	return list, nil
+}
+
+// selects checks whether the policy applies to a device.
+// Every non-empty selector must match.
+func (p *policy) selects(d *Device) bool {
+	if len(p.Models) > 0 {
+		var found bool
+		for _, m := range p.Models {
+			if m == d.DevConfig.Model {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(p.Profiles) > 0 {
+		var found bool
+		for _, name := range p.Profiles {
+			if d.HasProfile(name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if p.idPattern != nil && !p.idPattern.MatchString(d.ID) {
+		return false
+	}
+	return true
+}
+
+// configBlock is a block header plus its indented lines.
+type configBlock struct {
+	first int // line number of first line, starting from 1
+	lines []string
+}
+
+// findBlocks splits configuration lines into blocks starting at lines matching the header pattern.
+// A block holds the header plus all following indented lines.
+func findBlocks(lines []string, header *regexp.Regexp) []configBlock {
+	var blocks []configBlock
+	for i := 0; i < len(lines); i++ {
+		if !header.MatchString(lines[i]) {
+			continue
+		}
+		b := configBlock{first: i + 1, lines: []string{lines[i]}}
+		for i+1 < len(lines) && (strings.HasPrefix(lines[i+1], " ") || strings.HasPrefix(lines[i+1], "\t")) {
+			i++
+			b.lines = append(b.lines, lines[i])
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+func matchAny(list []*regexp.Regexp, line string) bool {
+	for _, re := range list {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLines evaluates rule patterns against a scope of lines, appending failures.
+func (r *rule) checkLines(b configBlock, scope string, showLines bool, failures []string) []string {
+	for _, re := range r.mustMatch {
+		var found bool
+		for _, l := range b.lines {
+			if re.MatchString(l) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("%smissing: %s", scope, re.String()))
+		}
+	}
+	for i, l := range b.lines {
+		if matchAny(r.mustNotMatch, l) && !matchAny(r.except, l) {
+			if showLines {
+				failures = append(failures, fmt.Sprintf("%sforbidden line %d: %s", scope, b.first+i, strings.TrimSpace(l)))
+			} else {
+				failures = append(failures, fmt.Sprintf("%sforbidden line %d", scope, b.first+i))
+			}
+		}
+	}
+	return failures
+}
+
+// check evaluates the rule against configuration lines.
+func (r *rule) check(lines []string, showLines bool) (bool, string) {
+	var failures []string
+
+	if r.block == nil {
+		failures = r.checkLines(configBlock{first: 1, lines: lines}, "", showLines, failures)
+	} else {
+		blocks := findBlocks(lines, r.block)
+		if len(blocks) < 1 {
+			return true, "no block matched"
+		}
+		for _, b := range blocks {
+			scope := fmt.Sprintf("block line %d: ", b.first)
+			if showLines {
+				scope = fmt.Sprintf("block '%s': ", strings.TrimSpace(b.lines[0]))
+			}
+			failures = r.checkLines(b, scope, showLines, failures)
+		}
+	}
+
+	if len(failures) < 1 {
+		return true, ""
+	}
+
+	if len(failures) > maxRuleFailures {
+		return false, strings.Join(failures[:maxRuleFailures], "; ") + fmt.Sprintf("; ... (%d failures)", len(failures))
+	}
+	return false, strings.Join(failures, "; ")
+}
+
+// evaluatePolicies checks a configuration against the policies selecting the device.
+// Unless showLines is set, failure details report line numbers only.
+// It returns nil when no policy applies to the device.
+func evaluatePolicies(policies []*policy, d *Device, config []byte, showLines bool) *ComplianceReport {
+	lines := splitLines(config)
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, "\r")
+	}
+
+	var results []RuleResult
+	pass := true
+	for _, p := range policies {
+		if !p.selects(d) {
+			continue
+		}
+		for _, r := range p.rules {
+			ok, msg := r.check(lines, showLines)
+			results = append(results, RuleResult{Policy: p.Name, Rule: r.name, Pass: ok, Msg: msg})
+			pass = pass && ok
+		}
+	}
+
+	if len(results) < 1 {
+		return nil
+	}
+
+	return &ComplianceReport{DevID: d.ID, Model: d.DevConfig.Model, Pass: pass, Results: results}
+}
+
+// CheckCompliance evaluates policies against the latest configuration saved for a device,
+// recording the report in the device table.
+// If the saved configuration holds masked secrets, its unmasked copy is evaluated,
+// and failure details omit line contents. Without the unmasked copy, every rule is reported as inconclusive.
+// It returns a nil report when no policy applies to the device.
+func (t *DeviceTable) CheckCompliance(id, repository string, opt *conf.AppConfig, logger hasPrintf) (*ComplianceReport, error) {
+	d, getErr := t.GetDevice(id)
+	if getErr != nil {
+		return nil, fmt.Errorf("CheckCompliance: %v", getErr)
+	}
+
+	t.lock.RLock()
+	var policies []*policy
+	for _, p := range t.policies {
+		if p.selects(d) {
+			policies = append(policies, p)
+		}
+	}
+	t.lock.RUnlock()
+
+	var report *ComplianceReport
+
+	if len(policies) > 0 {
+		last, lastErr := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repository)), logger)
+		if lastErr != nil {
+			return nil, fmt.Errorf("CheckCompliance: %v", lastErr)
+		}
+
+		config, readErr := store.FileRead(last, opt.MaxConfigLoadSize)
+		if readErr != nil {
+			return nil, fmt.Errorf("CheckCompliance: %v", readErr)
+		}
+
+		showLines := true
+		var inconclusive string // why rules could not see secret values
+		if hasMaskedSecrets(config) {
+			if opt.UnmaskedPath == "" {
+				inconclusive = "no unmasked copy (global option unmaskedpath)"
+			} else if unmasked, unmaskedErr := store.FileRead(DeviceFullPath(opt.UnmaskedPath, id, filepath.Base(last)), opt.MaxConfigLoadSize); unmaskedErr != nil {
+				logger.Printf("CheckCompliance: %s: unmasked copy: %v", id, unmaskedErr)
+				inconclusive = fmt.Sprintf("unmasked copy: %v", unmaskedErr)
+			} else {
+				config = unmasked
+				showLines = false // keep secrets out of reports
+			}
+		}
+
+		report = evaluatePolicies(policies, d, config, showLines)
+		if report != nil {
+			report.File = filepath.Base(last)
+			report.When = time.Now()
+			if inconclusive != "" {
+				report.markInconclusive(inconclusive)
+			}
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if report == nil {
+		delete(t.compliance, id) // drop stale report
+		return nil, nil
+	}
+
+	r1 := *report // force copy data
+	t.compliance[id] = &r1
+
+	return report, nil
+}
+
+// CheckAllCompliance evaluates policies for all devices, returning the number of failed checks.
+func CheckAllCompliance(tab *DeviceTable, logger hasPrintf, repository string, opt *conf.AppConfig) int {
+	var failed int
+	for _, d := range tab.ListDevices() {
+		if d.Deleted {
+			continue
+		}
+		if _, err := tab.CheckCompliance(d.ID, repository, opt, logger); err != nil {
+			logger.Printf("CheckAllCompliance: %s: %v", d.ID, err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// SetPolicies validates and replaces the full set of compliance policies in the device table.
+// Reports produced by previous policies are discarded.
+func (t *DeviceTable) SetPolicies(policies []conf.Policy) error {
+	list, err := compilePolicies(policies)
+	if err != nil {
+		return fmt.Errorf("DeviceTable.SetPolicies: %v", err)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.policies = list
+	t.compliance = map[string]*ComplianceReport{}
+
+	return nil
+}
+
+// ListPolicies gets the names of compliance policies.
+func (t *DeviceTable) ListPolicies() []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	names := make([]string, len(t.policies))
+	for i, p := range t.policies {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// GetCompliance gets the latest compliance report for a device.
+func (t *DeviceTable) GetCompliance(id string) (*ComplianceReport, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if r, found := t.compliance[id]; found {
+		r1 := *r // force copy data
+		return &r1, nil
+	}
+
+	return nil, fmt.Errorf("DeviceTable.GetCompliance: not found")
+}
+
+// ListCompliance gets the latest compliance reports for all devices, sorted by device ID.
+func (t *DeviceTable) ListCompliance() []ComplianceReport {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	reports := make([]ComplianceReport, 0, len(t.compliance))
+	for _, r := range t.compliance {
+		reports = append(reports, *r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].DevID < reports[j].DevID })
+	return reports
+}
+
+// ExportComplianceJSON writes compliance reports as JSON.
+func ExportComplianceJSON(w io.Writer, reports []ComplianceReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(reports); err != nil {
+		return fmt.Errorf("ExportComplianceJSON: %v", err)
+	}
+	return nil
+}
+
+// ExportComplianceCSV writes compliance reports as CSV, one row per rule result.
+func ExportComplianceCSV(w io.Writer, reports []ComplianceReport) error {
+	c := csv.NewWriter(w)
+
+	if err := c.Write([]string{"device", "model", "file", "when", "policy", "rule", "pass", "message"}); err != nil {
+		return fmt.Errorf("ExportComplianceCSV: %v", err)
+	}
+
+	for _, r := range reports {
+		when := r.When.Format(time.RFC3339)
+		for _, rr := range r.Results {
+			if err := c.Write([]string{r.DevID, r.Model, r.File, when, rr.Policy, rr.Rule, strconv.FormatBool(rr.Pass), rr.Msg}); err != nil {
+				return fmt.Errorf("ExportComplianceCSV: %v", err)
+			}
+		}
+	}
+
+	c.Flush()
+
+	if err := c.Error(); err != nil {
+		return fmt.Errorf("ExportComplianceCSV: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+const complianceConfig = `hostname lab1
+service password-encryption
+snmp-server community public RO
+snmp-server community s3cr3t RO
+ntp server 10.0.0.1
+ntp server 10.0.0.9
+interface Gi0/1
+ description uplink
+ no shutdown
+interface Gi0/2
+ shutdown
+`
+
+var compliancePolicies = []conf.Policy{
+	{
+		Name:   "baseline",
+		Models: []string{"cisco-ios"},
+		Rules: []conf.Rule{
+			{Name: "encryption", MustMatch: []string{`^service password-encryption$`}},
+			{Name: "snmp", MustNotMatch: []string{`^snmp-server community public`}},
+			{Name: "ntp", MustMatch: []string{`^ntp server 10\.0\.0\.1$`, `^ntp server 10\.0\.0\.2$`}, MustNotMatch: []string{`^ntp server`}, Except: []string{`10\.0\.0\.[12]$`}},
+			{Name: "described", Block: `^interface `, MustMatch: []string{`^ description`}},
+			{Name: "nothing", Block: `^router bgp`, MustMatch: []string{`neighbor`}},
+		},
+	},
+	{
+		Name:      "other",
+		IDPattern: "^core",
+		Rules:     []conf.Rule{{Name: "any", MustMatch: []string{"x"}}},
+	},
+}
+
+func TestComplianceEvaluate(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	policies, err := compilePolicies(compliancePolicies)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	d, _ := tab.GetDevice("lab1")
+	report := evaluatePolicies(policies, d, []byte(complianceConfig), true)
+	if report == nil {
+		t.Fatalf("nil report")
+	}
+
+	expected := map[string]bool{"encryption": true, "snmp": false, "ntp": false, "described": false, "nothing": true}
+	if len(report.Results) != len(expected) {
+		t.Fatalf("results: wanted=%d got=%d: %v", len(expected), len(report.Results), report.Results)
+	}
+	for _, r := range report.Results {
+		if r.Pass != expected[r.Rule] {
+			t.Errorf("rule %s: wanted pass=%v got=%v msg=%s", r.Rule, expected[r.Rule], r.Pass, r.Msg)
+		}
+	}
+	if report.Pass || report.Failed() != 3 {
+		t.Errorf("report: pass=%v failed=%d", report.Pass, report.Failed())
+	}
+
+	for _, r := range report.Results {
+		switch r.Rule {
+		case "ntp":
+			if !strings.Contains(r.Msg, `missing: ^ntp server 10\.0\.0\.2$`) || !strings.Contains(r.Msg, "forbidden line 6: ntp server 10.0.0.9") {
+				t.Errorf("ntp: unexpected msg: %s", r.Msg)
+			}
+		case "described":
+			if !strings.Contains(r.Msg, "block 'interface Gi0/2'") || strings.Contains(r.Msg, "Gi0/1") {
+				t.Errorf("described: unexpected msg: %s", r.Msg)
+			}
+		}
+	}
+
+	if _, err := compilePolicies([]conf.Policy{{Name: "bad", Rules: []conf.Rule{{Name: "empty"}}}}); err == nil {
+		t.Errorf("rule without patterns: expected error")
+	}
+	if _, err := compilePolicies([]conf.Policy{{Name: "bad", Rules: []conf.Rule{{MustMatch: []string{"("}}}}}); err == nil {
+		t.Errorf("bad pattern: expected error")
+	}
+}
+
+func TestComplianceCheck(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+	CreateDevice(tab, logger, "junos", "lab2", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	if err := tab.SetPolicies(compliancePolicies); err != nil {
+		t.Fatalf("policies: %v", err)
+	}
+
+	d, _ := tab.GetDevice("lab1")
+	store.MkDir(d.DeviceDir(repo))
+	write := func(w store.HasWrite) error {
+		_, err := w.Write([]byte(complianceConfig))
+		return err
+	}
+	if _, err := store.SaveNewConfig(d.DevicePathPrefix(d.DeviceDir(repo)), 10, logger, write, false, ""); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if failed := CheckAllCompliance(tab, logger, repo, &conf.AppConfig{MaxConfigLoadSize: 1000000}); failed != 0 {
+		t.Errorf("check all: failed=%d", failed)
+	}
+
+	reports := tab.ListCompliance()
+	if len(reports) != 1 || reports[0].DevID != "lab1" || reports[0].File != "lab1.0" {
+		t.Fatalf("unexpected reports: %v", reports)
+	}
+	if _, err := tab.GetCompliance("lab2"); err == nil {
+		t.Errorf("lab2: no policy should apply")
+	}
+
+	var buf bytes.Buffer
+	if err := ExportComplianceCSV(&buf, reports); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	rows, csvErr := csv.NewReader(&buf).ReadAll()
+	if csvErr != nil {
+		t.Fatalf("csv read: %v", csvErr)
+	}
+	if len(rows) != 6 {
+		t.Errorf("csv: wanted=6 rows got=%d", len(rows))
+	}
+
+	if err := ExportComplianceJSON(io.Discard, reports); err != nil {
+		t.Errorf("json: %v", err)
+	}
+
+	// dropping policies discards reports
+	if err := tab.SetPolicies(nil); err != nil {
+		t.Errorf("policies: %v", err)
+	}
+	if report, err := tab.CheckCompliance("lab1", repo, &conf.AppConfig{MaxConfigLoadSize: 1000000}, logger); err != nil || report != nil {
+		t.Errorf("no policies: report=%v err=%v", report, err)
+	}
+	if len(tab.ListCompliance()) != 0 {
+		t.Errorf("no policies: stale reports")
+	}
+}
+
+func TestComplianceMaskedWithoutUnmasked(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"} // no unmaskedpath
+
+	d, _ := tab.GetDevice("lab1")
+	d.attr.SecretMask = "cisco-ios"
+	capture := dialog{save: [][]byte{[]byte("hostname lab1\nsnmp-server community public RO\nend")}}
+	if err := d.saveCommit(logger, &capture, repo, opt, NewFilterTable(logger)); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	policies := []conf.Policy{{Name: "snmp", Rules: []conf.Rule{{Name: "public", MustNotMatch: []string{`^snmp-server community public`}}}}}
+	if err := tab.SetPolicies(policies); err != nil {
+		t.Fatalf("policies: %v", err)
+	}
+
+	// forbidden secret is hidden behind its hash token: must not pass
+	report, compErr := tab.CheckCompliance("lab1", repo, opt, logger)
+	if compErr != nil {
+		t.Fatalf("compliance: %v", compErr)
+	}
+	if report.Pass || report.Results[0].Pass || !strings.HasPrefix(report.Results[0].Msg, "inconclusive: ") {
+		t.Errorf("masked config without unmasked copy: pass=%v msg=%q", report.Pass, report.Results[0].Msg)
+	}
+
+	// unreadable unmasked copy
+	opt.UnmaskedPath = filepath.Join(repo, "missing")
+	report, compErr = tab.CheckCompliance("lab1", repo, opt, logger)
+	if compErr != nil {
+		t.Fatalf("compliance: %v", compErr)
+	}
+	if report.Pass || !strings.HasPrefix(report.Results[0].Msg, "inconclusive: ") {
+		t.Errorf("missing unmasked copy: pass=%v msg=%q", report.Pass, report.Results[0].Msg)
+	}
+}
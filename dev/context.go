@@ -0,0 +1,86 @@
+package dev
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// contextNameRegexp restricts context names, since they become directory names.
+var contextNameRegexp = regexp.MustCompile(`^[\w.-]+$`)
+
+// DeviceContextPrefix gets the path prefix for output captured within a security context.
+// Context files are numbered after the system configuration files they were fetched along with.
+func DeviceContextPrefix(repository, id, context string) string {
+	return filepath.Join(deviceDirectory(repository, id), "contexts", context, id+".")
+}
+
+// listContexts finds context names in the output of the context list command.
+func listContexts(re *regexp.Regexp, output []byte) ([]string, error) {
+	var names []string
+	found := map[string]bool{}
+	for _, m := range re.FindAllSubmatch(output, -1) {
+		if len(m) < 2 {
+			return nil, fmt.Errorf("listContexts: pattern '%s' lacks capture group", re)
+		}
+		name := string(m[1])
+		if !contextNameRegexp.MatchString(name) || name == "." || name == ".." {
+			return nil, fmt.Errorf("listContexts: bad context name: '%s'", name)
+		}
+		if found[name] {
+			continue
+		}
+		found[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// fetchContexts discovers security contexts, then captures the context command list within every context.
+// The context list output is saved along with system output.
+func (d *Device) fetchContexts(logger hasPrintf, t transp, capture *dialog) error {
+
+	re, reErr := regexp.Compile(d.attr.ContextListPattern)
+	if reErr != nil {
+		return fmt.Errorf("fetchContexts: bad context list pattern '%s': %v", d.attr.ContextListPattern, reErr)
+	}
+
+	if err := d.sendCommandList(logger, t, capture, []string{d.attr.ContextListCommand}); err != nil {
+		return fmt.Errorf("fetchContexts: list: %v", err)
+	}
+
+	names, listErr := listContexts(re, capture.save[len(capture.save)-1])
+	if listErr != nil {
+		return fmt.Errorf("fetchContexts: %v", listErr)
+	}
+
+	if len(names) < 1 {
+		d.debugf("fetchContexts: no context found - single context mode")
+		return nil
+	}
+
+	logger.Printf("fetchContexts: dev '%s' contexts: %v", d.ID, names)
+
+	var discard dialog // context switching output is not saved
+
+	for _, name := range names {
+		if err := d.sendCommandList(logger, t, &discard, []string{fmt.Sprintf(d.attr.ContextChangeCommand, name)}); err != nil {
+			return fmt.Errorf("fetchContexts: change to context '%s': %v", name, err)
+		}
+
+		var ctx dialog
+		if err := d.sendCommandList(logger, t, &ctx, d.attr.ContextCommandList); err != nil {
+			return fmt.Errorf("fetchContexts: context '%s': %v", name, err)
+		}
+
+		capture.contexts = append(capture.contexts, namedCapture{name: name, save: ctx.save})
+	}
+
+	if d.attr.ContextExitCommand != "" {
+		if err := d.sendCommandList(logger, t, &discard, []string{d.attr.ContextExitCommand}); err != nil {
+			return fmt.Errorf("fetchContexts: exit context: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+)
+
+// Names of device facts. Model fact extractors map these names to patterns capturing the fact value in the first group.
+const (
+	FactHostname = "hostname"
+	FactVersion  = "version"
+	FactSerial   = "serial"
+	FactPlatform = "platform"
+	FactUptime   = "uptime"
+)
+
+// factNames lists known device facts.
+var factNames = []string{FactHostname, FactVersion, FactSerial, FactPlatform, FactUptime}
+
+// Facts holds structured information extracted from the command output captured for a device.
+type Facts struct {
+	DevID    string
+	Model    string
+	HostPort string
+	File     string // configuration file the facts were extracted along with
+	When     time.Time
+	Hostname string
+	Version  string
+	Serial   string
+	Platform string
+	Uptime   string
+}
+
+// set assigns a fact value by name.
+func (f *Facts) set(name, value string) {
+	switch name {
+	case FactHostname:
+		f.Hostname = value
+	case FactVersion:
+		f.Version = value
+	case FactSerial:
+		f.Serial = value
+	case FactPlatform:
+		f.Platform = value
+	case FactUptime:
+		f.Uptime = value
+	}
+}
+
+// validateFacts checks fact extractors: known fact names and valid regular expressions with a capture group.
+func validateFacts(facts map[string]string) error {
+	for name, pattern := range facts {
+		known := false
+		for _, n := range factNames {
+			if n == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown fact '%s'", name)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("bad fact %s pattern '%s': %v", name, pattern, err)
+		}
+		if re.NumSubexp() < 1 {
+			return fmt.Errorf("fact %s pattern '%s' lacks capture group", name, pattern)
+		}
+	}
+	return nil
+}
+
+// extractFacts applies fact extractors to command output.
+// The first match for each pattern provides the fact value.
+func extractFacts(facts map[string]string, output []byte) (*Facts, error) {
+	f := &Facts{}
+	for name, pattern := range facts {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("extractFacts: bad fact %s pattern '%s': %v", name, pattern, err)
+		}
+		m := re.FindSubmatch(output)
+		if len(m) < 2 {
+			continue
+		}
+		f.set(name, string(bytes.TrimSpace(m[1])))
+	}
+	return f, nil
+}
+
+// DeviceFactsPrefix gets the path prefix for facts extracted from device configurations.
+// Facts files are numbered after the configuration files they were extracted along with.
+func DeviceFactsPrefix(repository, id string) string {
+	return filepath.Join(deviceDirectory(repository, id), "facts", id+".")
+}
+
+// saveFacts extracts facts from captured output and stores them as a JSON sidecar for the saved configuration.
+func (d *Device) saveFacts(logger hasPrintf, capture *dialog, path, repository string, opt *conf.AppConfig) error {
+	if len(d.devModel.facts) < 1 {
+		return nil // no fact extractors
+	}
+
+	f, extractErr := extractFacts(d.devModel.facts, bytes.Join(capture.save, []byte{'\n'}))
+	if extractErr != nil {
+		return fmt.Errorf("saveFacts: %v", extractErr)
+	}
+
+	f.DevID = d.ID
+	f.Model = d.Model()
+	f.HostPort = d.HostPort
+	f.File = filepath.Base(path)
+	f.When = time.Now()
+
+	id, idErr := store.ExtractCommitIDFromFilename(path)
+	if idErr != nil {
+		return fmt.Errorf("saveFacts: %v", idErr)
+	}
+
+	writeFunc := func(w store.HasWrite) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(f)
+	}
+
+	factsPath, saveErr := store.SaveConfigCopy(DeviceFactsPrefix(repository, d.ID), id, opt.MaxConfigFiles, logger, writeFunc, "application/json")
+	if saveErr != nil {
+		return fmt.Errorf("saveFacts: %v", saveErr)
+	}
+
+	logger.Printf("saveFacts: dev '%s' facts saved to '%s'", d.ID, factsPath)
+
+	return nil
+}
+
+// LoadFacts loads the facts extracted along with the latest configuration saved for a device,
+// then stores them into the device table.
+// Missing facts drop previously loaded ones.
+func (t *DeviceTable) LoadFacts(id, repository string, opt *conf.AppConfig, logger hasPrintf) (*Facts, error) {
+	d, getErr := t.GetDevice(id)
+	if getErr != nil {
+		return nil, fmt.Errorf("LoadFacts: %v", getErr)
+	}
+
+	var f *Facts
+
+	lastConfig, lastErr := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repository)), logger)
+	if lastErr == nil {
+		commitID, idErr := store.ExtractCommitIDFromFilename(lastConfig)
+		if idErr != nil {
+			return nil, fmt.Errorf("LoadFacts: %v", idErr)
+		}
+		factsPath := DeviceFactsPrefix(repository, id) + strconv.Itoa(commitID)
+		if b, readErr := store.FileRead(factsPath, opt.MaxConfigLoadSize); readErr == nil {
+			f = &Facts{}
+			if err := json.Unmarshal(b, f); err != nil {
+				return nil, fmt.Errorf("LoadFacts: '%s': %v", factsPath, err)
+			}
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if f == nil {
+		delete(t.facts, id) // drop stale facts
+		return nil, nil
+	}
+
+	f1 := *f // force copy data
+	t.facts[id] = &f1
+
+	return f, nil
+}
+
+// LoadAllFacts loads facts for all devices, returning the number of failures.
+func LoadAllFacts(tab *DeviceTable, logger hasPrintf, repository string, opt *conf.AppConfig) int {
+	var failed int
+	for _, d := range tab.ListDevices() {
+		if d.Deleted {
+			continue
+		}
+		if _, err := tab.LoadFacts(d.ID, repository, opt, logger); err != nil {
+			logger.Printf("LoadAllFacts: %s: %v", d.ID, err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// GetFacts gets the latest facts for a device.
+func (t *DeviceTable) GetFacts(id string) (*Facts, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if f, found := t.facts[id]; found {
+		f1 := *f // force copy data
+		return &f1, nil
+	}
+
+	return nil, fmt.Errorf("DeviceTable.GetFacts: not found")
+}
+
+// ListFacts gets the latest facts for all devices, sorted by device ID.
+func (t *DeviceTable) ListFacts() []Facts {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	list := make([]Facts, 0, len(t.facts))
+	for _, f := range t.facts {
+		list = append(list, *f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DevID < list[j].DevID })
+	return list
+}
+
+// ExportInventoryJSON writes device facts as JSON.
+func ExportInventoryJSON(w io.Writer, facts []Facts) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(facts); err != nil {
+		return fmt.Errorf("ExportInventoryJSON: %v", err)
+	}
+	return nil
+}
+
+// ExportInventoryCSV writes device facts as a fleet inventory CSV, one row per device.
+func ExportInventoryCSV(w io.Writer, facts []Facts) error {
+	c := csv.NewWriter(w)
+
+	if err := c.Write([]string{"device", "model", "host", "file", "when", FactHostname, FactVersion, FactSerial, FactPlatform, FactUptime}); err != nil {
+		return fmt.Errorf("ExportInventoryCSV: %v", err)
+	}
+
+	for _, f := range facts {
+		if err := c.Write([]string{f.DevID, f.Model, f.HostPort, f.File, f.When.Format(time.RFC3339), f.Hostname, f.Version, f.Serial, f.Platform, f.Uptime}); err != nil {
+			return fmt.Errorf("ExportInventoryCSV: %v", err)
+		}
+	}
+
+	c.Flush()
+
+	if err := c.Error(); err != nil {
+		return fmt.Errorf("ExportInventoryCSV: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/temp"
+)
+
+const showVerIOS = `Cisco IOS Software, C2960 Software (C2960-LANBASEK9-M), Version 12.2(55)SE5, RELEASE SOFTWARE (fc1)
+lab1 uptime is 2 years, 5 weeks, 1 day, 3 hours, 10 minutes
+System image file is "flash:c2960-lanbasek9-mz.122-55.SE5.bin"
+cisco WS-C2960-24TT-L (PowerPC405) processor (revision B0) with 65536K bytes of memory.
+Processor board ID FOC1234X5YZ
+!![show run]
+hostname lab1
+`
+
+func TestFactsExtract(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	table := []struct {
+		model  string
+		output string
+		want   Facts
+	}{
+		{"cisco-ios", showVerIOS, Facts{Hostname: "lab1", Version: "12.2(55)SE5", Serial: "FOC1234X5YZ", Platform: "WS-C2960-24TT-L", Uptime: "2 years, 5 weeks, 1 day, 3 hours, 10 minutes"}},
+		{"cisco-iosxr", "Cisco IOS XR Software, Version 5.1.3[Default]\nasr9010 uptime is 9 years, 2 weeks\ncisco ASR9K Series (Intel 686 F6M14S4) processor with 6291456K bytes of memory.\nhostname asr9010\n", Facts{Hostname: "asr9010", Version: "5.1.3[Default]", Platform: "ASR9K Series", Uptime: "9 years, 2 weeks"}},
+		{"junos", "Hostname: mx1\nModel: mx480\nJunos: 18.2R3-S2.9\n", Facts{Hostname: "mx1", Version: "18.2R3-S2.9", Platform: "mx480"}},
+		{"junos", "Hostname: j1\nModel: srx240h\nJUNOS Software Release [12.1X44-D45.2]\n", Facts{Hostname: "j1", Version: "12.1X44-D45.2", Platform: "srx240h"}},
+		{"huawei-vrp", "VRP (R) software, Version 5.160 (S5700 V200R010C00SPC600)\nHUAWEI S5700-28C-EI uptime is 10 days, 2 hours, 3 minutes\n#\n sysname sw1\n", Facts{Hostname: "sw1", Version: "5.160 (S5700 V200R010C00SPC600)", Platform: "S5700-28C-EI", Uptime: "10 days, 2 hours, 3 minutes"}},
+		{"fortios", "Version: FortiGate-60D v5.4.1,build1064,160608 (GA)\nSerial-Number: FGT60D4614000001\nHostname: fw1\n", Facts{Hostname: "fw1", Version: "v5.4.1", Serial: "FGT60D4614000001", Platform: "FortiGate-60D"}},
+		{"mikrotik", "                   uptime: 1w2d3h\r\n                  version: 6.37.3 (stable)\r\n               board-name: RB951Ui-2HnD\r\n/system identity\r\nset name=rt1\r\n", Facts{Hostname: "rt1", Version: "6.37.3 (stable)", Platform: "RB951Ui-2HnD", Uptime: "1w2d3h"}},
+	}
+
+	for _, data := range table {
+		m, getErr := tab.GetModel(data.model)
+		if getErr != nil {
+			t.Fatalf("%s: %v", data.model, getErr)
+		}
+		if err := validateFacts(m.facts); err != nil {
+			t.Errorf("%s: %v", data.model, err)
+		}
+		got, err := extractFacts(m.facts, []byte(data.output))
+		if err != nil {
+			t.Fatalf("%s: %v", data.model, err)
+		}
+		if *got != data.want {
+			t.Errorf("%s: wanted=%+v got=%+v", data.model, data.want, *got)
+		}
+	}
+
+	if err := validateFacts(map[string]string{"color": `(x)`}); err == nil {
+		t.Errorf("unknown fact: expected error")
+	}
+	if err := validateFacts(map[string]string{FactSerial: `x`}); err == nil {
+		t.Errorf("missing capture group: expected error")
+	}
+}
+
+func TestFactsSave(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+	CreateDevice(tab, logger, "cisco-ios", "lab2", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000}
+	ft := NewFilterTable(logger)
+
+	d, _ := tab.GetDevice("lab1")
+	capture := dialog{save: [][]byte{[]byte(showVerIOS)}}
+	if err := d.saveCommit(logger, &capture, repo, opt, ft); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	if failed := LoadAllFacts(tab, logger, repo, opt); failed != 0 {
+		t.Errorf("load all: failed=%d", failed)
+	}
+
+	facts := tab.ListFacts()
+	if len(facts) != 1 || facts[0].DevID != "lab1" || facts[0].File != "lab1.0" || facts[0].Serial != "FOC1234X5YZ" {
+		t.Fatalf("unexpected facts: %+v", facts)
+	}
+	if _, err := tab.GetFacts("lab2"); err == nil {
+		t.Errorf("lab2: no facts expected")
+	}
+
+	var buf bytes.Buffer
+	if err := ExportInventoryCSV(&buf, facts); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	rows, csvErr := csv.NewReader(&buf).ReadAll()
+	if csvErr != nil {
+		t.Fatalf("csv read: %v", csvErr)
+	}
+	if len(rows) != 2 || rows[1][0] != "lab1" || rows[1][5] != "lab1" || rows[1][6] != "12.2(55)SE5" {
+		t.Errorf("unexpected inventory: %v", rows)
+	}
+}
@@ -0,0 +1,157 @@
+package dev
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// failureState is the persisted record of consecutive backup failures for a device.
+type failureState struct {
+	Failures    int       // consecutive failed backup attempts
+	LastFailure time.Time // last failed attempt
+	Quarantined bool      // device is not scanned until manually released
+}
+
+// FailurePath builds the full pathname for the file keeping device failure counters, next to errlog.
+func FailurePath(pathPrefix, id string) string {
+	dir := filepath.Dir(pathPrefix)
+	path := filepath.Join(dir, id) + ".failures"
+	return path
+}
+
+// Failures gets the number of consecutive failed backup attempts.
+func (d *Device) Failures() int {
+	return d.failures
+}
+
+// Quarantined informs whether the device is excluded from scans until manually released.
+func (d *Device) Quarantined() bool {
+	return d.quarantined
+}
+
+// failureBackoff computes the wait after consecutive failures: base doubled for every further failure, up to max.
+// Zero base disables backoff. Zero max means no limit.
+func failureBackoff(failures int, base, max time.Duration) time.Duration {
+	if failures < 1 || base <= 0 {
+		return 0
+	}
+	b := base
+	for i := 1; i < failures && b < math.MaxInt64/2; i++ {
+		b *= 2
+		if max > 0 && b >= max {
+			break
+		}
+	}
+	if max > 0 && b > max {
+		return max
+	}
+	return b
+}
+
+// retryAfter informs when a failing device might be tried again. Zero time means no backoff.
+func (d *Device) retryAfter(base, max time.Duration) time.Time {
+	wait := failureBackoff(d.failures, base, max)
+	if wait == 0 {
+		return time.Time{}
+	}
+	return d.lastFailure.Add(wait)
+}
+
+// countFailure updates consecutive failure counters after a backup attempt.
+// It reports whether counters changed and whether the device has just entered quarantine.
+// Zero quarantineAfter disables quarantine.
+func (d *Device) countFailure(good bool, when time.Time, quarantineAfter int) (bool, bool) {
+	if good {
+		changed := d.failures > 0 || d.quarantined
+		d.failures = 0
+		d.lastFailure = time.Time{}
+		d.quarantined = false
+		return changed, false
+	}
+
+	d.failures++
+	d.lastFailure = when
+
+	if quarantineAfter > 0 && d.failures >= quarantineAfter && !d.quarantined {
+		d.quarantined = true
+		return true, true
+	}
+
+	return true, false
+}
+
+// saveFailureState persists device failure counters. Devices without failures have their file removed.
+func saveFailureState(pathPrefix string, d *Device) error {
+	path := FailurePath(pathPrefix, d.ID)
+
+	if d.failures == 0 && !d.quarantined {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("saveFailureState: %v", err)
+		}
+		return nil
+	}
+
+	b, encodeErr := json.Marshal(failureState{Failures: d.failures, LastFailure: d.lastFailure, Quarantined: d.quarantined})
+	if encodeErr != nil {
+		return fmt.Errorf("saveFailureState: %v", encodeErr)
+	}
+
+	if err := os.WriteFile(path, b, 0640); err != nil {
+		return fmt.Errorf("saveFailureState: %v", err)
+	}
+
+	return nil
+}
+
+// LoadFailureStates loads persisted failure counters for all devices.
+func LoadFailureStates(tab *DeviceTable, logger hasPrintf, pathPrefix string) {
+	for _, d := range tab.ListDevices() {
+		path := FailurePath(pathPrefix, d.ID)
+
+		b, readErr := os.ReadFile(path)
+		if readErr != nil {
+			if !os.IsNotExist(readErr) {
+				logger.Printf("LoadFailureStates: '%s': %v", path, readErr)
+			}
+			continue
+		}
+
+		var s failureState
+		if err := json.Unmarshal(b, &s); err != nil {
+			logger.Printf("LoadFailureStates: '%s': %v", path, err)
+			continue
+		}
+
+		d.failures = s.Failures
+		d.lastFailure = s.LastFailure
+		d.quarantined = s.Quarantined
+		tab.UpdateDevice(d)
+
+		if d.quarantined {
+			logger.Printf("LoadFailureStates: device %s quarantined after %d failures", d.ID, d.failures)
+		}
+	}
+}
+
+// ReleaseDevice clears failure counters, bringing a quarantined device back into scans.
+func ReleaseDevice(tab DeviceUpdater, devID string, logger hasPrintf, pathPrefix string) (*Device, error) {
+	d, getErr := tab.GetDevice(devID)
+	if getErr != nil {
+		return nil, fmt.Errorf("ReleaseDevice: %v", getErr)
+	}
+
+	logger.Printf("ReleaseDevice: device %s released: failures=%d quarantined=%v", devID, d.failures, d.quarantined)
+
+	d.countFailure(true, time.Now(), 0)
+	tab.UpdateDevice(d)
+
+	if saveErr := saveFailureState(pathPrefix, d); saveErr != nil {
+		return d, fmt.Errorf("ReleaseDevice: %v", saveErr)
+	}
+
+	return d, nil
+}
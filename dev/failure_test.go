@@ -0,0 +1,99 @@
+package dev
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestFailureBackoff(t *testing.T) {
+	table := []struct {
+		failures int
+		base     time.Duration
+		max      time.Duration
+		want     time.Duration
+	}{
+		{0, 10 * time.Minute, time.Hour, 0},
+		{1, 10 * time.Minute, time.Hour, 10 * time.Minute},
+		{2, 10 * time.Minute, time.Hour, 20 * time.Minute},
+		{3, 10 * time.Minute, time.Hour, 40 * time.Minute},
+		{4, 10 * time.Minute, time.Hour, time.Hour},
+		{1000, 10 * time.Minute, time.Hour, time.Hour},
+		{3, 0, time.Hour, 0},
+		{5, time.Minute, 0, 16 * time.Minute},
+	}
+	for _, data := range table {
+		if got := failureBackoff(data.failures, data.base, data.max); got != data.want {
+			t.Errorf("failures=%d base=%s max=%s: wanted=%s got=%s", data.failures, data.base, data.max, data.want, got)
+		}
+	}
+	if got := failureBackoff(1000, time.Minute, 0); got <= 0 {
+		t.Errorf("unlimited backoff overflow: %s", got)
+	}
+}
+
+func TestFailureQuarantine(t *testing.T) {
+
+	// no server listening: every backup fails
+	addr := ":2033"
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, QuarantineAfter: 2})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost"+addr, "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	defer close(requestCh)
+
+	scan := func(wantBad, wantSkip int) {
+		_, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+		if bad != wantBad || skip != wantSkip {
+			t.Errorf("bad=%d skip=%d: wanted bad=%d skip=%d", bad, skip, wantBad, wantSkip)
+		}
+	}
+
+	scan(1, 0)
+	scan(1, 0)
+	scan(1, 1) // quarantined
+
+	d, _ := tab.GetDevice("lab1")
+	if !d.Quarantined() || d.Failures() != 2 {
+		t.Errorf("quarantined=%v failures=%d", d.Quarantined(), d.Failures())
+	}
+
+	// counters survive restart
+	tab2 := NewDeviceTable()
+	RegisterModels(logger, tab2)
+	CreateDevice(tab2, logger, "cisco-ios", "lab1", "localhost"+addr, "telnet", "lab", "pass", "en", false, nil)
+	LoadFailureStates(tab2, logger, errlogPrefix)
+	d2, _ := tab2.GetDevice("lab1")
+	if !d2.Quarantined() || d2.Failures() != 2 || !d2.lastFailure.Equal(d.lastFailure) {
+		t.Errorf("loaded: quarantined=%v failures=%d last=%s", d2.Quarantined(), d2.Failures(), d2.lastFailure)
+	}
+
+	// manual release
+	if _, err := ReleaseDevice(tab, "lab1", logger, errlogPrefix); err != nil {
+		t.Errorf("release: %v", err)
+	}
+	if _, err := os.Stat(FailurePath(errlogPrefix, "lab1")); !os.IsNotExist(err) {
+		t.Errorf("failure file not removed: %v", err)
+	}
+
+	// backoff holds failing device
+	o := opt.Get()
+	o.FailureBackoff = time.Hour
+	opt.Set(o)
+	scan(1, 0)
+	scan(1, 1)
+}
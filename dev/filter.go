@@ -1,45 +1,316 @@
 package dev
 
 import (
+	"bytes"
+	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
 )
 
 // FilterTable stores line filters for custom line-by-line processing of configuration.
+// Built-in filters are registered at creation; user-defined filters can be replaced at any time.
 type FilterTable struct {
-	table map[string]FilterFunc
-	re1   *regexp.Regexp
-	re2   *regexp.Regexp
-	re3   *regexp.Regexp
-	re4   *regexp.Regexp
+	table  map[string]filterFactory
+	custom map[string]bool             // names of user-defined filters
+	masks  map[string][]*regexp.Regexp // secret mask name => patterns
+	lock   sync.RWMutex
 }
 
 // FilterFunc is a helper function type for line filters.
+// A nil result removes the line, while an empty result keeps an empty line.
 type FilterFunc func(hasPrintf, bool, *FilterTable, []byte, int) []byte
 
+// filterFactory creates a line filter for processing a single configuration.
+// Stateful filters, like block filters, must not share state across configurations.
+type filterFactory func() FilterFunc
+
 // NewFilterTable creates a filter table.
 func NewFilterTable(logger hasPrintf) *FilterTable {
 	t := &FilterTable{
-		table: map[string]FilterFunc{},
-		re1:   regexp.MustCompile(`^\w{3}\s\w{3}\s\d{1,2}\s`), // Thu Feb 11 15:45:43.545 BRST
-		re2:   regexp.MustCompile(`^Building`),                // Building configuration...
-		re3:   regexp.MustCompile(`^!! Last`),                 // !! Last configuration change at Tue Jan 26 16:40:46 2016 by user
-		re4:   regexp.MustCompile(`^\w+ uptime is `),          // asr9010 uptime is 9 years, 2 weeks, 5 days, 20 hours, 3 minutes
+		table:  map[string]filterFactory{},
+		custom: map[string]bool{},
+		masks:  map[string][]*regexp.Regexp{},
 	}
 	registerFilters(logger, t.table)
+	registerMasks(logger, t.masks)
 	return t
 }
 
-func register(logger hasPrintf, table map[string]FilterFunc, name string, f FilterFunc) {
+func register(logger hasPrintf, table map[string]filterFactory, name string, f FilterFunc) {
+	registerFactory(logger, table, name, func() FilterFunc { return f })
+}
+
+func registerFactory(logger hasPrintf, table map[string]filterFactory, name string, f filterFactory) {
 	logger.Printf("line filter registered: '%s'", name)
 	table[name] = f
 }
 
-func registerFilters(logger hasPrintf, table map[string]FilterFunc) {
+// builtinRuleFilters drop noise lines, or drop or collapse bulky sections, like certificates.
+var builtinRuleFilters = []conf.FilterConfig{
+	{
+		Name: "cisco-certs", // crypto pki certificate chain TP / certificate 01 / hex data / quit
+		Rules: []conf.FilterRule{
+			{Action: conf.FilterCollapseBlock, Pattern: `^\s*certificate (?:ca |self-signed )?\S+$`},
+		},
+	},
+	{
+		Name: "fortios-certs", // config vpn certificate local / ... PEM data is not indented ... / end
+		Rules: []conf.FilterRule{
+			{Action: conf.FilterCollapseBlock, Pattern: `^config (?:vpn |system )?certificate `, End: `^end\s*$`},
+		},
+	},
+	{
+		Name: "junos", // routing engine status shown above prompts: {master:0}
+		Rules: []conf.FilterRule{
+			{Action: conf.FilterDrop, Pattern: `^\{(?:master|backup|primary|secondary|linecard)(?::[\w-]+)?\}\s*$`},
+		},
+	},
+	{
+		Name: "vyos", // configuration level shown by vbash: [edit]
+		Rules: []conf.FilterRule{
+			{Action: conf.FilterDrop, Pattern: `^\[edit(?: [^\]]*)?\]\s*$`},
+		},
+	},
+}
+
+func registerFilters(logger hasPrintf, table map[string]filterFactory) {
 	register(logger, table, "iosxr", filterIOSXR)
 	register(logger, table, "noop", filterNoop)
 	register(logger, table, "drop", filterDrop)
 	register(logger, table, "count_lines", filterCountLines)
+
+	for _, c := range builtinRuleFilters {
+		f, err := compileFilter(c)
+		if err != nil {
+			panic(fmt.Sprintf("registerFilters: %v", err))
+		}
+		registerFactory(logger, table, c.Name, f)
+	}
+}
+
+// get creates an instance of the line filter chain.
+// The chain is a comma-separated list of filter names, applied in order.
+// Names of missing filters are reported, and skipped from the chain.
+func (t *FilterTable) get(chain string) ([]FilterFunc, []string) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var filters []FilterFunc
+	var missing []string
+	for _, name := range strings.Split(chain, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		f, found := t.table[name]
+		if !found {
+			missing = append(missing, name)
+			continue
+		}
+		filters = append(filters, f())
+	}
+	return filters, missing
+}
+
+// applyFilters runs a line through a filter chain.
+// A nil result means some filter removed the line.
+func applyFilters(filters []FilterFunc, logger hasPrintf, debug bool, table *FilterTable, line []byte, lineNum int) []byte {
+	for _, f := range filters {
+		line = f(logger, debug, table, line, lineNum)
+		if line == nil {
+			return nil
+		}
+	}
+	return line
+}
+
+// ReplaceCustomFilters atomically swaps all user-defined line filters in the filter table.
+// Built-in filters are never replaced.
+// On error, previously loaded filters are kept.
+func (t *FilterTable) ReplaceCustomFilters(logger hasPrintf, filters []conf.FilterConfig) error {
+
+	compiled := map[string]filterFactory{}
+	for i, c := range filters {
+		if c.Name == "" || strings.Contains(c.Name, ",") {
+			return fmt.Errorf("FilterTable.ReplaceCustomFilters: filter #%d: bad name '%s'", i, c.Name)
+		}
+		if _, found := compiled[c.Name]; found {
+			return fmt.Errorf("FilterTable.ReplaceCustomFilters: filter '%s': duplicate name", c.Name)
+		}
+		f, err := compileFilter(c)
+		if err != nil {
+			return fmt.Errorf("FilterTable.ReplaceCustomFilters: %v", err)
+		}
+		compiled[c.Name] = f
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for name := range compiled {
+		if _, found := t.table[name]; found && !t.custom[name] {
+			return fmt.Errorf("FilterTable.ReplaceCustomFilters: filter '%s' collides with built-in filter", name)
+		}
+	}
+
+	for name := range t.custom {
+		delete(t.table, name)
+	}
+	t.custom = map[string]bool{}
+
+	for name, f := range compiled {
+		registerFactory(logger, t.table, name, f)
+		t.custom[name] = true
+	}
+
+	return nil
+}
+
+// ListCustomFilters gets the list of user-defined line filters.
+func (t *FilterTable) ListCustomFilters() []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var filters []string
+	for name := range t.custom {
+		filters = append(filters, name)
+	}
+	return filters
+}
+
+type filterRule struct {
+	action  string
+	re      *regexp.Regexp
+	replace []byte
+	end     *regexp.Regexp // block end, nil means indentation scoping
+}
+
+func (r *filterRule) isBlock() bool {
+	return r.action == conf.FilterDropBlock || r.action == conf.FilterCollapseBlock
+}
+
+// compileFilter builds a line filter from user-defined rules.
+func compileFilter(c conf.FilterConfig) (filterFactory, error) {
+	rules := make([]filterRule, 0, len(c.Rules))
+	for i, r := range c.Rules {
+		switch r.Action {
+		case conf.FilterDrop, conf.FilterReplace, conf.FilterKeep, conf.FilterDropBlock, conf.FilterCollapseBlock:
+		default:
+			return nil, fmt.Errorf("filter '%s': rule #%d: bad action '%s'", c.Name, i, r.Action)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("filter '%s': rule #%d: bad pattern '%s': %v", c.Name, i, r.Pattern, err)
+		}
+		rule := filterRule{action: r.Action, re: re, replace: []byte(r.Replace)}
+		if r.End != "" {
+			if !rule.isBlock() {
+				return nil, fmt.Errorf("filter '%s': rule #%d: end pattern requires block action", c.Name, i)
+			}
+			end, endErr := regexp.Compile(r.End)
+			if endErr != nil {
+				return nil, fmt.Errorf("filter '%s': rule #%d: bad end pattern '%s': %v", c.Name, i, r.End, endErr)
+			}
+			rule.end = end
+		}
+		rules = append(rules, rule)
+	}
+
+	name := c.Name
+
+	factory := func() FilterFunc {
+		b := &blockState{}
+		return func(logger hasPrintf, debug bool, table *FilterTable, line []byte, lineNum int) []byte {
+			if r := b.rule; r != nil {
+				if out, inside := b.next(line); inside {
+					if out == nil && debug {
+						logger.Printf("filter %s: %s: [%s]", name, r.action, string(line))
+					}
+					return out
+				}
+			}
+			for i := range rules {
+				r := &rules[i]
+				if !r.re.Match(line) {
+					continue
+				}
+				switch r.action {
+				case conf.FilterDrop:
+					if debug {
+						logger.Printf("filter %s: drop: [%s]", name, string(line))
+					}
+					return nil
+				case conf.FilterKeep:
+					return line
+				case conf.FilterReplace:
+					line = r.re.ReplaceAll(line, r.replace)
+				case conf.FilterDropBlock:
+					b.begin(r, line)
+					if debug {
+						logger.Printf("filter %s: drop-block: [%s]", name, string(line))
+					}
+					return nil
+				case conf.FilterCollapseBlock:
+					b.begin(r, line)
+					return line // keep header
+				}
+			}
+			return line
+		}
+	}
+
+	return factory, nil
+}
+
+// blockState tracks the block currently being dropped or collapsed by a filter.
+type blockState struct {
+	rule      *filterRule // nil when out of block
+	indent    int         // header indentation
+	collapsed bool        // marker already sent
+}
+
+func (b *blockState) begin(r *filterRule, header []byte) {
+	b.rule = r
+	b.indent = indentation(header)
+	b.collapsed = false
+}
+
+// next processes a line within the current block.
+// If the line is out of block, the block is finished and inside is false.
+func (b *blockState) next(line []byte) (out []byte, inside bool) {
+	r := b.rule
+
+	if r.end == nil {
+		// indentation scoping: blank line or line not deeper than header finishes block
+		if len(bytes.TrimSpace(line)) == 0 || indentation(line) <= b.indent {
+			b.rule = nil
+			return nil, false
+		}
+	} else if r.end.Match(line) {
+		b.rule = nil
+		if r.action == conf.FilterCollapseBlock {
+			return line, true // keep end line
+		}
+		return nil, true
+	}
+
+	if r.action == conf.FilterDropBlock || b.collapsed {
+		return nil, true
+	}
+
+	b.collapsed = true
+	indent := line[:indentation(line)]
+	return append(append([]byte{}, indent...), "<collapsed>"...), true
+}
+
+// indentation counts leading spaces and tabs.
+func indentation(line []byte) int {
+	return len(line) - len(bytes.TrimLeft(line, " \t"))
 }
 
 func filterDrop(logger hasPrintf, debug bool, table *FilterTable, line []byte, lineNum int) []byte {
@@ -55,6 +326,14 @@ func filterCountLines(logger hasPrintf, debug bool, table *FilterTable, line []b
 	return line
 }
 
+// iosxrDrop holds patterns for volatile lines in IOS XR output.
+var iosxrDrop = []*regexp.Regexp{
+	regexp.MustCompile(`^\w{3}\s\w{3}\s\d{1,2}\s`), // Thu Feb 11 15:45:43.545 BRST
+	regexp.MustCompile(`^Building`),                // Building configuration...
+	regexp.MustCompile(`^!! Last`),                 // !! Last configuration change at Tue Jan 26 16:40:46 2016 by user
+	regexp.MustCompile(`^\w+ uptime is `),          // asr9010 uptime is 9 years, 2 weeks, 5 days, 20 hours, 3 minutes
+}
+
 /*
 Thu Feb 11 15:45:43.545 BRST
 Building configuration...
@@ -64,30 +343,53 @@ asr9010 uptime is 9 years, 2 weeks, 5 days, 20 hours, 3 minutes
 */
 func filterIOSXR(logger hasPrintf, debug bool, table *FilterTable, line []byte, lineNum int) []byte {
 
-	if table.re1.Match(line) {
-		if debug {
-			logger.Printf("filterIOSXR: drop: [%s]", string(line))
+	for _, re := range iosxrDrop {
+		if re.Match(line) {
+			if debug {
+				logger.Printf("filterIOSXR: drop: [%s]", string(line))
+			}
+			return []byte{}
 		}
-		return []byte{}
 	}
-	if table.re2.Match(line) {
-		if debug {
-			logger.Printf("filterIOSXR: drop: [%s]", string(line))
+
+	return line
+}
+
+// CompileIgnorePatterns compiles patterns for volatile lines ignored when comparing configurations.
+func CompileIgnorePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	list := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("CompileIgnorePatterns: bad pattern '%s': %v", p, err)
 		}
-		return []byte{}
+		list = append(list, re)
 	}
-	if table.re3.Match(line) {
-		if debug {
-			logger.Printf("filterIOSXR: drop: [%s]", string(line))
+	return list, nil
+}
+
+// IgnoredLine checks whether a line matches any pattern for volatile lines.
+func IgnoredLine(patterns []*regexp.Regexp, line []byte) bool {
+	for _, re := range patterns {
+		if re.Match(line) {
+			return true
 		}
-		return []byte{}
 	}
-	if table.re4.Match(line) {
-		if debug {
-			logger.Printf("filterIOSXR: drop: [%s]", string(line))
+	return false
+}
+
+// ignoreLines builds a normalize function which removes volatile lines from a configuration.
+func ignoreLines(patterns []*regexp.Regexp) store.NormalizeFunc {
+	if len(patterns) < 1 {
+		return nil
+	}
+	return func(b []byte) []byte {
+		var out [][]byte
+		for _, line := range bytes.Split(b, []byte{'\n'}) {
+			if !IgnoredLine(patterns, line) {
+				out = append(out, line)
+			}
 		}
-		return []byte{}
+		return bytes.Join(out, []byte{'\n'})
 	}
-
-	return line
 }
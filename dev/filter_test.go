@@ -0,0 +1,298 @@
+package dev
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+// TestFilterSample shows before/after output of the sample user-defined filter.
+func TestFilterSample(t *testing.T) {
+	logger := &testLogger{t}
+
+	filters, loadErr := conf.LoadFilterDir("../samples", 1000000)
+	if loadErr != nil {
+		t.Fatalf("load filters: %v", loadErr)
+	}
+
+	ft := NewFilterTable(logger)
+	if err := ft.ReplaceCustomFilters(logger, filters); err != nil {
+		t.Fatalf("register filters: %v", err)
+	}
+
+	input, inputErr := os.ReadFile("../samples/cisco_iosxr_show_run.txt")
+	if inputErr != nil {
+		t.Fatalf("read sample: %v", inputErr)
+	}
+	expected, expectedErr := os.ReadFile("../samples/cisco_iosxr_show_run.filtered.txt")
+	if expectedErr != nil {
+		t.Fatalf("read filtered sample: %v", expectedErr)
+	}
+
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-iosxr", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+	d, _ := tab.GetDevice("lab1")
+	d.attr.LineFilter = "iosxr-volatile"
+
+	capture := dialog{save: [][]byte{bytes.TrimRight(input, "\n")}}
+	var buf bytes.Buffer
+	if err := d.captureWriter(&capture, ft, nil)(&buf); err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+
+	t.Logf("before:\n%s", input)
+	t.Logf("after:\n%s", buf.Bytes())
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("filtered output mismatch:\nwanted:\n%s\ngot:\n%s", expected, buf.Bytes())
+	}
+}
+
+func TestFilterReplaceCustom(t *testing.T) {
+	logger := &testLogger{t}
+	ft := NewFilterTable(logger)
+
+	good := []conf.FilterConfig{{Name: "f1", Rules: []conf.FilterRule{{Action: conf.FilterDrop, Pattern: "^x"}}}}
+	if err := ft.ReplaceCustomFilters(logger, good); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	filters, missing := ft.get("f1")
+	if len(filters) != 1 || len(missing) != 0 {
+		t.Fatalf("filter f1 not found")
+	}
+	f := filters[0]
+	if line := f(logger, false, ft, []byte("xyz"), 1); line != nil {
+		t.Errorf("drop: got [%s]", line)
+	}
+	if line := f(logger, false, ft, []byte(""), 1); line == nil {
+		t.Errorf("empty line removed")
+	}
+
+	bad := [][]conf.FilterConfig{
+		{{Name: "f2", Rules: []conf.FilterRule{{Action: "remove", Pattern: "x"}}}},
+		{{Name: "f2", Rules: []conf.FilterRule{{Action: conf.FilterDrop, Pattern: "("}}}},
+		{{Name: "iosxr"}},
+		{{Name: "f2"}, {Name: "f2"}},
+		{{}},
+	}
+	for i, filters := range bad {
+		if err := ft.ReplaceCustomFilters(logger, filters); err == nil {
+			t.Errorf("bad filters #%d: expected error", i)
+		}
+	}
+
+	// failed replacement keeps previous filters
+	if list := ft.ListCustomFilters(); len(list) != 1 || list[0] != "f1" {
+		t.Errorf("unexpected custom filters: %v", list)
+	}
+
+	if err := ft.ReplaceCustomFilters(logger, nil); err != nil {
+		t.Errorf("clear: %v", err)
+	}
+	if _, missing := ft.get("f1"); len(missing) != 1 {
+		t.Errorf("filter f1 not removed")
+	}
+	if _, missing := ft.get("iosxr"); len(missing) != 0 {
+		t.Errorf("built-in filter iosxr removed")
+	}
+}
+
+// TestFilterBlockSamples checks built-in block filters against every sample config.
+func TestFilterBlockSamples(t *testing.T) {
+	logger := &testLogger{t}
+	ft := NewFilterTable(logger)
+
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	table := []struct {
+		model   string
+		sample  string
+		chain   string
+		removed int      // lines removed by block filters
+		want    []string // expected lines in output
+	}{
+		{"cisco-apic", "cisco_aci_apic.txt", "cisco-certs", 0, nil},
+		{"cisco-ios", "cisco_ios.txt", "cisco-certs", 0, nil},
+		{"cisco-ios", "cisco_ios_show_run_certs.txt", "cisco-certs", 3, []string{" certificate self-signed 01\n  <collapsed>\ncrypto pki certificate chain SLA-TrustPoint\n", " certificate ca 01\n  <collapsed>\n!\n"}},
+		{"cisco-iosxr", "cisco_iosxr.txt", "cisco-certs", 0, nil},
+		{"cisco-iosxr", "cisco_iosxr_show_run.txt", "cisco-certs", 0, nil},
+		{"cisco-nga", "cisco_nga.txt", "cisco-certs", 0, nil},
+		{"dmswitch", "datacom_dmswitch.txt", "cisco-certs", 0, nil},
+		{"fortios", "fortinet_fortios.txt", "fortios-certs", 0, nil},
+		{"fortios", "fortinet_fortios_show_certs.txt", "fortios-certs", 5, []string{"config vpn certificate local\n    <collapsed>\nend\nconfig vpn certificate ca\nend\nconfig router multicast\n"}},
+		{"huawei-vrp", "huawei_vrp.txt", "cisco-certs", 0, nil},
+		{"junos", "juniper_junos.txt", "cisco-certs", 0, nil},
+		{"mikrotik", "mikrotik.txt", "fortios-certs", 0, nil},
+	}
+
+	for _, data := range table {
+		input, readErr := os.ReadFile("../samples/" + data.sample)
+		if readErr != nil {
+			t.Fatalf("%s: read sample: %v", data.sample, readErr)
+		}
+
+		id := data.model + "-" + data.sample
+		CreateDevice(tab, logger, data.model, id, "localhost", "telnet", "lab", "pass", "en", false, nil)
+		d, getErr := tab.GetDevice(id)
+		if getErr != nil {
+			t.Fatalf("%s: %v", id, getErr)
+		}
+
+		// output under model default filter only
+		modelFilter := d.attr.LineFilter
+		d.attr.LineFilter = strings.Trim(modelFilter+",noop", ",")
+		capture := dialog{save: [][]byte{bytes.TrimRight(input, "\n")}}
+		var plain bytes.Buffer
+		if err := d.captureWriter(&capture, ft, nil)(&plain); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+
+		// then append block filter to model filter chain
+		d.attr.LineFilter = strings.Trim(modelFilter+","+data.chain, ",")
+		var filtered bytes.Buffer
+		if err := d.captureWriter(&capture, ft, nil)(&filtered); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+
+		if data.removed == 0 {
+			if filtered.String() != plain.String() {
+				t.Errorf("%s: chain '%s' changed output:\nbefore:\n%s\nafter:\n%s", id, d.attr.LineFilter, plain.String(), filtered.String())
+			}
+			continue
+		}
+
+		t.Logf("%s: chain '%s':\nbefore:\n%s\nafter:\n%s", id, d.attr.LineFilter, plain.String(), filtered.String())
+
+		if removed := bytes.Count(plain.Bytes(), []byte{'\n'}) - bytes.Count(filtered.Bytes(), []byte{'\n'}); removed != data.removed {
+			t.Errorf("%s: removed lines: wanted=%d got=%d", id, data.removed, removed)
+		}
+		for _, w := range data.want {
+			if !strings.Contains(filtered.String(), w) {
+				t.Errorf("%s: missing output: %q", id, w)
+			}
+		}
+		if strings.Contains(filtered.String(), "3082") || strings.Contains(filtered.String(), "BEGIN CERTIFICATE") {
+			t.Errorf("%s: certificate data left in output", id)
+		}
+	}
+}
+
+func TestFilterBlockRules(t *testing.T) {
+	logger := &testLogger{t}
+	ft := NewFilterTable(logger)
+
+	filters := []conf.FilterConfig{
+		{Name: "no-banner", Rules: []conf.FilterRule{{Action: conf.FilterDropBlock, Pattern: `^banner motd`, End: `^\^C$`}}},
+		{Name: "no-bgp", Rules: []conf.FilterRule{{Action: conf.FilterDropBlock, Pattern: `^router bgp`}}},
+		{Name: "short-desc", Rules: []conf.FilterRule{
+			{Action: conf.FilterReplace, Pattern: `^ description .*`, Replace: " description x"},
+			{Action: conf.FilterCollapseBlock, Pattern: `^interface Loopback`},
+		}},
+	}
+	if err := ft.ReplaceCustomFilters(logger, filters); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	input := "hostname r1\nbanner motd\n^C\nwelcome\n^C\ninterface Loopback0\n description a\n ip address 1.1.1.1\ninterface Gi0\n description b\nrouter bgp 1\n neighbor 2.2.2.2\n\nend\nrouter bgp 2\n neighbor 3.3.3.3"
+
+	run := func(chain string) string {
+		filters, missing := ft.get(chain)
+		if len(missing) > 0 {
+			t.Errorf("chain '%s': missing filters: %v", chain, missing)
+		}
+		var out []string
+		for i, line := range strings.Split(input, "\n") {
+			if result := applyFilters(filters, logger, true, ft, []byte(line), i+1); result != nil {
+				out = append(out, string(result)+"\n")
+			}
+		}
+		return strings.Join(out, "")
+	}
+
+	// banner begins and ends with same line, hence end pattern first matches the very next line
+	if got := run("no-banner, no-bgp ,short-desc"); got != "hostname r1\nwelcome\n^C\ninterface Loopback0\n <collapsed>\ninterface Gi0\n description x\n\nend\n" {
+		t.Errorf("chain: unexpected output:\n%s", got)
+	}
+
+	// each run gets fresh block state
+	if got := run("short-desc,no-bgp"); got != "hostname r1\nbanner motd\n^C\nwelcome\n^C\ninterface Loopback0\n <collapsed>\ninterface Gi0\n description x\n\nend\n" {
+		t.Errorf("second chain: unexpected output:\n%s", got)
+	}
+
+	if _, missing := ft.get("noop,missing"); len(missing) != 1 || missing[0] != "missing" {
+		t.Errorf("missing filter not reported: %v", missing)
+	}
+
+	bad := [][]conf.FilterConfig{
+		{{Name: "f", Rules: []conf.FilterRule{{Action: conf.FilterDrop, Pattern: "x", End: "y"}}}},
+		{{Name: "f", Rules: []conf.FilterRule{{Action: conf.FilterDropBlock, Pattern: "x", End: "("}}}},
+		{{Name: "a,b"}},
+		{{Name: "cisco-certs"}},
+	}
+	for i, filters := range bad {
+		if err := ft.ReplaceCustomFilters(logger, filters); err == nil {
+			t.Errorf("bad filters #%d: expected error", i)
+		}
+	}
+}
+
+func TestIgnoreForComparison(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000}
+	ft := NewFilterTable(logger)
+
+	d, _ := tab.GetDevice("lab1")
+	d.attr.ChangesOnly = true
+	d.attr.SecretMask = ""
+
+	save := func(config, wantFile string) {
+		capture := dialog{save: [][]byte{[]byte(config)}}
+		if err := d.saveCommit(logger, &capture, repo, opt, ft); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		last, lastErr := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repo)), logger)
+		if lastErr != nil {
+			t.Fatalf("last config: %v", lastErr)
+		}
+		if want := DeviceFullPath(repo, "lab1", wantFile); last != want {
+			t.Errorf("last config: wanted=%s got=%s", want, last)
+		}
+	}
+
+	config1 := "cisco uptime is 1 week\n! Last configuration change at 10:00:01 UTC Mon Oct 19 2026\nhostname lab1\n"
+	config2 := "cisco uptime is 2 weeks\n! Last configuration change at 11:00:01 UTC Mon Oct 19 2026\nhostname lab1\n"
+	config3 := "cisco uptime is 2 weeks\n! Last configuration change at 11:00:01 UTC Mon Oct 19 2026\nhostname lab2\n"
+
+	save(config1, "lab1.0")
+	save(config2, "lab1.0") // only volatile lines changed
+	save(config3, "lab1.1")
+
+	full, readErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.1"), opt.MaxConfigLoadSize)
+	if readErr != nil {
+		t.Fatalf("read: %v", readErr)
+	}
+	if string(full) != config3 {
+		t.Errorf("saved file must keep volatile lines: wanted=%q got=%q", config3, full)
+	}
+
+	d.attr.IgnoreForComparison = []string{"("}
+	capture := dialog{save: [][]byte{[]byte(config1)}}
+	if err := d.saveCommit(logger, &capture, repo, opt, ft); err == nil {
+		t.Errorf("bad ignore pattern: expected error")
+	}
+}
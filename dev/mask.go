@@ -0,0 +1,176 @@
+package dev
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Secret masks replace secret values in saved configurations with stable hash tokens.
+// Every pattern captures the secret value in its first group.
+// Since the same secret always produces the same token, secret changes still show up in diffs.
+
+const maskTokenPrefix = "<masked:"
+
+// maskTokenPattern matches hash tokens produced by secret masking.
+var maskTokenPattern = regexp.MustCompile(`<masked:[0-9a-f]{12}>`)
+
+// Patterns shared by models with cisco-like configuration syntax.
+var maskCisco = []string{
+	`\b(?:secret|password)(?: encrypted)? (?:\d+ )?(\S+)`,                 // enable secret 5 $1$x, username u password 7 0822455D0A16
+	`^\s*snmp-server community (\S+)`,                                     // snmp-server community public RO
+	`\bsnmp-server user \S+ \S+ v3 auth \S+ (\S+)`,                        // snmp-server user u g v3 auth sha x priv ...
+	`\bpriv (?:des|3des|aes(?: \d+)?) (\S+)`,                              // ... priv aes 128 x
+	`\b(?:key-string|md5|pre-shared-key|isakmp key) (?:\d+ )?(\S+)`,       // ip ospf message-digest-key 1 md5 7 x
+	`\bauthentication-key (?:\d+ )?(?:md5 )?(\S+)`,                        // ip ospf authentication-key 7 x
+	`^\s*(?:tacacs-server|radius-server) key (?:\d+ )?(\S+)`,              // tacacs-server key 7 x
+	`^\s*(?:tacacs-server|radius-server) host \S+ .*\bkey (?:\d+ )?(\S+)`, // radius-server host h auth-port 1645 key x
+	`^\s+key [0-7] (\S+)`,                                                 // tacacs server block: key 7 x
+}
+
+// Patterns shared by models saving YANG data as JSON.
+var maskYANG = []string{
+	`"(?:[\w-]+:)?(?:password|secret|key|encrypted-password|password-hashed|auth-password|priv-password|pre-shared-key|community)"\s*:\s*"([^"]+)"`, // json: "openconfig-system:password-hashed": "$6$x"
+}
+
+func registerMasks(logger hasPrintf, masks map[string][]*regexp.Regexp) {
+	registerMask(logger, masks, "cisco-ios", maskCisco)
+	registerMask(logger, masks, "cisco-iosxr", maskCisco)
+	registerMask(logger, masks, "cisco-asa", append(append([]string{}, maskCisco...),
+		`^passwd (\S+)`,                         // passwd 2KFQnbNIdI.2KYOU encrypted
+		`\bsnmp-server host .* community (\S+)`, // snmp-server host inside 10.0.0.1 community public version 2c
+		`^\s+key (\S+)`,                         // aaa-server block: key x
+	))
+	registerMask(logger, masks, "dmswitch", maskCisco)
+	registerMask(logger, masks, "arista-eos", []string{
+		`\b(?:secret|password)(?: sha512| \d+)? (\S+)`,                             // username admin secret sha512 $6$x, enable password 7 x
+		`^\s*snmp-server community (\S+)`,                                          // snmp-server community public ro
+		`^\s*(?:tacacs-server|radius-server) (?:host \S+ .*\b)?key (?:\d+ )?(\S+)`, // tacacs-server key 7 x
+		`\b(?:message-digest-key \d+ md5|authentication-key) (?:\d+ )?(\S+)`,       // ip ospf authentication-key 7 x
+	})
+	registerMask(logger, masks, "junos", []string{
+		`"(\$\d+\$[^"]*)"`, // secret "$9$x"; encrypted-password "$6$x";
+		`\bcommunity "?([^"\s;{]+)"?\s*(?:\{|;|$|authorization|clients)`, // community public { / set snmp community public authorization read-only
+		`\bauthentication-key "?([^"\s;]+)`,                              // authentication-key x;
+		`\bpre-shared-key (?:ascii|hexadecimal)-text "?([^"\s;]+)`,       // pre-shared-key ascii-text x;
+	})
+	registerMask(logger, masks, "huawei-vrp", []string{
+		`\b(?:cipher|irreversible-cipher|simple) (\S+)`,           // local-user admin password irreversible-cipher x
+		`\bsnmp-agent community (?:read|write) (?:cipher )?(\S+)`, // snmp-agent community read cipher x
+		`\bpre-shared-key (?:cipher |simple )?(\S+)`,              // pre-shared-key cipher x
+	})
+	registerMask(logger, masks, "gnmi", maskYANG)
+	registerMask(logger, masks, "restconf", append(append([]string{}, maskYANG...),
+		`<(?:password|secret|key|encrypted-password|password-hashed|auth-password|priv-password|pre-shared-key|community)>([^<]+)<`, // xml: <password>x</password>
+	))
+	registerMask(logger, masks, "hpe-comware", []string{
+		`\b(?:password|key|pre-shared-key)(?: role \S+| authentication| accounting)? (?:cipher|simple|hash) (\S+)`, // local-user admin / password hash $h$6$x
+		`\bsnmp-agent community (?:read|write) (?:cipher |simple )?(\S+)`,                                          // snmp-agent community read simple public
+	})
+	registerMask(logger, masks, "aruba-cx", []string{
+		`\b(?:password|key|secret|auth-pass|priv-pass) (?:ciphertext|plaintext) (\S+)`, // user admin group administrators password ciphertext AQBx
+		`^\s*snmp-server community (\S+)`,                                              // snmp-server community public
+		`"(?:password|secret|key|auth_pass|priv_pass)"\s*:\s*"([^"]+)"`,                // json: "password": "AQBx"
+	})
+	registerMask(logger, masks, "f5-bigip", []string{
+		`\b(?:encrypted-password|password|secret|passphrase|auth-password|privacy-password|bind-pw|community-name) ("[^"]*"|\S+)`, // encrypted-password $6$x, secret $M$x
+	})
+	registerMask(logger, masks, "nokia-sros", []string{
+		`\b(?:password|authentication-key|secret|pre-shared-key|community|auth-key|priv-key|md5-key|hash-key)\s+("[^"]*"|\S+)`, // password "x" hash2, community "public"
+	})
+	registerMask(logger, masks, "vyos", []string{
+		`\b(?:plaintext-password|encrypted-password|pre-shared-secret|secret|key|password|community|authentication-key|md5-key) ('[^']*'|"[^"]*"|\S+)`, // set system login user vyos authentication encrypted-password '$6$x'
+	})
+	registerMask(logger, masks, "fortios", []string{
+		`^\s*set (?:password|passwd|psksecret|secret|key|private-key|passphrase|auth-pwd|priv-pwd|pre-shared-key|sso-password) (?:ENC )?("[^"]*"|\S+)`, // set password ENC x
+	})
+	registerMask(logger, masks, "mikrotik", []string{
+		`\b(?:password|secret|passphrase|key|authentication-key|authentication-password|encryption-password|wpa-pre-shared-key|wpa2-pre-shared-key|pre-shared-key)=("[^"]*"|\S+)`, // /ppp secret add name=u password=x
+	})
+}
+
+func registerMask(logger hasPrintf, masks map[string][]*regexp.Regexp, name string, patterns []string) {
+	logger.Printf("secret mask registered: '%s'", name)
+	list := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		list[i] = regexp.MustCompile(p)
+	}
+	masks[name] = list
+}
+
+// secretMask replaces secrets in configuration lines with hash tokens.
+type secretMask struct {
+	patterns []*regexp.Regexp
+	key      []byte
+}
+
+// mask gets the secret mask for the device, if any.
+// The mask key might be a secret reference. An empty key is refused, since unkeyed tokens could be brute-forced.
+func (d *Device) mask(ft *FilterTable, key string, secretTTL time.Duration) (*secretMask, error) {
+	if d.attr.SecretMask == "" {
+		return nil, nil // masking disabled
+	}
+
+	patterns, found := ft.masks[d.attr.SecretMask]
+	if !found {
+		return nil, fmt.Errorf("secret mask '%s' not found", d.attr.SecretMask)
+	}
+
+	k, keyErr := secrets.get(d.ID, key, secretTTL)
+	if keyErr != nil {
+		return nil, fmt.Errorf("secret mask key: %v", keyErr)
+	}
+	if k == "" {
+		return nil, fmt.Errorf("secret mask '%s': empty secretmaskkey: refusing to save unkeyed tokens", d.attr.SecretMask)
+	}
+
+	return &secretMask{patterns: patterns, key: []byte(k)}, nil
+}
+
+// token builds the stable hash token for a secret value.
+func (m *secretMask) token(secret []byte) []byte {
+	h := hmac.New(sha256.New, m.key)
+	h.Write(secret)
+	return []byte(maskTokenPrefix + hex.EncodeToString(h.Sum(nil))[:12] + ">")
+}
+
+// line replaces every secret found in a configuration line.
+func (m *secretMask) line(line []byte) []byte {
+	for _, re := range m.patterns {
+		matches := re.FindAllSubmatchIndex(line, -1)
+		if matches == nil {
+			continue
+		}
+		var masked []byte
+		last := 0
+		for _, loc := range matches {
+			begin, end := loc[2], loc[3]
+			if begin < 0 || begin == end || maskTokenPattern.Match(line[begin:end]) {
+				continue // no value, or already masked
+			}
+			masked = append(masked, line[last:begin]...)
+			masked = append(masked, m.token(line[begin:end])...)
+			last = end
+		}
+		line = append(masked, line[last:]...)
+	}
+	return line
+}
+
+// text replaces every secret found in a multi-line text.
+func (m *secretMask) text(b []byte) []byte {
+	lines := bytes.Split(b, []byte{'\n'})
+	for i, l := range lines {
+		lines[i] = m.line(l)
+	}
+	return bytes.Join(lines, []byte{'\n'})
+}
+
+// hasMaskedSecrets checks whether a saved configuration holds hash tokens from secret masking.
+func hasMaskedSecrets(config []byte) bool {
+	return maskTokenPattern.Match(config)
+}
@@ -0,0 +1,182 @@
+package dev
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestMaskLines(t *testing.T) {
+	logger := &testLogger{t}
+	ft := NewFilterTable(logger)
+
+	table := []struct {
+		mask   string
+		line   string
+		secret string // must vanish; empty means line unchanged
+	}{
+		{"cisco-ios", "enable secret 5 $1$mERr$hx5rVt7rPNoS4wqbXKX7m0", "$1$mERr$hx5rVt7rPNoS4wqbXKX7m0"},
+		{"cisco-ios", "username admin privilege 15 password 7 0822455D0A16", "0822455D0A16"},
+		{"cisco-ios", " password 7 094F471A1A0A", "094F471A1A0A"},
+		{"cisco-ios", "snmp-server community public RO", "public"},
+		{"cisco-ios", "crypto isakmp key s3cr3t address 10.0.0.1", "s3cr3t"},
+		{"cisco-ios", "tacacs-server key 7 1511021F0725", "1511021F0725"},
+		{"cisco-ios", "service password-encryption", ""},
+		{"cisco-ios", "key chain KC", ""},
+		{"cisco-iosxr", "username u secret 10 $6$abc$def", "$6$abc$def"},
+		{"dmswitch", "snmp-server community private rw", "private"},
+		{"arista-eos", "username admin privilege 15 role network-admin secret sha512 $6$abc$def", "$6$abc$def"},
+		{"arista-eos", "enable password 7 0822455D0A16", "0822455D0A16"},
+		{"junos", `            encrypted-password "$6$x9y$abcdefgh"; ## SECRET-DATA`, "$6$x9y$abcdefgh"},
+		{"junos", `    secret "$9$kPfz6CuRhrlKv"; ## SECRET-DATA`, "$9$kPfz6CuRhrlKv"},
+		{"junos", "    community public {", "public"},
+		{"junos", "set snmp community public authorization read-only", "public"},
+		{"junos", "    community MYCOMM members 65000:100;", ""},
+		{"huawei-vrp", " local-user admin password irreversible-cipher $1a$abc$", "$1a$abc$"},
+		{"huawei-vrp", " snmp-agent community read cipher %^%#xyz%^%#", "%^%#xyz%^%#"},
+		{"fortios", "        set password ENC SH2abcdef", "SH2abcdef"},
+		{"fortios", `        set psksecret ENC "a b c"`, `"a b c"`},
+		{"gnmi", `            "openconfig-system:password-hashed": "$6$abc$def",`, "$6$abc$def"},
+		{"gnmi", `      "hostname": "srl1"`, ""},
+		{"restconf", `          "secret": "$9$abc$def"`, "$9$abc$def"},
+		{"restconf", `    <password>s3cr3t</password>`, "s3cr3t"},
+		{"hpe-comware", " password hash $h$6$abc$def", "$h$6$abc$def"},
+		{"hpe-comware", " super password role network-admin simple s3cr3t", "s3cr3t"},
+		{"hpe-comware", " key authentication cipher $c$3$xyz", "$c$3$xyz"},
+		{"hpe-comware", " snmp-agent community read simple public", "public"},
+		{"hpe-comware", " sysname core1", ""},
+		{"aruba-cx", "user admin group administrators password ciphertext AQBapXyz", "AQBapXyz"},
+		{"aruba-cx", "radius-server host 10.0.0.1 key ciphertext AQBaKey", "AQBaKey"},
+		{"aruba-cx", "snmp-server community public", "public"},
+		{"aruba-cx", `        "password": "AQBapJson",`, "AQBapJson"},
+		{"mikrotik", "/ppp secret add name=u1 password=pw1 service=pptp", "pw1"},
+		{"vyos", "set system login user vyos authentication encrypted-password '$6$abc$def'", "$6$abc$def"},
+		{"vyos", "                encrypted-password $6$abc$def", "$6$abc$def"},
+		{"vyos", "set vpn ipsec site-to-site peer 10.0.0.1 authentication pre-shared-secret 's3cr3t'", "s3cr3t"},
+		{"vyos", "set service snmp community public authorization 'ro'", "public"},
+		{"vyos", "set system host-name 'vyos'", ""},
+	}
+
+	for _, data := range table {
+		m := &secretMask{patterns: ft.masks[data.mask], key: []byte("key")}
+		if len(m.patterns) < 1 {
+			t.Fatalf("mask not found: %s", data.mask)
+		}
+		got := string(m.line([]byte(data.line)))
+		if data.secret == "" {
+			if got != data.line {
+				t.Errorf("%s: line changed: [%s] => [%s]", data.mask, data.line, got)
+			}
+			continue
+		}
+		if strings.Contains(got, data.secret) || !hasMaskedSecrets([]byte(got)) {
+			t.Errorf("%s: secret not masked: [%s] => [%s]", data.mask, data.line, got)
+		}
+		if again := string(m.line([]byte(data.line))); again != got {
+			t.Errorf("%s: unstable token: [%s] != [%s]", data.mask, got, again)
+		}
+		if masked := string(m.line([]byte(got))); masked != got {
+			t.Errorf("%s: masked twice: [%s] => [%s]", data.mask, got, masked)
+		}
+	}
+
+	m := &secretMask{patterns: ft.masks["cisco-ios"], key: []byte("key")}
+	if string(m.token([]byte("a"))) == string(m.token([]byte("b"))) {
+		t.Errorf("different secrets produced same token")
+	}
+}
+
+func TestMaskSave(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	unmaskedPath := filepath.Join(repo, "unmasked")
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key", UnmaskedPath: unmaskedPath}
+	ft := NewFilterTable(logger)
+
+	d, _ := tab.GetDevice("lab1")
+	d.attr.SecretMask = "cisco-ios" // masking is opt-in
+	config := "hostname lab1\nenable secret 5 $1$abc\nsnmp-server community public RO\nend"
+	capture := dialog{save: [][]byte{[]byte(config)}}
+
+	if err := d.saveCommit(logger, &capture, repo, opt, ft); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	masked, readErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), opt.MaxConfigLoadSize)
+	if readErr != nil {
+		t.Fatalf("read masked: %v", readErr)
+	}
+	if strings.Contains(string(masked), "$1$abc") || strings.Contains(string(masked), "public") {
+		t.Errorf("secrets leaked into repository: %s", masked)
+	}
+	if !strings.HasPrefix(string(masked), "hostname lab1\n") || !strings.HasSuffix(string(masked), "\nend") {
+		t.Errorf("unexpected masked config: %q", masked)
+	}
+
+	unmaskedFile := DeviceFullPath(unmaskedPath, "lab1", "lab1.0")
+	unmasked, unmaskedErr := store.FileRead(unmaskedFile, opt.MaxConfigLoadSize)
+	if unmaskedErr != nil {
+		t.Fatalf("read unmasked: %v", unmaskedErr)
+	}
+	if string(unmasked) != config {
+		t.Errorf("unmasked copy: wanted=%q got=%q", config, unmasked)
+	}
+	info, statErr := os.Stat(unmaskedFile)
+	if statErr != nil {
+		t.Errorf("unmasked copy: %v", statErr)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("unmasked copy: bad permissions: %v", info.Mode())
+	}
+
+	// compliance sees secrets through unmasked copy, without exposing them
+	policies := []conf.Policy{{Name: "snmp", Rules: []conf.Rule{{Name: "public", MustNotMatch: []string{`^snmp-server community public`}}}}}
+	if err := tab.SetPolicies(policies); err != nil {
+		t.Fatalf("policies: %v", err)
+	}
+	report, compErr := tab.CheckCompliance("lab1", repo, opt, logger)
+	if compErr != nil {
+		t.Fatalf("compliance: %v", compErr)
+	}
+	if report.Pass || report.Results[0].Msg != "forbidden line 3" {
+		t.Errorf("compliance: pass=%v msg=%q", report.Pass, report.Results[0].Msg)
+	}
+
+	// missing key must not save unkeyed tokens
+	keyless := *opt
+	keyless.SecretMaskKey = ""
+	if err := d.saveCommit(logger, &capture, repo, &keyless, ft); err == nil {
+		t.Errorf("empty mask key: expected error")
+	}
+
+	// unknown mask must not save secrets in clear
+	d.attr.SecretMask = "unknown"
+	if err := d.saveCommit(logger, &capture, repo, opt, ft); err == nil {
+		t.Errorf("unknown mask: expected error")
+	}
+}
+
+// enableMask turns on the secret mask for a device in the table, since masking is opt-in.
+func enableMask(t *testing.T, tab *DeviceTable, id, mask string) {
+	d, getErr := tab.GetDevice(id)
+	if getErr != nil {
+		t.Fatalf("enableMask: %v", getErr)
+	}
+	d.DevConfig.Attr = conf.AttrOverrides{"secretmask": mask}
+	if err := d.refreshAttr(); err != nil {
+		t.Fatalf("enableMask: %v", err)
+	}
+	if err := tab.UpdateDevice(d); err != nil {
+		t.Fatalf("enableMask: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/udhos/jazigo/conf"
@@ -14,8 +15,14 @@ import (
 
 // Model provides default attributes for model of devices.
 type Model struct {
-	name        string
-	defaultAttr conf.DevAttributes
+	name         string
+	defaultAttr  conf.DevAttributes
+	custom       bool              // user-defined model loaded at runtime
+	fingerprints []string          // patterns identifying the model in probe output, see autodetection
+	facts        map[string]string // fact name => pattern capturing fact value from command output
+	apiFetch     apiFetchFunc      // fetch through device API instead of CLI chat
+	apiFallback  bool              // fall back to CLI chat when device API is unreachable
+	artifacts    artifactFetchFunc // fetch binary artifacts after CLI chat
 }
 
 // Device is an specific device.
@@ -24,15 +31,21 @@ type Device struct {
 
 	logger      hasPrintf
 	devModel    *Model
-	lastStatus  bool // true=good false=bad
+	attr        conf.DevAttributes // effective attributes: model defaults + profiles + device overrides
+	attrOrigin  map[string]string  // attribute => origin of effective value
+	profiles    []conf.Profile     // profiles resolved from DevConfig.Profiles
+	lastStatus  bool               // true=good false=bad
 	lastTry     time.Time
 	lastSuccess time.Time
 	lastElapsed time.Duration
+	failures    int       // consecutive failed attempts
+	lastFailure time.Time // last failed attempt
+	quarantined bool      // excluded from scans until released
 }
 
 // Username gets the username for login into a device.
 func (d *Device) Username() string {
-	return d.DevConfig.LoginUser + d.DevConfig.Attr.UsernameAppend
+	return d.DevConfig.LoginUser + d.attr.UsernameAppend
 }
 
 // Printf formats device-specific messages into logs.
@@ -46,6 +59,106 @@ func (d *Device) Model() string {
 	return d.devModel.name
 }
 
+// Attributes gets the effective device attributes.
+func (d *Device) Attributes() conf.DevAttributes {
+	return d.attr
+}
+
+// AttributesOrigin gets the origin (model, profile, device) for every effective attribute.
+func (d *Device) AttributesOrigin() map[string]string {
+	return d.attrOrigin
+}
+
+// HasProfile checks whether the device uses a profile.
+func (d *Device) HasProfile(name string) bool {
+	for _, p := range d.DevConfig.Profiles {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Device) profileLayers() []conf.AttrLayer {
+	layers := make([]conf.AttrLayer, 0, len(d.profiles)+1)
+	for _, p := range d.profiles {
+		layers = append(layers, conf.AttrLayer{Origin: conf.ProfileOrigin(p.Name), Overrides: p.Attr})
+	}
+	return layers
+}
+
+// profileBase merges model defaults with profile overrides.
+func (d *Device) profileBase() (conf.DevAttributes, map[string]string, error) {
+	return conf.MergeAttr(d.devModel.defaultAttr, d.profileLayers()...)
+}
+
+// refreshAttr recomputes effective attributes as model defaults + profiles + device overrides.
+func (d *Device) refreshAttr() error {
+	layers := append(d.profileLayers(), conf.AttrLayer{Origin: conf.OriginDevice, Overrides: d.DevConfig.Attr})
+	a, origins, err := conf.MergeAttr(d.devModel.defaultAttr, layers...)
+	if err != nil {
+		return err
+	}
+	if schedErr := ValidateSchedule(a.Schedule, a.ScheduleTimezone); schedErr != nil {
+		return schedErr
+	}
+	d.attr = a
+	d.attrOrigin = origins
+	return nil
+}
+
+// refresh picks up current model and profiles from the device table, then recomputes effective attributes.
+func (d *Device) refresh(tab DeviceUpdater) error {
+	mod, modErr := tab.GetModel(d.DevConfig.Model)
+	if modErr != nil {
+		return fmt.Errorf("model '%s': %v", d.DevConfig.Model, modErr)
+	}
+	profiles := make([]conf.Profile, 0, len(d.DevConfig.Profiles))
+	for _, name := range d.DevConfig.Profiles {
+		p, profErr := tab.GetProfile(name)
+		if profErr != nil {
+			return fmt.Errorf("profile '%s': %v", name, profErr)
+		}
+		profiles = append(profiles, *p)
+	}
+	d.devModel = mod
+	d.profiles = profiles
+	return d.refreshAttr()
+}
+
+// applyProfileCredentials fills in credentials missing from device with values from profiles.
+// The last profile providing a credential wins.
+func (d *Device) applyProfileCredentials() {
+	fill := func(dst *string, pick func(p *conf.Profile) string) {
+		if *dst != "" {
+			return // device value takes precedence
+		}
+		for i := len(d.profiles) - 1; i >= 0; i-- {
+			if v := pick(&d.profiles[i]); v != "" {
+				*dst = v
+				return
+			}
+		}
+	}
+	fill(&d.LoginUser, func(p *conf.Profile) string { return p.LoginUser })
+	fill(&d.LoginPassword, func(p *conf.Profile) string { return p.LoginPassword })
+	fill(&d.EnablePassword, func(p *conf.Profile) string { return p.EnablePassword })
+	fill(&d.SSHKeyFile, func(p *conf.Profile) string { return p.SSHKeyFile })
+	fill(&d.SSHKeyPassphrase, func(p *conf.Profile) string { return p.SSHKeyPassphrase })
+}
+
+// SetDevConfig replaces device properties, recomputing effective attributes.
+// On error, the device is left unchanged.
+func (d *Device) SetDevConfig(tab DeviceUpdater, cfg *conf.DevConfig) error {
+	d1 := *d
+	d1.DevConfig = *cfg
+	if err := d1.refresh(tab); err != nil {
+		return fmt.Errorf("SetDevConfig: %v", err)
+	}
+	*d = d1
+	return nil
+}
+
 // LastStatus gets a status string for last configuration backup.
 func (d *Device) LastStatus() bool {
 	return d.lastStatus
@@ -73,18 +186,30 @@ func (d *Device) Holdtime(now time.Time, holdtime time.Duration) time.Duration {
 
 // RegisterModels adds known device models.
 func RegisterModels(logger hasPrintf, t *DeviceTable) {
+	registerModelAuto(logger, t)
+	registerModelAristaEOS(logger, t)
+	registerModelArubaCX(logger, t)
 	registerModelCiscoNGA(logger, t)
 	registerModelCiscoAPIC(logger, t)
+	registerModelCiscoASA(logger, t)
 	registerModelCiscoIOS(logger, t)
 	registerModelCiscoIOSXR(logger, t)
 	registerModelDatacomDmswitch(logger, t)
+	registerModelF5BigIP(logger, t)
 	registerModelFortiOS(logger, t)
+	registerModelGNMI(logger, t)
+	registerModelHPEComware(logger, t)
 	registerModelHTTP(logger, t)
 	registerModelHuaweiVRP(logger, t)
 	registerModelJunOS(logger, t)
 	registerModelLinux(logger, t)
 	registerModelMikrotik(logger, t)
+	registerModelNokiaSROS(logger, t)
+	registerModelNokiaSROSMD(logger, t)
+	registerModelPANOS(logger, t)
+	registerModelRESTCONF(logger, t)
 	registerModelRun(logger, t)
+	registerModelVyOS(logger, t)
 }
 
 // CreateDevice creates a new device in the device table.
@@ -98,7 +223,12 @@ func CreateDevice(tab *DeviceTable, logger hasPrintf, modelName, id, hostPort, t
 		return err
 	}
 
-	d := NewDevice(logger, mod, id, hostPort, transports, user, pass, enable, debug)
+	d, newErr := NewDevice(logger, mod, id, hostPort, transports, user, pass, enable, debug)
+	if newErr != nil {
+		err := fmt.Errorf("CreateDevice: %v", newErr)
+		logger.Printf(err.Error())
+		return err
+	}
 
 	if change != nil {
 		d.LastChange = *change
@@ -114,20 +244,44 @@ func CreateDevice(tab *DeviceTable, logger hasPrintf, modelName, id, hostPort, t
 }
 
 // NewDeviceFromConf creates a new device from a DevConfig.
-func NewDeviceFromConf(tab *DeviceTable, logger hasPrintf, cfg *conf.DevConfig) (*Device, error) {
-	mod, getErr := tab.GetModel(cfg.Model)
-	if getErr != nil {
-		return nil, fmt.Errorf("NewDeviceFromConf: could not find model '%s': %v", cfg.Model, getErr)
+// Attribute overrides matching model defaults are discarded, then the bool result reports
+// whether the device config was changed by such a migration.
+func NewDeviceFromConf(tab *DeviceTable, logger hasPrintf, cfg *conf.DevConfig) (*Device, bool, error) {
+	d := &Device{logger: logger, DevConfig: *cfg}
+
+	if err := d.refresh(tab); err != nil {
+		return nil, false, fmt.Errorf("NewDeviceFromConf: device '%s': %v", cfg.ID, err)
 	}
-	d := &Device{logger: logger, devModel: mod, DevConfig: *cfg}
-	return d, nil
+
+	base, _, baseErr := d.profileBase()
+	if baseErr != nil {
+		return nil, false, fmt.Errorf("NewDeviceFromConf: device '%s': %v", cfg.ID, baseErr)
+	}
+
+	minimal, minErr := conf.MinimizeOverrides(base, cfg.Attr)
+	if minErr != nil {
+		return nil, false, fmt.Errorf("NewDeviceFromConf: device '%s': %v", cfg.ID, minErr)
+	}
+	migrated := len(minimal) != len(cfg.Attr)
+	if migrated {
+		logger.Printf("NewDeviceFromConf: device '%s': attribute overrides reduced from %d to %d", cfg.ID, len(cfg.Attr), len(minimal))
+	}
+	d.Attr = minimal
+
+	if err := d.refreshAttr(); err != nil {
+		return nil, false, fmt.Errorf("NewDeviceFromConf: device '%s': %v", cfg.ID, err)
+	}
+
+	return d, migrated, nil
 }
 
 // NewDevice creates a new device.
-func NewDevice(logger hasPrintf, mod *Model, id, hostPort, transports, loginUser, loginPassword, enablePassword string, debug bool) *Device {
+func NewDevice(logger hasPrintf, mod *Model, id, hostPort, transports, loginUser, loginPassword, enablePassword string, debug bool) (*Device, error) {
 	d := &Device{logger: logger, devModel: mod, DevConfig: conf.DevConfig{Model: mod.name, ID: id, HostPort: hostPort, Transports: transports, LoginUser: loginUser, LoginPassword: loginPassword, EnablePassword: enablePassword, Debug: debug}}
-	d.Attr = mod.defaultAttr
-	return d
+	if err := d.refreshAttr(); err != nil {
+		return nil, fmt.Errorf("NewDevice: device '%s': %v", id, err)
+	}
+	return d, nil
 }
 
 const (
@@ -139,12 +293,17 @@ const (
 	fetchErrPager    = 5
 	fetchErrCommands = 6
 	fetchErrSave     = 7
+	fetchErrSecret   = 8
+	fetchErrAttr     = 9
+	fetchErrDetect   = 10
+	fetchErrArtifact = 11
 )
 
 // FetchRequest is a request for fetching a device configuration.
 type FetchRequest struct {
 	ID        string           // fetch this device
 	ReplyChan chan FetchResult // reply on this channel
+	Pool      string           // concurrency pool slot already acquired by requester, released after fetch
 }
 
 // FetchResult reports the result for fetching a device configuration.
@@ -153,6 +312,7 @@ type FetchResult struct {
 	DevID       string
 	DevHostPort string
 	Transport   string
+	Detected    string    // model found by autodetection
 	Msg         string    // result error message
 	Code        int       // result error code
 	Begin       time.Time // begin timestamp
@@ -164,22 +324,45 @@ type hasPrintf interface {
 }
 
 type dialog struct {
-	save [][]byte
+	save      [][]byte
+	banner    []byte         // output received before login, not saved
+	contexts  []namedCapture // output captured within security contexts, saved apart
+	outputs   []namedCapture // output from separate commands, saved apart
+	artifacts []artifact     // binary files fetched along with output, saved apart
 }
 
 // Fetch captures a configuration for a device.
 // Fetch runs in a per-device goroutine.
 func (d *Device) Fetch(tab DeviceUpdater, logger hasPrintf, resultCh chan FetchResult, delay time.Duration, repository, logPathPrefix string, opt *conf.AppConfig, ft *FilterTable) {
 
-	result := d.fetch(logger, delay, repository, opt.MaxConfigFiles, ft)
+	var result FetchResult
+
+	// pick up current model defaults and profiles, which might have been reloaded
+	if attrErr := d.refresh(tab); attrErr != nil {
+		now := time.Now()
+		result = FetchResult{Model: d.devModel.name, DevID: d.ID, DevHostPort: d.HostPort, Transport: d.Transports, Msg: fmt.Sprintf("fetch attributes: %v", attrErr), Code: fetchErrAttr, Begin: now}
+	} else if d.devModel.name == autoModel {
+		result = d.autodetect(tab, logger, delay, repository, opt, ft)
+	} else {
+		result = d.fetch(logger, delay, repository, opt, ft)
+	}
 
 	result.End = time.Now()
 
 	good := result.Code == fetchErrNone
 
-	updateDeviceStatus(tab, d.ID, good, result.End, result.End.Sub(result.Begin), logger, opt.Holdtime)
+	updateDeviceStatus(tab, d.ID, good, result.End, result.End.Sub(result.Begin), logger, opt, logPathPrefix)
+
+	if good {
+		if _, compErr := tab.CheckCompliance(d.ID, repository, opt, logger); compErr != nil {
+			logger.Printf("fetch: %s: compliance: %v", d.ID, compErr)
+		}
+		if _, factsErr := tab.LoadFacts(d.ID, repository, opt, logger); factsErr != nil {
+			logger.Printf("fetch: %s: facts: %v", d.ID, factsErr)
+		}
+	}
 
-	errlog(logger, result, logPathPrefix, d.Debug, d.Attr.ErrlogHistSize)
+	errlog(logger, result, logPathPrefix, d.Debug, d.attr.ErrlogHistSize)
 
 	if resultCh != nil {
 		resultCh <- result
@@ -190,43 +373,43 @@ func (d *Device) createTransport(logger hasPrintf) (transp, string, bool, error)
 	modelName := d.devModel.name
 
 	if modelName == "run" {
-		d.debugf("createTransport: %q", d.Attr.RunProg)
+		d.debugf("createTransport: %q", d.attr.RunProg)
 		return openTransportPipe(logger, modelName, d.ID, d.HostPort, d.Transports, d.LoginUser,
-			d.LoginPassword, d.Attr.RunProg, d.Debug, d.Attr.RunTimeout)
+			d.LoginPassword, d.attr.RunProg, d.Debug, d.attr.RunTimeout)
 	}
 
 	return openTransport(logger, modelName, d.ID, d.HostPort, d.Transports, d.Username(),
-		d.LoginPassword, d.DevConfig.SSHClearCiphers, d.DevConfig.SSHAddCiphers)
+		d.LoginPassword, d.DevConfig.SSHKeyFile, d.DevConfig.SSHKeyPassphrase, d.DevConfig.SSHClearCiphers, d.DevConfig.SSHAddCiphers)
 }
 
-func (d *Device) fetch(logger hasPrintf, delay time.Duration, repository string, maxFiles int, ft *FilterTable) FetchResult {
+// connect opens a session to the device, then performs login and enable.
+// On error, it returns the fetch error code.
+func (d *Device) connect(logger hasPrintf, capture *dialog, secretTTL time.Duration) (transp, string, bool, int, error) {
 	modelName := d.devModel.name
 
-	if delay > 0 {
-		time.Sleep(delay)
-	}
+	// d is a private copy: profile credentials and resolved secrets do not leak into device table
+	d.applyProfileCredentials()
 
-	begin := time.Now()
+	if secretErr := d.resolveSecrets(secretTTL); secretErr != nil {
+		return nil, d.Transports, false, fetchErrSecret, fmt.Errorf("fetch secret: %v", secretErr)
+	}
 
 	session, transport, logged, err := d.createTransport(logger)
 	if err != nil {
-		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("fetch transport: %v", err), Code: fetchErrTransp, Begin: begin}
+		return nil, transport, false, fetchErrTransp, fmt.Errorf("fetch transport: %v", err)
 	}
 
-	defer session.Close()
-
 	logger.Printf("fetch: %s %s %s - transport OPEN logged=%v", modelName, d.ID, d.HostPort, logged)
 
-	capture := dialog{}
-
 	enabled := false
 
 	d.debugf("will login")
 
-	if d.Attr.NeedLoginChat && !logged {
-		e, loginErr := d.login(logger, session, &capture)
+	if d.attr.NeedLoginChat && !logged {
+		e, loginErr := d.login(logger, session, capture)
 		if loginErr != nil {
-			return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("fetch login: %v", loginErr), Code: fetchErrLogin, Begin: begin}
+			session.Close()
+			return nil, transport, false, fetchErrLogin, fmt.Errorf("fetch login: %v", loginErr)
 		}
 		if e {
 			enabled = true
@@ -235,17 +418,49 @@ func (d *Device) fetch(logger hasPrintf, delay time.Duration, repository string,
 
 	d.debugf("will enable")
 
-	if d.Attr.NeedEnabledMode && !enabled {
-		enableErr := d.enable(logger, session, &capture)
+	if d.attr.NeedEnabledMode && !enabled {
+		enableErr := d.enable(logger, session, capture)
 		if enableErr != nil {
 			d.debugf("enable failed")
-			return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("fetch enable: %v", enableErr), Code: fetchErrEnable, Begin: begin}
+			session.Close()
+			return nil, transport, false, fetchErrEnable, fmt.Errorf("fetch enable: %v", enableErr)
 		}
+		enabled = true
 	}
 
-	d.debugf("will disable paging: %v pattern=[%s]", d.Attr.NeedPagingOff, d.Attr.DisablePagerCommand)
+	return session, transport, enabled, fetchErrNone, nil
+}
 
-	if d.Attr.NeedPagingOff {
+func (d *Device) fetch(logger hasPrintf, delay time.Duration, repository string, opt *conf.AppConfig, ft *FilterTable) FetchResult {
+	modelName := d.devModel.name
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	begin := time.Now()
+
+	if d.devModel.apiFetch != nil {
+		api := *d // keep credentials unresolved for CLI fallback
+		result := api.fetchAPI(logger, begin, repository, opt, ft)
+		if result.Code != fetchErrTransp || !d.devModel.apiFallback {
+			return result
+		}
+		logger.Printf("fetch: %s %s %s - API unreachable, falling back to CLI: %s", modelName, d.ID, d.HostPort, result.Msg)
+	}
+
+	capture := dialog{}
+
+	session, transport, _, code, err := d.connect(logger, &capture, opt.SecretCacheTTL)
+	if err != nil {
+		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: err.Error(), Code: code, Begin: begin}
+	}
+
+	defer session.Close()
+
+	d.debugf("will disable paging: %v pattern=[%s]", d.attr.NeedPagingOff, d.attr.DisablePagerCommand)
+
+	if d.attr.NeedPagingOff {
 		pagingErr := d.pagingOff(logger, session, &capture)
 		if pagingErr != nil {
 			return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("fetch pager off: %v", pagingErr), Code: fetchErrPager, Begin: begin}
@@ -259,9 +474,36 @@ func (d *Device) fetch(logger hasPrintf, delay time.Duration, repository string,
 		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("commands: %v", cmdErr), Code: fetchErrCommands, Begin: begin}
 	}
 
+	if d.attr.ContextListCommand != "" {
+		d.debugf("will fetch contexts")
+
+		if ctxErr := d.fetchContexts(logger, session, &capture); ctxErr != nil {
+			d.saveRollback(logger, &capture)
+			return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("contexts: %v", ctxErr), Code: fetchErrCommands, Begin: begin}
+		}
+	}
+
+	if len(d.attr.SeparateCommands) > 0 {
+		d.debugf("will fetch separate outputs")
+
+		if outErr := d.fetchSeparateOutputs(logger, session, &capture); outErr != nil {
+			d.saveRollback(logger, &capture)
+			return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("separate outputs: %v", outErr), Code: fetchErrCommands, Begin: begin}
+		}
+	}
+
+	if d.devModel.artifacts != nil {
+		d.debugf("will fetch artifacts")
+
+		if artErr := d.devModel.artifacts(d, logger, &capture); artErr != nil {
+			d.saveRollback(logger, &capture)
+			return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("artifacts: %v", artErr), Code: fetchErrArtifact, Begin: begin}
+		}
+	}
+
 	d.debugf("will save results")
 
-	if saveErr := d.saveCommit(logger, &capture, repository, maxFiles, ft); saveErr != nil {
+	if saveErr := d.saveCommit(logger, &capture, repository, opt, ft); saveErr != nil {
 		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("save commit: %v", saveErr), Code: fetchErrSave, Begin: begin}
 	}
 
@@ -270,6 +512,9 @@ func (d *Device) fetch(logger hasPrintf, delay time.Duration, repository string,
 
 func (d *Device) saveRollback(logger hasPrintf, capture *dialog) {
 	capture.save = nil
+	capture.contexts = nil
+	capture.outputs = nil
+	capture.artifacts = nil
 }
 
 func deviceDirectory(repository, id string) string {
@@ -296,7 +541,7 @@ func (d *Device) DevicePathPrefix(devDir string) string {
 	return filepath.Join(devDir, d.ID+".")
 }
 
-func (d *Device) saveCommit(logger hasPrintf, capture *dialog, repository string, maxFiles int, ft *FilterTable) error {
+func (d *Device) saveCommit(logger hasPrintf, capture *dialog, repository string, opt *conf.AppConfig, ft *FilterTable) error {
 
 	devDir := d.DeviceDir(repository)
 
@@ -306,16 +551,84 @@ func (d *Device) saveCommit(logger hasPrintf, capture *dialog, repository string
 
 	devPathPrefix := d.DevicePathPrefix(devDir)
 
-	// writeFunc: copy command outputs into file
-	writeFunc := func(w store.HasWrite) error {
+	mask, maskErr := d.mask(ft, opt.SecretMaskKey, opt.SecretCacheTTL)
+	if maskErr != nil {
+		return fmt.Errorf("saveCommit: %v", maskErr)
+	}
+
+	ignore, ignoreErr := CompileIgnorePatterns(d.attr.IgnoreForComparison)
+	if ignoreErr != nil {
+		return fmt.Errorf("saveCommit: %v", ignoreErr)
+	}
+
+	writeFunc := d.captureWriter(capture, ft, mask)
 
-		lineFilter, filterFound := ft.table[d.Attr.LineFilter]
+	changesOnly := d.attr.ChangesOnly
+	for _, g := range d.namedGroups(capture, repository) {
+		if changesOnly && d.namedChanged(logger, g.list, g.prefix, repository, ft, mask, ignoreLines(ignore), opt.MaxConfigLoadSize) {
+			changesOnly = false // any changed context or separate output requires new version
+		}
+	}
+
+	path, writeErr := store.SaveNewConfigNormalized(devPathPrefix, opt.MaxConfigFiles, logger, writeFunc, changesOnly, d.attr.S3ContentType, ignoreLines(ignore), opt.MaxConfigLoadSize)
+	if writeErr != nil {
+		return fmt.Errorf("saveCommit: error: %v", writeErr)
+	}
+
+	logger.Printf("saveCommit: dev '%s' saved to '%s'", d.ID, path)
+
+	for _, g := range d.namedGroups(capture, repository) {
+		if namedErr := d.saveNamed(logger, g.list, g.prefix, path, opt.MaxConfigFiles, ft, mask); namedErr != nil {
+			return fmt.Errorf("saveCommit: %v", namedErr)
+		}
+	}
+
+	if artErr := d.saveArtifacts(logger, capture, path, repository, opt.MaxConfigFiles); artErr != nil {
+		return fmt.Errorf("saveCommit: %v", artErr)
+	}
+
+	if factsErr := d.saveFacts(logger, capture, path, repository, opt); factsErr != nil {
+		logger.Printf("saveCommit: dev '%s': %v", d.ID, factsErr) // facts are not worth failing the backup
+	}
+
+	if mask == nil || opt.UnmaskedPath == "" {
+		return nil
+	}
+
+	// keep unmasked copy under the same file name
+	id, idErr := store.ExtractCommitIDFromFilename(path)
+	if idErr != nil {
+		return fmt.Errorf("saveCommit: unmasked copy: %v", idErr)
+	}
+
+	copyPath, copyErr := store.SaveConfigCopy(DeviceFullPrefix(opt.UnmaskedPath, d.ID), id, opt.MaxConfigFiles, logger, d.captureWriter(capture, ft, nil), d.attr.S3ContentType)
+	if copyErr != nil {
+		return fmt.Errorf("saveCommit: unmasked copy: %v", copyErr)
+	}
+
+	logger.Printf("saveCommit: dev '%s' unmasked copy saved to '%s'", d.ID, copyPath)
+
+	for _, g := range d.namedGroups(capture, opt.UnmaskedPath) {
+		if namedErr := d.saveNamed(logger, g.list, g.prefix, path, opt.MaxConfigFiles, ft, nil); namedErr != nil {
+			return fmt.Errorf("saveCommit: unmasked copy: %v", namedErr)
+		}
+	}
+
+	return nil
+}
+
+// captureWriter builds a function which writes captured command outputs, applying the line filter,
+// then the secret mask, if any.
+func (d *Device) captureWriter(capture *dialog, ft *FilterTable, mask *secretMask) func(store.HasWrite) error {
+	return func(w store.HasWrite) error {
+
+		filters, missing := ft.get(d.attr.LineFilter)
+		if len(missing) > 0 {
+			d.debugf("captureWriter: filters not found: %v", missing)
+		}
+		filterFound := len(filters) > 0
 		if filterFound {
-			d.debugf("saveCommit: filter '%s' FOUND", d.Attr.LineFilter)
-		} else {
-			if d.Attr.LineFilter != "" {
-				d.debugf("saveCommit: filter '%s' not found", d.Attr.LineFilter)
-			}
+			d.debugf("captureWriter: filter chain '%s' FOUND", d.attr.LineFilter)
 		}
 
 		lineNum := 1
@@ -323,25 +636,36 @@ func (d *Device) saveCommit(logger hasPrintf, capture *dialog, repository string
 		for _, b := range capture.save {
 
 			var lines [][]byte
-			if filterFound {
+			if filterFound || mask != nil {
 				lines = bytes.Split(b, []byte{'\n'}) // split block into lines
 			} else {
 				lines = [][]byte{b} // use block as single line
 			}
 
-			for _, line := range lines {
+			for i, line := range lines {
 
 				if filterFound {
-					line = lineFilter(d, d.Debug, ft, line, lineNum) // apply filter
-					line = append(line, '\n')                        // restore LF removed by split
+					line = applyFilters(filters, d, d.Debug, ft, line, lineNum) // apply filter chain
+					if line == nil {
+						lineNum++
+						continue // line removed by filter
+					}
+				}
+
+				if mask != nil {
+					line = mask.line(line)
+				}
+
+				if filterFound || (mask != nil && i < len(lines)-1) {
+					line = append(line, '\n') // restore LF removed by split
 				}
 
 				n, writeErr := w.Write(line)
 				if writeErr != nil {
-					return fmt.Errorf("saveCommit: writeFunc: error: %v", writeErr)
+					return fmt.Errorf("captureWriter: error: %v", writeErr)
 				}
 				if n != len(line) {
-					return fmt.Errorf("saveCommit: writeFunc: partial: wrote=%d size=%d", n, len(line))
+					return fmt.Errorf("captureWriter: partial: wrote=%d size=%d", n, len(line))
 				}
 
 				lineNum++
@@ -349,15 +673,6 @@ func (d *Device) saveCommit(logger hasPrintf, capture *dialog, repository string
 		}
 		return nil
 	}
-
-	path, writeErr := store.SaveNewConfig(devPathPrefix, maxFiles, logger, writeFunc, d.Attr.ChangesOnly, d.Attr.S3ContentType)
-	if writeErr != nil {
-		return fmt.Errorf("saveCommit: error: %v", writeErr)
-	}
-
-	logger.Printf("saveCommit: dev '%s' saved to '%s'", d.ID, path)
-
-	return nil
 }
 
 type hasTimeout interface {
@@ -396,11 +711,11 @@ func (d *Device) match(logger hasPrintf, t transp, capture *dialog, patterns []s
 READ_LOOP:
 	for {
 		now := time.Now()
-		if now.Sub(begin) > d.Attr.MatchTimeout {
-			return badIndex, matchBuf, fmt.Errorf("match: timed out: %s", d.Attr.MatchTimeout)
+		if now.Sub(begin) > d.attr.MatchTimeout {
+			return badIndex, matchBuf, fmt.Errorf("match: timed out: %s", d.attr.MatchTimeout)
 		}
 
-		deadline := now.Add(d.Attr.ReadTimeout)
+		deadline := now.Add(d.attr.ReadTimeout)
 		if err := t.SetDeadline(deadline); err != nil {
 			return badIndex, matchBuf, fmt.Errorf("match: could not set read timeout: %v", err)
 		}
@@ -438,7 +753,7 @@ READ_LOOP:
 
 		d.debugf("recv1(%d): [%q]", len(lastRead), lastRead)
 
-		if !d.Attr.KeepControlChars {
+		if !d.attr.KeepControlChars {
 			matchBuf, lastRead = removeControlChars(d, d.Debug, matchBuf, lastRead)
 		}
 
@@ -446,7 +761,16 @@ READ_LOOP:
 
 		matchBuf = append(matchBuf, lastRead...)
 
-		if expList != nil {
+		if expList != nil && d.attr.MultiLinePrompt {
+			tail := promptTail(matchBuf)
+			for i, exp := range expList {
+				d.debugf("matching: %d/%d pattern=[%s] tail=[%q]", i, len(expList), patterns[i], tail)
+				if exp.Match(tail) {
+					d.debugf("matched: %d/%d pattern=[%s] tail=[%q]", i, len(expList), patterns[i], tail)
+					return i, matchBuf, nil // pattern found
+				}
+			}
+		} else if expList != nil {
 			var sep []byte
 			if bytes.IndexByte(lastRead, CR) >= 0 {
 				sep = []byte{CR, LF}
@@ -497,34 +821,60 @@ func (d *Device) send(logger hasPrintf, t transp, msg string) error {
 }
 
 func (d *Device) sendln(logger hasPrintf, t transp, msg string) error {
-	if d.Attr.SupressAutoLF {
+	if d.attr.SupressAutoLF {
 		return d.send(logger, t, msg)
 	}
 	return d.send(logger, t, msg+"\n")
 }
 
+// sendSecretln sends a credential without exposing it in debug logs.
+func (d *Device) sendSecretln(logger hasPrintf, t transp, secret string) error {
+	msg := secret
+	if !d.attr.SupressAutoLF {
+		msg += "\n"
+	}
+	return d.sendBytesQuiet(logger, t, []byte(msg), "[secret]")
+}
+
 func (d *Device) sendBytes(logger hasPrintf, t transp, msg []byte) error {
+	return d.sendBytesQuiet(logger, t, msg, fmt.Sprintf("%q", msg))
+}
+
+func (d *Device) sendBytesQuiet(logger hasPrintf, t transp, msg []byte, label string) error {
 
-	deadline := time.Now().Add(d.Attr.SendTimeout)
+	deadline := time.Now().Add(d.attr.SendTimeout)
 	if err := t.SetDeadline(deadline); err != nil {
 		return fmt.Errorf("send: could not set read timeout: %v", err)
 	}
 
-	d.debugf("send: [%q]", msg)
+	d.debugf("send: [%s]", label)
 
 	_, wrErr := t.Write(msg)
 
 	return wrErr
 }
 
+// promptTailSize limits the output tail matched against multi-line prompts.
+const promptTailSize = 1000
+
+// promptTail gets the output tail with line endings normalized to LF, for matching multi-line prompts.
+// Unlike single line matching, the tail also finds prompts split across reads.
+func promptTail(buf []byte) []byte {
+	if len(buf) > promptTailSize {
+		buf = buf[len(buf)-promptTailSize:]
+	}
+	tail := bytes.ReplaceAll(buf, []byte{CR, LF}, []byte{LF})
+	return bytes.ReplaceAll(tail, []byte{CR}, []byte{LF})
+}
+
 func (d *Device) matchCommandPrompt(t transp, capture *dialog) (matchBuf []byte, enabledPrompt, wantEOF bool, errMatch error) {
 
-	wantEOF = d.Attr.DisabledPromptPattern == ""
+	wantEOF = d.attr.DisabledPromptPattern == ""
 
-	list := []string{d.Attr.DisabledPromptPattern}
+	list := []string{d.attr.DisabledPromptPattern}
 
-	if d.Attr.EnabledPromptPattern != "" {
-		list = append(list, d.Attr.EnabledPromptPattern)
+	if d.attr.EnabledPromptPattern != "" {
+		list = append(list, d.attr.EnabledPromptPattern)
 	}
 
 	m, buf, err := d.match(d.logger, t, capture, list)
@@ -544,22 +894,26 @@ func (d *Device) matchCommandPrompt(t transp, capture *dialog) (matchBuf []byte,
 }
 
 func (d *Device) sendCommands(logger hasPrintf, t transp, capture *dialog) error {
+	return d.sendCommandList(logger, t, capture, d.attr.CommandList)
+}
+
+func (d *Device) sendCommandList(logger hasPrintf, t transp, capture *dialog, commands []string) error {
 
 	// save timeouts
-	saveReadTimeout := d.Attr.ReadTimeout
-	saveMatchTimeout := d.Attr.MatchTimeout
+	saveReadTimeout := d.attr.ReadTimeout
+	saveMatchTimeout := d.attr.MatchTimeout
 
 	// temporarily change timeouts
-	d.Attr.ReadTimeout = d.Attr.CommandReadTimeout
-	d.Attr.MatchTimeout = d.Attr.CommandMatchTimeout
+	d.attr.ReadTimeout = d.attr.CommandReadTimeout
+	d.attr.MatchTimeout = d.attr.CommandMatchTimeout
 
 	// restore timeouts
 	defer func() {
-		d.Attr.ReadTimeout = saveReadTimeout
-		d.Attr.MatchTimeout = saveMatchTimeout
+		d.attr.ReadTimeout = saveReadTimeout
+		d.attr.MatchTimeout = saveMatchTimeout
 	}()
 
-	for i, c := range d.Attr.CommandList {
+	for i, c := range commands {
 
 		d.debugf("sending command: [%s]", c)
 
@@ -598,8 +952,8 @@ func (d *Device) save(logger hasPrintf, capture *dialog, command string, buf []b
 
 	if command != "" {
 		command = fmt.Sprintf("%q", command)
-		if d.Attr.QuoteSentCommandsFormat != "" {
-			command = fmt.Sprintf(d.Attr.QuoteSentCommandsFormat, command)
+		if d.attr.QuoteSentCommandsFormat != "" {
+			command = fmt.Sprintf(d.attr.QuoteSentCommandsFormat, command)
 		}
 		command = "\n" + command + "\n"
 	}
@@ -608,28 +962,63 @@ func (d *Device) save(logger hasPrintf, capture *dialog, command string, buf []b
 	return nil
 }
 
+// pagingOff disables the pager.
+// Commands from DisablePagerCommandList are tried in order until one is not rejected by DisablePagerErrorPattern.
 func (d *Device) pagingOff(logger hasPrintf, t transp, capture *dialog) error {
 
-	if pagerErr := d.sendln(logger, t, d.Attr.DisablePagerCommand); pagerErr != nil {
-		return fmt.Errorf("pager off: could not send pager disabling command '%s': %v", d.Attr.DisablePagerCommand, pagerErr)
+	commands := d.attr.DisablePagerCommandList
+	if len(commands) < 1 {
+		commands = []string{d.attr.DisablePagerCommand}
+	}
+
+	var rejected *regexp.Regexp
+	if d.attr.DisablePagerErrorPattern != "" {
+		var reErr error
+		if rejected, reErr = regexp.Compile(d.attr.DisablePagerErrorPattern); reErr != nil {
+			return fmt.Errorf("pagingOff: bad pager error pattern '%s': %v", d.attr.DisablePagerErrorPattern, reErr)
+		}
+	}
+
+	for _, c := range commands {
+		output, err := d.pagerCommand(logger, t, capture, c)
+		if err != nil {
+			return err
+		}
+		if rejected == nil || !rejected.Match(output) {
+			return nil // pager disabled
+		}
+		logger.Printf("pagingOff: dev '%s' rejected pager command '%s'", d.ID, c)
+	}
+
+	return fmt.Errorf("pagingOff: all pager commands rejected: %s", strings.Join(commands, ", "))
+}
+
+// pagerCommand sends a pager disabling command, then returns its output.
+func (d *Device) pagerCommand(logger hasPrintf, t transp, capture *dialog, command string) ([]byte, error) {
+
+	if pagerErr := d.sendln(logger, t, command); pagerErr != nil {
+		return nil, fmt.Errorf("pager off: could not send pager disabling command '%s': %v", command, pagerErr)
 	}
 
-	matchCount := d.Attr.DisablePagerExtraPromptCount + 1
+	matchCount := d.attr.DisablePagerExtraPromptCount + 1
+
+	var output []byte
 
 	for i := 0; i < matchCount; i++ {
 
 		d.debugf("pagingOff: matching %d/%d", i, matchCount)
 
-		var buf []byte
-		var err error
-		if buf, _, _, err = d.matchCommandPrompt(t, capture); err != nil {
-			return fmt.Errorf("pagingOff: %d/%d could not match command prompt: %v", i, matchCount, err)
+		buf, _, _, err := d.matchCommandPrompt(t, capture)
+		if err != nil {
+			return nil, fmt.Errorf("pagingOff: %d/%d could not match command prompt: %v", i, matchCount, err)
 		}
 
 		d.debugf("pagingOff: matching %d/%d: found buf=[%s]", i, matchCount, string(buf))
+
+		output = append(output, buf...)
 	}
 
-	return nil
+	return output, nil
 }
 
 func (d *Device) enable(logger hasPrintf, t transp, capture *dialog) error {
@@ -660,19 +1049,19 @@ func (d *Device) enable(logger hasPrintf, t transp, capture *dialog) error {
 
 	d.debugf("enable: sending enable command")
 
-	if enableErr := d.sendln(logger, t, d.Attr.EnableCommand); enableErr != nil {
-		return fmt.Errorf("enable: could not send enable command '%s': %v", d.Attr.EnableCommand, enableErr)
+	if enableErr := d.sendln(logger, t, d.attr.EnableCommand); enableErr != nil {
+		return fmt.Errorf("enable: could not send enable command '%s': %v", d.attr.EnableCommand, enableErr)
 	}
 
 	d.debugf("enable: expecting enabled prompt")
 
-	if d.Attr.EnablePasswordPromptPattern == "" {
+	if d.attr.EnablePasswordPromptPattern == "" {
 
 		// no pattern for enable password prompt
 
 		d.debugf("enable: expecting enabled prompt - no pattern for enable password prompt")
 
-		_, _, err := d.match(logger, t, capture, []string{d.Attr.EnabledPromptPattern})
+		_, _, err := d.match(logger, t, capture, []string{d.attr.EnabledPromptPattern})
 		if err != nil {
 			return fmt.Errorf("enable: could not match after-enable prompt: %v", err)
 		}
@@ -681,7 +1070,7 @@ func (d *Device) enable(logger hasPrintf, t transp, capture *dialog) error {
 
 	}
 
-	m, _, err := d.match(logger, t, capture, []string{d.Attr.EnablePasswordPromptPattern, d.Attr.EnabledPromptPattern})
+	m, _, err := d.match(logger, t, capture, []string{d.attr.EnablePasswordPromptPattern, d.attr.EnabledPromptPattern})
 	if err != nil {
 		return fmt.Errorf("enable: could not match after-enable prompt: %v", err)
 	}
@@ -690,11 +1079,11 @@ func (d *Device) enable(logger hasPrintf, t transp, capture *dialog) error {
 		return nil // found enabled command prompt
 	}
 
-	if passErr := d.sendln(logger, t, d.EnablePassword); passErr != nil {
+	if passErr := d.sendSecretln(logger, t, d.EnablePassword); passErr != nil {
 		return fmt.Errorf("enable: could not send enable password: %v", passErr)
 	}
 
-	if _, _, mismatch := d.match(logger, t, capture, []string{d.Attr.EnabledPromptPattern}); mismatch != nil {
+	if _, _, mismatch := d.match(logger, t, capture, []string{d.attr.EnabledPromptPattern}); mismatch != nil {
 		return fmt.Errorf("enable: could not find enabled command prompt: %v", mismatch)
 	}
 
@@ -703,11 +1092,13 @@ func (d *Device) enable(logger hasPrintf, t transp, capture *dialog) error {
 
 func (d *Device) login(logger hasPrintf, t transp, capture *dialog) (bool, error) {
 
-	m1, _, err := d.match(logger, t, capture, []string{d.Attr.UsernamePromptPattern, d.Attr.PasswordPromptPattern})
+	m1, banner, err := d.match(logger, t, capture, []string{d.attr.UsernamePromptPattern, d.attr.PasswordPromptPattern})
 	if err != nil {
 		return false, fmt.Errorf("login: could not find username prompt: %v", err)
 	}
 
+	capture.banner = banner
+
 	switch m1 {
 	case 0:
 		d.debugf("login: found username prompt")
@@ -724,19 +1115,19 @@ func (d *Device) login(logger hasPrintf, t transp, capture *dialog) (bool, error
 		indexEna := -1
 		indexDis := -1
 
-		if d.Attr.PasswordPromptPattern != "" {
+		if d.attr.PasswordPromptPattern != "" {
 			indexPwd = len(list)
-			list = append(list, d.Attr.PasswordPromptPattern)
+			list = append(list, d.attr.PasswordPromptPattern)
 		}
 
-		if d.Attr.EnabledPromptPattern != "" {
+		if d.attr.EnabledPromptPattern != "" {
 			indexEna = len(list)
-			list = append(list, d.Attr.EnabledPromptPattern)
+			list = append(list, d.attr.EnabledPromptPattern)
 		}
 
-		if d.Attr.DisabledPromptPattern != "" {
+		if d.attr.DisabledPromptPattern != "" {
 			indexDis = len(list)
-			list = append(list, d.Attr.DisabledPromptPattern)
+			list = append(list, d.attr.DisabledPromptPattern)
 		}
 
 		if len(list) < 1 {
@@ -767,27 +1158,27 @@ func (d *Device) login(logger hasPrintf, t transp, capture *dialog) (bool, error
 
 	d.debugf("login: will send password")
 
-	if passErr := d.sendln(logger, t, d.LoginPassword); passErr != nil {
+	if passErr := d.sendSecretln(logger, t, d.LoginPassword); passErr != nil {
 		return false, fmt.Errorf("login: could not send password: %v", passErr)
 	}
 
 	d.debugf("login: sent password")
 
-	if d.Attr.PostLoginPromptPattern != "" {
+	if d.attr.PostLoginPromptPattern != "" {
 
-		d.debugf("post-login-prompt: looking for pattern=[%s]", d.Attr.PostLoginPromptPattern)
+		d.debugf("post-login-prompt: looking for pattern=[%s]", d.attr.PostLoginPromptPattern)
 
 		list := []string{}
 
 		indexEna := -1
 
-		if d.Attr.DisabledPromptPattern != "" {
-			list = append(list, d.Attr.DisabledPromptPattern)
+		if d.attr.DisabledPromptPattern != "" {
+			list = append(list, d.attr.DisabledPromptPattern)
 		}
 
-		if d.Attr.EnabledPromptPattern != "" {
+		if d.attr.EnabledPromptPattern != "" {
 			indexEna = len(list)
-			list = append(list, d.Attr.EnabledPromptPattern)
+			list = append(list, d.attr.EnabledPromptPattern)
 		}
 
 		if len(list) < 1 {
@@ -795,7 +1186,7 @@ func (d *Device) login(logger hasPrintf, t transp, capture *dialog) (bool, error
 		}
 
 		indexPos := len(list)
-		list = append(list, d.Attr.PostLoginPromptPattern)
+		list = append(list, d.attr.PostLoginPromptPattern)
 
 		var m int
 		var mismatch error
@@ -807,11 +1198,11 @@ func (d *Device) login(logger hasPrintf, t transp, capture *dialog) (bool, error
 		if m == indexPos {
 			d.debugf("post-login-prompt: prompt FOUND")
 
-			if nlErr := d.send(logger, t, d.Attr.PostLoginPromptResponse); nlErr != nil {
+			if nlErr := d.send(logger, t, d.attr.PostLoginPromptResponse); nlErr != nil {
 				return false, fmt.Errorf("post-login-prompt: error: %v", nlErr)
 			}
 
-			d.debugf("post-login-prompt: response sent: [%q]", d.Attr.PostLoginPromptResponse)
+			d.debugf("post-login-prompt: response sent: [%q]", d.attr.PostLoginPromptResponse)
 		} else {
 			enabled := m == indexEna
 			return enabled, nil
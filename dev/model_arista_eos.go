@@ -0,0 +1,171 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelAristaEOS(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.NeedLoginChat = true
+	a.NeedEnabledMode = true
+	a.NeedPagingOff = true
+	a.EnableCommand = "enable"
+	a.UsernamePromptPattern = `(?:[Ll]ogin|Username):\s*$`
+	a.PasswordPromptPattern = `Password:\s*$`
+	a.EnablePasswordPromptPattern = `Password:\s*$`
+	a.DisabledPromptPattern = `\S+>\s*$`
+	a.EnabledPromptPattern = `\S+#\s*$`
+	a.CommandList = []string{"show version", "show running-config"} // sent through eAPI or CLI
+	a.DisablePagerCommand = "terminal length 0"
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow 'sh run'
+	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'sh run', also full eAPI request timeout
+	a.QuoteSentCommandsFormat = `!![%s]`
+	a.APIFormat = "text"
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^Uptime:`, `^(?:Total|Free) memory:`, `^! Time: `}
+
+	m := &Model{name: "arista-eos"}
+	m.defaultAttr = a
+	m.apiFetch = fetchEOSAPI
+	m.apiFallback = true // eAPI might be disabled
+	m.fingerprints = []string{`Software image version: `}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^hostname (\S+)`,
+		FactVersion:  `Software image version: (\S+)`,
+		FactSerial:   `Serial number:\s+(\S+)`,
+		FactPlatform: `(?m)^Arista (\S+)`,
+		FactUptime:   `(?m)^Uptime:\s+(.+?)\s*$`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelAristaEOS: %v", err)
+	}
+}
+
+// eosRequest is an eAPI JSON-RPC request.
+type eosRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  eosRequestParam `json:"params"`
+	ID      string          `json:"id"`
+}
+
+type eosRequestParam struct {
+	Version int           `json:"version"`
+	Cmds    []interface{} `json:"cmds"`
+	Format  string        `json:"format"`
+}
+
+// eosCommand is an eAPI command answering a prompt, like enable password.
+type eosCommand struct {
+	Cmd   string `json:"cmd"`
+	Input string `json:"input"`
+}
+
+// eosResponse is an eAPI JSON-RPC response.
+type eosResponse struct {
+	Result []json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// fetchEOSAPI runs the command list through eAPI runCmds.
+// Unreachable eAPI is reported as transport error, so the CLI chat is tried instead.
+func fetchEOSAPI(d *Device, logger hasPrintf, capture *dialog, opt *conf.AppConfig) (string, int, error) {
+
+	u, urlErr := apiBaseURL(d.apiAddress())
+	if urlErr != nil {
+		return "", fetchErrTransp, fmt.Errorf("fetchEOSAPI: %v", urlErr)
+	}
+	transport := u.Scheme
+	u.Path = "/command-api"
+
+	format := d.attr.APIFormat
+	if format == "" {
+		format = "text"
+	}
+
+	cmds := []interface{}{eosCommand{Cmd: "enable", Input: d.EnablePassword}}
+	for _, c := range d.attr.CommandList {
+		cmds = append(cmds, c)
+	}
+
+	reqBody, _ := json.Marshal(eosRequest{JSONRPC: "2.0", Method: "runCmds", Params: eosRequestParam{Version: 1, Cmds: cmds, Format: format}, ID: "jazigo"})
+
+	req, reqErr := http.NewRequest("POST", u.String(), bytes.NewReader(reqBody))
+	if reqErr != nil {
+		return transport, fetchErrTransp, fmt.Errorf("fetchEOSAPI: %v", reqErr)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(d.Username(), d.LoginPassword)
+
+	resp, doErr := d.apiClient().Do(req)
+	if doErr != nil {
+		return transport, fetchErrTransp, fmt.Errorf("fetchEOSAPI: %v", doErr)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return transport, fetchErrLogin, fmt.Errorf("fetchEOSAPI: http status=%d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return transport, fetchErrTransp, fmt.Errorf("fetchEOSAPI: http status=%d", resp.StatusCode) // eAPI disabled
+	}
+
+	body, readErr := apiReadBody(resp.Body, opt.MaxConfigLoadSize)
+	if readErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchEOSAPI: %v", readErr)
+	}
+
+	var r eosResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchEOSAPI: bad response: %v", err)
+	}
+	if r.Error != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchEOSAPI: error code=%d: %s", r.Error.Code, r.Error.Message)
+	}
+	if len(r.Result) != len(cmds) {
+		return transport, fetchErrCommands, fmt.Errorf("fetchEOSAPI: sent %d commands, got %d results", len(cmds), len(r.Result))
+	}
+
+	for i, c := range d.attr.CommandList {
+		output, outErr := eosOutput(r.Result[i+1], format) // skip enable
+		if outErr != nil {
+			return transport, fetchErrCommands, fmt.Errorf("fetchEOSAPI: command '%s': %v", c, outErr)
+		}
+		if saveErr := d.save(logger, capture, c, output); saveErr != nil {
+			return transport, fetchErrCommands, fmt.Errorf("fetchEOSAPI: could not save command '%s' result: %v", c, saveErr)
+		}
+	}
+
+	return transport, fetchErrNone, nil
+}
+
+// eosOutput gets command output from an eAPI result: output field for text format, indented object for json format.
+func eosOutput(result json.RawMessage, format string) ([]byte, error) {
+	if format != "text" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, result, "", "  "); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	var text struct {
+		Output string `json:"output"`
+	}
+	if err := json.Unmarshal(result, &text); err != nil {
+		return nil, err
+	}
+	return []byte(text.Output), nil
+}
@@ -0,0 +1,138 @@
+package dev
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+const eosTestVersion = "Arista DCS-7050TX-64-R\nHardware version:    01.11\nSerial number:       JPE12345678\n\nSoftware image version: 4.24.2F\nUptime:                 4 weeks, 2 days, 3 hours and 12 minutes\n"
+
+const eosTestConfig = "! Command: show running-config\nhostname leaf1\nusername admin privilege 15 role network-admin secret sha512 $6$abc$def\nend\n"
+
+func TestAristaEOSAPI(t *testing.T) {
+
+	api := httptest.NewTLSServer(http.HandlerFunc(handleEOSAPI))
+	defer api.Close()
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	d := newTestAristaEOS(t, tab, logger, "localhost:2028", strings.TrimPrefix(api.URL, "https://"))
+	d.attr.SecretMask = "arista-eos"
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"}
+
+	result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger))
+	if result.Code != fetchErrNone || result.Transport != "https" {
+		t.Fatalf("eAPI: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), opt.MaxConfigLoadSize)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if !strings.Contains(string(config), "hostname leaf1") || !strings.Contains(string(config), "Software image version: 4.24.2F") || strings.Contains(string(config), "$6$abc$def") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+
+	if out, err := eosOutput(json.RawMessage(`{"version":"4.24.2F"}`), "json"); err != nil || string(out) != "{\n  \"version\": \"4.24.2F\"\n}" {
+		t.Errorf("json output: %q err=%v", out, err)
+	}
+
+	// bad credentials must not fall back to CLI
+	d.LoginPassword = "wrong"
+	if result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger)); result.Code != fetchErrLogin || result.Transport != "https" {
+		t.Errorf("bad credentials: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+
+	// output larger than maxconfigloadsize must not fall back to CLI either
+	d.LoginPassword = "pass"
+	small := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 100}
+	if result := d.fetch(logger, 0, repo, small, NewFilterTable(logger)); result.Code != fetchErrCommands || !strings.Contains(result.Msg, "exceeds max=100") {
+		t.Errorf("oversized output: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+}
+
+func TestAristaEOSFallback(t *testing.T) {
+
+	// launch bogus CLI server
+	addr := ":2028"
+	s, listenErr := spawnServerCiscoIOS(t, addr, optionsCiscoIOS{sendUsername: true, sendDisable: true, requestEnablePass: true})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus CLI server: %v", listenErr)
+	}
+
+	// eAPI disabled
+	api := httptest.NewTLSServer(http.NotFoundHandler())
+	defer api.Close()
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	d := newTestAristaEOS(t, tab, logger, "localhost"+addr, strings.TrimPrefix(api.URL, "https://"))
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	result := d.fetch(logger, 0, repo, &conf.AppConfig{MaxConfigFiles: 10}, NewFilterTable(logger))
+	if result.Code != fetchErrNone || result.Transport != "telnet" {
+		t.Errorf("CLI fallback: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+}
+
+func newTestAristaEOS(t *testing.T, tab *DeviceTable, logger hasPrintf, hostPort, apiHostPort string) *Device {
+	cfg := conf.DevConfig{Model: "arista-eos", ID: "lab1", HostPort: hostPort, Transports: "telnet", LoginUser: "admin", LoginPassword: "pass", EnablePassword: "en",
+		Attr: conf.AttrOverrides{"apihostport": apiHostPort, "apiinsecuretls": true}}
+	d, _, err := NewDeviceFromConf(tab, logger, &cfg)
+	if err != nil {
+		t.Fatalf("new device: %v", err)
+	}
+	return d
+}
+
+func handleEOSAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.URL.Path != "/command-api" {
+		http.NotFound(w, r)
+		return
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var req eosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "runCmds" || req.Params.Format != "text" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": map[string]interface{}{"code": -32600, "message": "bad request"}})
+		return
+	}
+
+	var result []map[string]string
+	for _, c := range req.Params.Cmds {
+		var output string
+		switch c {
+		case "show version":
+			output = eosTestVersion
+		case "show running-config":
+			output = eosTestConfig
+		}
+		result = append(result, map[string]string{"output": output})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
@@ -0,0 +1,41 @@
+package dev
+
+import (
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelArubaCX(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.NeedLoginChat = true
+	a.NeedPagingOff = true
+	a.UsernamePromptPattern = `(?:[Ll]ogin(?: as)?|Username):\s*$`
+	a.PasswordPromptPattern = `[Pp]assword:\s*$`
+	a.DisabledPromptPattern = `\S+>\s*$`
+	a.EnabledPromptPattern = `\S+#\s*$`
+	a.DisablePagerCommand = "no page"
+	a.CommandList = []string{"show version", "show running-config"}
+	a.SeparateCommands = []string{"show running-config json"} // saved under outputs/show-running-config-json
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow 'sh run'
+	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'sh run'
+	a.QuoteSentCommandsFormat = `!![%s]`
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^Current configuration:`}
+
+	m := &Model{name: "aruba-cx"}
+	m.defaultAttr = a
+	m.fingerprints = []string{`ArubaOS-CX`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^hostname (\S+)`,
+		FactVersion:  `(?m)^Version\s+:\s+(\S+)`,
+		FactPlatform: `(?m)^Build ID\s+:\s+(ArubaOS-CX):`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelArubaCX: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package dev
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestArubaCX(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2032"
+	s, listenErr := spawnServerArubaCX(t, addr)
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus ArubaCX server: %v", listenErr)
+	}
+	t.Logf("TestArubaCX: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "aruba-cx", "lab1", "localhost"+addr, "telnet", "admin", "pass", "", false, nil)
+	enableMask(t, tab, "lab1", "aruba-cx")
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), 1000000)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if c := string(config); !strings.Contains(c, "hostname access1") || strings.Contains(c, "AQBapCLI") || strings.Contains(c, `"hostname"`) {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+
+	jsonOut, jsonErr := store.FileRead(DeviceOutputPrefix(repo, "lab1", "show-running-config-json")+"0", 1000000)
+	if jsonErr != nil {
+		t.Fatalf("json output: %v", jsonErr)
+	}
+	if c := string(jsonOut); !strings.Contains(c, `"hostname": "access1"`) || strings.Contains(c, "AQBapJSON") {
+		t.Errorf("json output: unexpected content: %s", jsonOut)
+	}
+
+	if f, err := tab.GetFacts("lab1"); err != nil || f.Hostname != "access1" || f.Version != "FL.10.04.3000" || f.Platform != "ArubaOS-CX" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+}
+
+func spawnServerArubaCX(t *testing.T, addr string) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopArubaCX(t, s, handleConnectionArubaCX)
+
+	return s, nil
+}
+
+func acceptLoopArubaCX(t *testing.T, s *testServer, handler func(*testing.T, net.Conn)) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopArubaCX: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn)
+	}
+
+	close(s.done)
+}
+
+func handleConnectionArubaCX(t *testing.T, c net.Conn) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send username prompt
+	if _, err := c.Write([]byte("\nlogin as: ")); err != nil {
+		t.Logf("handleConnectionArubaCX: send username prompt error: %v", err)
+		return
+	}
+
+	// consume username
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionArubaCX: read username error: %v", err)
+		return
+	}
+
+	// send password prompt
+	if _, err := c.Write([]byte("\nadmin@access1's password: ")); err != nil {
+		t.Logf("handleConnectionArubaCX: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionArubaCX: read password error: %v", err)
+		return
+	}
+
+	// send motd
+	if _, err := c.Write([]byte("\nLast login: 2020-03-02 12:41:38 from 10.0.0.1\nUser \"admin\" has logged in 3 times in the past 30 days\n")); err != nil {
+		t.Logf("handleConnectionArubaCX: send motd error: %v", err)
+		return
+	}
+
+LOOP:
+	for {
+		// send command prompt
+		if _, err := c.Write([]byte("\naccess1# ")); err != nil {
+			t.Logf("handleConnectionArubaCX: send command prompt error: %v", err)
+			return
+		}
+
+		// consume command
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionArubaCX: read command error: %v", readErr)
+			return
+		}
+
+		cmd := strings.TrimSpace(string(buf[:n]))
+
+		var output string
+
+		switch cmd {
+		case "exit", "logout":
+			break LOOP
+		case "no page":
+		case "show version":
+			output = "-----------------------------------------------------------------------------\nArubaOS-CX\n(c) Copyright 2017-2020 Hewlett Packard Enterprise Development LP\n-----------------------------------------------------------------------------\nVersion      : FL.10.04.3000\nBuild Date   : 2020-03-02 12:41:38 PST\nBuild ID     : ArubaOS-CX:FL.10.04.3000:abcdef012345:202003021241\nActive Image : primary"
+		case "show running-config":
+			output = "Current configuration:\n!\n!Version ArubaOS-CX FL.10.04.3000\nhostname access1\nuser admin group administrators password ciphertext AQBapCLI\n!\nvlan 1"
+		case "show running-config json":
+			output = "Running-configuration in JSON:\n{\n    \"System\": {\n        \"hostname\": \"access1\",\n        \"users\": {\n            \"admin\": {\n                \"password\": \"AQBapJSON\"\n            }\n        }\n    }\n}"
+		default:
+			output = "Invalid input: " + cmd
+		}
+
+		if output != "" {
+			if _, err := c.Write([]byte("\n" + output)); err != nil {
+				t.Logf("handleConnectionArubaCX: send output error: %v", err)
+				return
+			}
+		}
+	}
+
+	// send bye
+	if _, err := c.Write([]byte("\n")); err != nil {
+		t.Logf("handleConnectionArubaCX: send bye error: %v", err)
+		return
+	}
+}
@@ -0,0 +1,198 @@
+package dev
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+// autoModel is a pseudo-model for devices whose actual model is unknown.
+// The device is probed with generic patterns, classified against the fingerprints
+// provided by every other model, then rewritten to the detected model.
+const autoModel = "auto"
+
+func registerModelAuto(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.NeedLoginChat = true
+	a.UsernamePromptPattern = `(?i)(username|login)\s*:\s*$`
+	a.PasswordPromptPattern = `(?i)password\s*:\s*$`
+	a.DisabledPromptPattern = `\S\s?>\s*$`
+	a.EnabledPromptPattern = `\S\s?#\s*$`
+
+	// safe probe commands: paging off, then system information
+	a.CommandList = []string{
+		"terminal length 0",         // cisco
+		"screen-length 0 temporary", // huawei
+		"set cli screen-length 0",   // junos
+		"show version",              // cisco, junos, dmswitch
+		"display version",           // huawei
+		"get system status",         // fortios
+		"/system resource print\r",  // mikrotik
+	}
+
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+
+	m := &Model{name: autoModel}
+	m.defaultAttr = a
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelAuto: %v", err)
+	}
+}
+
+// loadFingerprints compiles the fingerprints for all models able to be autodetected.
+func loadFingerprints(tab DeviceUpdater) (map[string][]*regexp.Regexp, error) {
+	fingerprints := map[string][]*regexp.Regexp{}
+	for _, name := range tab.ListModels() {
+		if name == autoModel {
+			continue
+		}
+		mod, getErr := tab.GetModel(name)
+		if getErr != nil {
+			continue // model removed meanwhile
+		}
+		for _, f := range mod.fingerprints {
+			exp, badExp := regexp.Compile(f)
+			if badExp != nil {
+				return nil, fmt.Errorf("loadFingerprints: model '%s': bad fingerprint '%s': %v", name, f, badExp)
+			}
+			fingerprints[name] = append(fingerprints[name], exp)
+		}
+	}
+	return fingerprints, nil
+}
+
+// classify picks the model with the highest count of fingerprints matching the output.
+func classify(fingerprints map[string][]*regexp.Regexp, output []byte) (string, error) {
+	var best []string
+	bestScore := 0
+	for name, list := range fingerprints {
+		score := 0
+		for _, exp := range list {
+			if exp.Match(output) {
+				score++
+			}
+		}
+		switch {
+		case score < 1 || score < bestScore:
+		case score == bestScore:
+			best = append(best, name)
+		default:
+			best = []string{name}
+			bestScore = score
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return "", fmt.Errorf("classify: no fingerprint matched")
+	case 1:
+		return best[0], nil
+	}
+
+	sort.Strings(best)
+	return "", fmt.Errorf("classify: ambiguous models: %s", strings.Join(best, ","))
+}
+
+// probe logs into the device with generic patterns, then sends probe commands until the model is identified.
+func (d *Device) probe(logger hasPrintf, delay time.Duration, secretTTL time.Duration, fingerprints map[string][]*regexp.Regexp) FetchResult {
+	modelName := d.devModel.name
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	begin := time.Now()
+
+	capture := dialog{}
+
+	session, transport, _, code, err := d.connect(logger, &capture, secretTTL)
+	if err != nil {
+		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: err.Error(), Code: code, Begin: begin}
+	}
+
+	defer session.Close()
+
+	output := append([]byte{}, capture.banner...)
+	detected, detectErr := classify(fingerprints, output)
+
+	for _, c := range d.attr.CommandList {
+		if detected != "" {
+			break
+		}
+
+		d.debugf("probe: sending command: [%s]", c)
+
+		if sendErr := d.sendln(logger, session, c); sendErr != nil {
+			d.logf("probe: could not send command '%s': %v", c, sendErr)
+			break
+		}
+
+		buf, _, _, matchErr := d.matchCommandPrompt(session, &capture)
+		output = append(output, buf...)
+		detected, detectErr = classify(fingerprints, output)
+		if matchErr != nil {
+			if matchErr != io.EOF {
+				d.logf("probe: could not match command prompt after '%s': %v", c, matchErr)
+			}
+			break
+		}
+	}
+
+	if detected == "" {
+		return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Msg: fmt.Sprintf("autodetect: %v", detectErr), Code: fetchErrDetect, Begin: begin}
+	}
+
+	return FetchResult{Model: modelName, DevID: d.ID, DevHostPort: d.HostPort, Transport: transport, Detected: detected, Code: fetchErrNone, Begin: begin}
+}
+
+// autodetect identifies the device model, rewrites the device config with it,
+// then fetches the device configuration using the detected model.
+func (d *Device) autodetect(tab DeviceUpdater, logger hasPrintf, delay time.Duration, repository string, opt *conf.AppConfig, ft *FilterTable) FetchResult {
+
+	fingerprints, fpErr := loadFingerprints(tab)
+	if fpErr != nil {
+		now := time.Now()
+		return FetchResult{Model: d.devModel.name, DevID: d.ID, DevHostPort: d.HostPort, Transport: d.Transports, Msg: fmt.Sprintf("autodetect: %v", fpErr), Code: fetchErrDetect, Begin: now}
+	}
+
+	probe := d.probe(logger, delay, opt.SecretCacheTTL, fingerprints)
+	if probe.Code != fetchErrNone {
+		return probe
+	}
+
+	logger.Printf("autodetect: device %s: detected model '%s'", d.ID, probe.Detected)
+
+	change := conf.Change{When: time.Now(), By: "autodetect"}
+	if err := tab.SetDeviceModel(d.ID, probe.Detected, change); err != nil {
+		probe.Msg = fmt.Sprintf("autodetect: %v", err)
+		probe.Code = fetchErrDetect
+		return probe
+	}
+
+	// backup right away with detected model
+	d1, getErr := tab.GetDevice(d.ID)
+	if getErr != nil {
+		probe.Msg = fmt.Sprintf("autodetect: %v", getErr)
+		probe.Code = fetchErrGetDev
+		return probe
+	}
+	if err := d1.refresh(tab); err != nil {
+		probe.Msg = fmt.Sprintf("autodetect: fetch attributes: %v", err)
+		probe.Code = fetchErrAttr
+		return probe
+	}
+
+	result := d1.fetch(logger, 0, repository, opt, ft)
+	result.Detected = probe.Detected
+	result.Begin = probe.Begin
+
+	return result
+}
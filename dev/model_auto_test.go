@@ -0,0 +1,184 @@
+package dev
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestAutodetect(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2015"
+	s, listenErr := spawnServerAuto(t, addr)
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus auto server: %v", listenErr)
+	}
+	t.Logf("TestAutodetect: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, autoModel, "lab1", "localhost"+addr, "telnet", "lab", "pass", "", false, nil)
+
+	var changes []conf.Change
+	tab.SetChangeHook(func(c conf.Change) {
+		changes = append(changes, c)
+	})
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	d, _ := tab.GetDevice("lab1")
+	if d.DevConfig.Model != "huawei-vrp" || d.Model() != "huawei-vrp" {
+		t.Errorf("detected model: config=%s model=%s", d.DevConfig.Model, d.Model())
+	}
+	if d.LastChange.By != "autodetect" {
+		t.Errorf("change by: %s", d.LastChange.By)
+	}
+	if len(changes) != 1 {
+		t.Errorf("change hook calls: %d", len(changes))
+	}
+}
+
+func TestClassify(t *testing.T) {
+	fingerprints := map[string][]*regexp.Regexp{
+		"a": {regexp.MustCompile(`Alpha`), regexp.MustCompile(`OS v\d`)},
+		"b": {regexp.MustCompile(`Beta`)},
+		"c": {regexp.MustCompile(`OS v\d`)},
+	}
+
+	if m, err := classify(fingerprints, []byte("Alpha OS v1")); err != nil || m != "a" {
+		t.Errorf("best score: model=%s err=%v", m, err)
+	}
+	if m, err := classify(fingerprints, []byte("Beta")); err != nil || m != "b" {
+		t.Errorf("single: model=%s err=%v", m, err)
+	}
+	if _, err := classify(fingerprints, []byte("OS v2")); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("ambiguous: err=%v", err)
+	}
+	if _, err := classify(fingerprints, []byte("Gamma")); err == nil {
+		t.Errorf("no match: expected error")
+	}
+}
+
+func spawnServerAuto(t *testing.T, addr string) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopAuto(t, s, handleConnectionAuto)
+
+	return s, nil
+}
+
+func acceptLoopAuto(t *testing.T, s *testServer, handler func(*testing.T, net.Conn)) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopAuto: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn)
+	}
+
+	close(s.done)
+}
+
+// handleConnectionAuto emulates a huawei-vrp device.
+func handleConnectionAuto(t *testing.T, c net.Conn) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send banner and username prompt
+	if _, err := c.Write([]byte("Bogus server\n\nLogin authentication\n\nUsername:")); err != nil {
+		t.Logf("handleConnectionAuto: send banner error: %v", err)
+		return
+	}
+
+	// consume username
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionAuto: read username error: %v", err)
+		return
+	}
+
+	// send password prompt
+	if _, err := c.Write([]byte("\nPassword:")); err != nil {
+		t.Logf("handleConnectionAuto: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionAuto: read password error: %v", err)
+		return
+	}
+
+	for {
+		// send command prompt
+		if _, err := c.Write([]byte(fmt.Sprintf("\n%s", "<auto-router>"))); err != nil {
+			t.Logf("handleConnectionAuto: send command prompt error: %v", err)
+			return
+		}
+
+		// consume command
+		n, err := c.Read(buf)
+		if err != nil {
+			if err == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionAuto: read command error: %v", err)
+			return
+		}
+
+		str := string(buf[:n])
+
+		var reply string
+		switch {
+		case strings.HasPrefix(str, "q"): //quit
+			return
+		case strings.HasPrefix(str, "screen-length"):
+			// set paging
+		case strings.HasPrefix(str, "display ver"), strings.HasPrefix(str, "disp ver"):
+			reply = "\nHuawei Versatile Routing Platform Software\nVRP (R) software, Version 5.160\n"
+		case strings.HasPrefix(str, "disp curr"):
+			reply = "\n#\nsysname auto-router\n#\nreturn\n"
+		default:
+			reply = "\nError: Unrecognized command found at '^' position."
+		}
+
+		if _, err := c.Write([]byte(reply)); err != nil {
+			t.Logf("handleConnectionAuto: send reply error: %v", err)
+			return
+		}
+	}
+}
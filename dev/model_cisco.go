@@ -26,9 +26,25 @@ func registerModelCiscoIOS(logger hasPrintf, t *DeviceTable) {
 	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow 'sh run'
 	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'sh run'
 	a.QuoteSentCommandsFormat = `!![%s]`
+	a.RestoreCommands = []string{
+		"configure replace {url} time 300", // automatic rollback unless confirmed by operator: configure confirm
+		"Y",                                // answer replace prompt
+	}
+	a.RestoreConfigCommand = "show run"    // staged for {url}
+	a.RestorePromptPattern = `\[no\]:\s*$` // Enter Y if you are sure you want to proceed. ? [no]:
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{` uptime is `, `^Current configuration : \d+ bytes`, `^! (?:Last configuration change|NVRAM config last updated) at `, `^ntp clock-period `}
 
 	m := &Model{name: "cisco-ios"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`Cisco IOS Software`, `IOS \(tm\)`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^hostname (\S+)`,
+		FactVersion:  `(?:Cisco IOS Software|IOS \(tm\)).*, Version ([^\s,]+)`,
+		FactSerial:   `(?m)^Processor board ID (\S+)`,
+		FactPlatform: `(?m)^[Cc]isco (\S+) (?:\([^)]*\) )?processor`,
+		FactUptime:   `(?m)^\S+ uptime is (.+?)\s*$`,
+	}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelCiscoIOS: %v", err)
 	}
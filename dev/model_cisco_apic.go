@@ -23,6 +23,7 @@ func registerModelCiscoAPIC(logger hasPrintf, t *DeviceTable) {
 
 	m := &Model{name: "cisco-apic"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`Application Policy Infrastructure Controller`}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelCiscoAPIC: %v", err)
 	}
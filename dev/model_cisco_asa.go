@@ -0,0 +1,52 @@
+package dev
+
+import (
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelCiscoASA(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.NeedLoginChat = true
+	a.NeedEnabledMode = true
+	a.NeedPagingOff = true
+	a.EnableCommand = "enable"
+	a.UsernamePromptPattern = `(?:Username|User Name):\s*$`
+	a.PasswordPromptPattern = `Password:\s*$`
+	a.EnablePasswordPromptPattern = `Password:\s*$`
+	a.DisabledPromptPattern = `\S+>\s*$`
+	a.EnabledPromptPattern = `\S+#\s*$`
+	a.CommandList = []string{"show version", "more system:running-config"} // more shows secrets hidden by show run
+	a.DisablePagerCommand = "terminal pager 0"
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow 'more system:running-config'
+	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'more system:running-config'
+	a.QuoteSentCommandsFormat = `!![%s]`
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^\S+ up \d`, `^: Written by `, `^Cryptochecksum:`}
+
+	// multiple context mode: show context lists contexts, single context mode lists nothing
+	a.ContextListCommand = "show context"
+	a.ContextListPattern = `(?m)^[ *](\S+)\s.*\s\S+:/\S+\s*$` // *admin   GigabitEthernet0/1   default   disk0:/admin.cfg
+	a.ContextChangeCommand = "changeto context %s"
+	a.ContextExitCommand = "changeto system"
+	a.ContextCommandList = []string{"more system:running-config"}
+
+	m := &Model{name: "cisco-asa"}
+	m.defaultAttr = a
+	m.fingerprints = []string{`Cisco Adaptive Security Appliance`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^hostname (\S+)`,
+		FactVersion:  `Cisco Adaptive Security Appliance Software Version (\S+)`,
+		FactSerial:   `(?m)^Serial Number: (\S+)`,
+		FactPlatform: `(?m)^Hardware:\s+([^,]+)`,
+		FactUptime:   `(?m)^\S+ up (.+?)\s*$`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelCiscoASA: %v", err)
+	}
+}
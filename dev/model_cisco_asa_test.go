@@ -0,0 +1,323 @@
+package dev
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+type optionsCiscoASA struct {
+	contexts []string // multiple context mode
+}
+
+func TestCiscoASAMultiContext(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2021"
+	s, listenErr := spawnServerCiscoASA(t, addr, optionsCiscoASA{contexts: []string{"admin", "cust1"}})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus CiscoASA server: %v", listenErr)
+	}
+	t.Logf("TestCiscoASA: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-asa", "lab1", "localhost"+addr, "telnet", "lab", "pass", "en", false, nil)
+	enableMask(t, tab, "lab1", "cisco-asa")
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	system, sysErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), 1000000)
+	if sysErr != nil {
+		t.Fatalf("system config: %v", sysErr)
+	}
+	if !strings.Contains(string(system), "hostname asa-system") || !strings.Contains(string(system), "context cust1") {
+		t.Errorf("system config: unexpected content: %s", system)
+	}
+
+	for _, ctx := range []string{"admin", "cust1"} {
+		b, err := store.FileRead(DeviceContextPrefix(repo, "lab1", ctx)+"0", 1000000)
+		if err != nil {
+			t.Errorf("context %s: %v", ctx, err)
+			continue
+		}
+		if !strings.Contains(string(b), "hostname "+ctx) {
+			t.Errorf("context %s: unexpected content: %s", ctx, b)
+		}
+		if strings.Contains(string(b), "hostname asa-system") {
+			t.Errorf("context %s: system output leaked into context: %s", ctx, b)
+		}
+		if strings.Contains(string(b), "s3cr3t-"+ctx) || !hasMaskedSecrets(b) {
+			t.Errorf("context %s: secret not masked: %s", ctx, b)
+		}
+	}
+}
+
+func TestCiscoASASingleContext(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2022"
+	s, listenErr := spawnServerCiscoASA(t, addr, optionsCiscoASA{})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus CiscoASA server: %v", listenErr)
+	}
+	t.Logf("TestCiscoASA: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-asa", "lab1", "localhost"+addr, "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	if _, err := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), 1000000); err != nil {
+		t.Errorf("system config: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "lab1", "contexts")); !os.IsNotExist(err) {
+		t.Errorf("single context mode: unexpected contexts dir: %v", err)
+	}
+}
+
+func TestCiscoASAContextChanges(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-asa", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000}
+	ft := NewFilterTable(logger)
+
+	d, _ := tab.GetDevice("lab1")
+	d.attr.ChangesOnly = true
+
+	save := func(ctxConfig string) string {
+		capture := dialog{
+			save:     [][]byte{[]byte("hostname asa-system\n")},
+			contexts: []namedCapture{{name: "cust1", save: [][]byte{[]byte(ctxConfig)}}},
+		}
+		if err := d.saveCommit(logger, &capture, repo, opt, ft); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		last, err := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repo)), logger)
+		if err != nil {
+			t.Fatalf("last: %v", err)
+		}
+		return filepath.Base(last)
+	}
+
+	if f := save("hostname cust1\nCryptochecksum:1111\n"); f != "lab1.0" {
+		t.Errorf("first save: %s", f)
+	}
+	if f := save("hostname cust1\nCryptochecksum:2222\n"); f != "lab1.0" {
+		t.Errorf("volatile context change: unexpected new version: %s", f)
+	}
+	if f := save("hostname cust1\naccess-list x permit ip any any\n"); f != "lab1.1" {
+		t.Errorf("context change: expected new version: %s", f)
+	}
+	b, err := store.FileRead(DeviceContextPrefix(repo, "lab1", "cust1")+"1", opt.MaxConfigLoadSize)
+	if err != nil || !strings.Contains(string(b), "access-list") {
+		t.Errorf("context copy: err=%v content=%q", err, b)
+	}
+
+	re := regexp.MustCompile(d.attr.ContextListPattern)
+	output := []byte("Context Name      Class      Interfaces           Mode         URL\n*admin            default    Gi0/0                Routed       disk0:/admin.cfg\n cust1            default    Gi0/1,Gi0/2          Routed       disk0:/cust1.cfg\nTotal active Security Contexts: 2\n")
+	names, listErr := listContexts(re, output)
+	if listErr != nil || fmt.Sprint(names) != "[admin cust1]" {
+		t.Errorf("listContexts: names=%v err=%v", names, listErr)
+	}
+	if _, err := listContexts(re, []byte(" ../x   default   Gi0/1   Routed   disk0:/x.cfg\n")); err == nil {
+		t.Errorf("listContexts: bad name: expected error")
+	}
+}
+
+func spawnServerCiscoASA(t *testing.T, addr string, options optionsCiscoASA) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopCiscoASA(t, s, handleConnectionCiscoASA, options)
+
+	return s, nil
+}
+
+func acceptLoopCiscoASA(t *testing.T, s *testServer, handler func(*testing.T, net.Conn, optionsCiscoASA), options optionsCiscoASA) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopCiscoASA: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn, options)
+	}
+
+	close(s.done)
+}
+
+func handleConnectionCiscoASA(t *testing.T, c net.Conn, options optionsCiscoASA) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send password prompt
+	if _, err := c.Write([]byte("Bogus CiscoASA server\nPassword: ")); err != nil {
+		t.Logf("handleConnectionCiscoASA: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionCiscoASA: read password error: %v", err)
+		return
+	}
+
+	enabled := false
+	context := "" // system
+
+LOOP:
+	for {
+
+		prompt := "asa>"
+		if enabled {
+			prompt = "asa#"
+			if context != "" {
+				prompt = "asa/" + context + "#"
+			}
+		}
+
+		// send command prompt
+		if _, err := c.Write([]byte(fmt.Sprintf("\n%s ", prompt))); err != nil {
+			t.Logf("handleConnectionCiscoASA: send command prompt error: %v", err)
+			return
+		}
+
+		// consume command
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionCiscoASA: read command error: %v", readErr)
+			return
+		}
+
+		cmd := strings.TrimSpace(string(buf[:n]))
+
+		var output string
+
+		switch {
+		case strings.HasPrefix(cmd, "q"), strings.HasPrefix(cmd, "ex"): // quit, exit
+			break LOOP
+		case cmd == "terminal pager 0":
+		case cmd == "enable":
+			// send password prompt
+			if _, err := c.Write([]byte("\nPassword: ")); err != nil {
+				t.Logf("handleConnectionCiscoASA: send enable password prompt error: %v", err)
+				return
+			}
+
+			// consume password
+			if _, err := c.Read(buf); err != nil {
+				t.Logf("handleConnectionCiscoASA: read enable password error: %v", err)
+				return
+			}
+
+			enabled = true
+		case cmd == "show version":
+			output = "Cisco Adaptive Security Appliance Software Version 9.8(4)\nasa up 10 days 2 hours\nHardware:   ASA5555, 16384 MB RAM\nSerial Number: FCH1234ABCD"
+		case cmd == "more system:running-config" && context == "":
+			output = ": Saved\nhostname asa-system\nenable password 8Ry2YjIyt7RRXU24 encrypted"
+			for _, ctx := range options.contexts {
+				output += fmt.Sprintf("\ncontext %s\n config-url disk0:/%s.cfg", ctx, ctx)
+			}
+			output += "\nCryptochecksum:0123abcd\n: end"
+		case cmd == "more system:running-config":
+			output = fmt.Sprintf(": Saved\nhostname %s\npasswd s3cr3t-%s encrypted\nCryptochecksum:0123abcd\n: end", context, context)
+		case cmd == "show context":
+			if len(options.contexts) < 1 {
+				output = "ERROR: Command requires multiple context mode"
+				break
+			}
+			output = "Context Name      Class      Interfaces           Mode         URL"
+			for i, ctx := range options.contexts {
+				mark := " "
+				if i == 0 {
+					mark = "*"
+				}
+				output += fmt.Sprintf("\n%s%-16s default    GigabitEthernet0/%d   Routed       disk0:/%s.cfg", mark, ctx, i, ctx)
+			}
+			output += fmt.Sprintf("\n\nTotal active Security Contexts: %d", len(options.contexts))
+		case cmd == "changeto system":
+			context = ""
+		case strings.HasPrefix(cmd, "changeto context "):
+			context = strings.TrimPrefix(cmd, "changeto context ")
+		default:
+			output = "Ignoring unknown command"
+		}
+
+		if output != "" {
+			if _, err := c.Write([]byte("\n" + output)); err != nil {
+				t.Logf("handleConnectionCiscoASA: send output error: %v", err)
+				return
+			}
+		}
+	}
+
+	// send bye
+	if _, err := c.Write([]byte("\nbye\n")); err != nil {
+		t.Logf("handleConnectionCiscoASA: send bye error: %v", err)
+		return
+	}
+}
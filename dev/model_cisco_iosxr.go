@@ -26,9 +26,18 @@ func registerModelCiscoIOSXR(logger hasPrintf, t *DeviceTable) {
 	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'sh run'
 	a.QuoteSentCommandsFormat = `!![%s]`
 	a.LineFilter = "iosxr" // line filter name - applied to every saved line
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{` uptime is `, `^!! Last configuration change at `}
 
 	m := &Model{name: "cisco-iosxr"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`Cisco IOS XR Software`, `RP/\d+/\S+:\S+#`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^hostname (\S+)`,
+		FactVersion:  `Cisco IOS XR Software, Version (\S+)`,
+		FactPlatform: `(?m)^cisco (.+?) \([^)]*\) processor`,
+		FactUptime:   `(?m)^\S+ uptime is (.+?)\s*$`,
+	}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelCiscoIOSXR: %v", err)
 	}
@@ -23,6 +23,7 @@ func registerModelCiscoNGA(logger hasPrintf, t *DeviceTable) {
 
 	m := &Model{name: "cisco-nga"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`Cisco Netflow Generation Appliance`}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelCiscoNGA: %v", err)
 	}
@@ -0,0 +1,95 @@
+package dev
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+// RegisterCustomModels validates user-defined models and registers them into the device table,
+// replacing any previously loaded user-defined models.
+// On validation error, the device table is left untouched.
+func RegisterCustomModels(logger hasPrintf, t *DeviceTable, models []conf.ModelConfig) error {
+	list, err := validateCustomModels(models)
+	if err != nil {
+		return err
+	}
+	return t.ReplaceCustomModels(list, logger)
+}
+
+func validateCustomModels(models []conf.ModelConfig) ([]*Model, error) {
+	var errs []string
+	var list []*Model
+	seen := map[string]bool{}
+
+	for i, mc := range models {
+		name := strings.TrimSpace(mc.Name)
+		if name == "" {
+			errs = append(errs, fmt.Sprintf("model #%d: missing name", i))
+			continue
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Sprintf("model '%s': duplicate name", name))
+			continue
+		}
+		seen[name] = true
+
+		if patErr := validateAttrPatterns(&mc.Attr); patErr != nil {
+			errs = append(errs, fmt.Sprintf("model '%s': %v", name, patErr))
+			continue
+		}
+
+		if fpErr := validateFingerprints(mc.Fingerprints); fpErr != nil {
+			errs = append(errs, fmt.Sprintf("model '%s': %v", name, fpErr))
+			continue
+		}
+
+		if factsErr := validateFacts(mc.Facts); factsErr != nil {
+			errs = append(errs, fmt.Sprintf("model '%s': %v", name, factsErr))
+			continue
+		}
+
+		list = append(list, &Model{name: name, defaultAttr: mc.Attr, custom: true, fingerprints: mc.Fingerprints, facts: mc.Facts})
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid models: %s", strings.Join(errs, "; "))
+	}
+
+	return list, nil
+}
+
+// validateAttrPatterns checks that all prompt patterns are valid regular expressions.
+func validateAttrPatterns(a *conf.DevAttributes) error {
+	patterns := []struct {
+		field   string
+		pattern string
+	}{
+		{"UsernamePromptPattern", a.UsernamePromptPattern},
+		{"PasswordPromptPattern", a.PasswordPromptPattern},
+		{"EnablePasswordPromptPattern", a.EnablePasswordPromptPattern},
+		{"DisabledPromptPattern", a.DisabledPromptPattern},
+		{"EnabledPromptPattern", a.EnabledPromptPattern},
+		{"PostLoginPromptPattern", a.PostLoginPromptPattern},
+		{"ContextListPattern", a.ContextListPattern},
+		{"DisablePagerErrorPattern", a.DisablePagerErrorPattern},
+	}
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p.pattern); err != nil {
+			return fmt.Errorf("bad %s '%s': %v", p.field, p.pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateFingerprints checks that all autodetection fingerprints are valid regular expressions.
+func validateFingerprints(fingerprints []string) error {
+	for _, f := range fingerprints {
+		if _, err := regexp.Compile(f); err != nil {
+			return fmt.Errorf("bad fingerprint '%s': %v", f, err)
+		}
+	}
+	return nil
+}
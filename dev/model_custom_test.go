@@ -0,0 +1,92 @@
+package dev
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"gopkg.in/yaml.v3"
+)
+
+const customModelsYAML = `
+models:
+- name: acme-os
+  attr:
+    needloginchat: true
+    usernamepromptpattern: 'login:\s*$'
+    passwordpromptpattern: 'Password:\s*$'
+    disabledpromptpattern: '\S+>\s*$'
+    enabledpromptpattern: '\S+#\s*$'
+    commandlist:
+    - show config
+`
+
+func TestCustomModels(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	var f conf.ModelFile
+	if err := yaml.Unmarshal([]byte(customModelsYAML), &f); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if err := RegisterCustomModels(logger, tab, f.Models); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	m, getErr := tab.GetModel("acme-os")
+	if getErr != nil {
+		t.Fatalf("get model: %v", getErr)
+	}
+	if !m.defaultAttr.NeedLoginChat || len(m.defaultAttr.CommandList) != 1 {
+		t.Errorf("unexpected attributes: %v", m.defaultAttr)
+	}
+	if m.defaultAttr.ErrlogHistSize != conf.NewDevAttr().ErrlogHistSize {
+		t.Errorf("missing default ErrlogHistSize: got=%d", m.defaultAttr.ErrlogHistSize)
+	}
+
+	if err := CreateDevice(tab, logger, "acme-os", "lab1", "localhost", "ssh", "lab", "pass", "", false, nil); err != nil {
+		t.Errorf("create device: %v", err)
+	}
+
+	// reload with another set: acme-os must vanish
+	if err := RegisterCustomModels(logger, tab, []conf.ModelConfig{{Name: "other", Attr: conf.NewDevAttr()}}); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := tab.GetModel("acme-os"); err == nil {
+		t.Errorf("reload: stale model acme-os still registered")
+	}
+	if list := tab.ListCustomModels(); len(list) != 1 || list[0] != "other" {
+		t.Errorf("reload: unexpected custom models: %v", list)
+	}
+}
+
+func TestCustomModelsInvalid(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	bad := conf.NewDevAttr()
+	bad.EnabledPromptPattern = `(unclosed`
+
+	expectCustomModelError(t, tab, []conf.ModelConfig{{Name: "cisco-ios"}}, "built-in")
+	expectCustomModelError(t, tab, []conf.ModelConfig{{Name: "x"}, {Name: "x"}}, "duplicate")
+	expectCustomModelError(t, tab, []conf.ModelConfig{{Name: ""}}, "missing name")
+	expectCustomModelError(t, tab, []conf.ModelConfig{{Name: "x", Attr: bad}}, "EnabledPromptPattern")
+
+	if list := tab.ListCustomModels(); len(list) != 0 {
+		t.Errorf("failed registration must not change table: %v", list)
+	}
+}
+
+func expectCustomModelError(t *testing.T, tab *DeviceTable, models []conf.ModelConfig, want string) {
+	err := RegisterCustomModels(&testLogger{t}, tab, models)
+	if err == nil {
+		t.Errorf("models %v: expected error containing '%s'", models, want)
+		return
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("models %v: expected error containing '%s', got: %v", models, want, err)
+	}
+}
@@ -31,6 +31,7 @@ func registerModelDatacomDmswitch(logger hasPrintf, t *DeviceTable) {
 
 	m := &Model{name: "dmswitch"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`(?i)DmSwitch`}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelDatacomDmswitch: %v", err)
 	}
@@ -0,0 +1,180 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelF5BigIP(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	promptPattern := `\(tmos\)#\s*$` // admin@(bigip1)(cfg-sync Standalone)(Active)(/Common)(tmos)#
+
+	a.NeedLoginChat = true
+	a.NeedPagingOff = true
+	a.UsernamePromptPattern = `(?:login|Username):\s*$`
+	a.PasswordPromptPattern = `Password:\s*$`
+	a.DisabledPromptPattern = promptPattern
+	a.EnabledPromptPattern = promptPattern
+	a.DisablePagerCommand = "modify cli preference pager disabled display-threshold 0"
+	a.CommandList = []string{"show sys version", "show running-config recursive"}
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 30 * time.Second   // larger timeout for slow 'show running-config recursive'
+	a.CommandMatchTimeout = 120 * time.Second // larger timeout for slow 'show running-config recursive', also full UCS request timeout
+	a.QuoteSentCommandsFormat = `#[%s]`
+	a.ExportUCS = true
+	a.MaxArtifactFiles = 3        // UCS archives are large
+	a.MaxArtifactSize = 500000000 // 500M
+
+	m := &Model{name: "f5-bigip"}
+	m.defaultAttr = a
+	m.artifacts = fetchF5UCS
+	m.facts = map[string]string{
+		FactHostname: `(?m)^\s+hostname (\S+)`,
+		FactVersion:  `(?m)^\s+Version\s+(\S+)`,
+		FactPlatform: `(?m)^\s+Product\s+(.+?)\s*$`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelF5BigIP: %v", err)
+	}
+}
+
+// f5UCSName is the name of the UCS archive created on the device for download.
+const f5UCSName = "jazigo.ucs"
+
+// f5ChunkSize is the size of download chunks requested from iControl REST file transfer.
+var f5ChunkSize = 1024 * 1024
+
+// fetchF5UCS creates an UCS archive through iControl REST, downloads it as binary artifact,
+// then removes it from the device.
+func fetchF5UCS(d *Device, logger hasPrintf, capture *dialog) error {
+
+	if !d.attr.ExportUCS {
+		return nil
+	}
+
+	u, urlErr := apiBaseURL(d.apiAddress())
+	if urlErr != nil {
+		return fmt.Errorf("fetchF5UCS: %v", urlErr)
+	}
+
+	client := d.apiClient()
+
+	save, _ := json.Marshal(map[string]string{"command": "save", "name": f5UCSName})
+	if _, _, err := f5Request(d, client, "POST", u, "/mgmt/tm/sys/ucs", save, nil); err != nil {
+		return fmt.Errorf("fetchF5UCS: save ucs: %v", err)
+	}
+
+	defer func() {
+		if _, _, err := f5Request(d, client, "DELETE", u, "/mgmt/tm/sys/ucs/"+f5UCSName, nil, nil); err != nil {
+			logger.Printf("fetchF5UCS: dev '%s': delete ucs: %v", d.ID, err)
+		}
+	}()
+
+	var ucs []byte
+	size := -1 // total size reported by first chunk
+
+	for {
+		start := len(ucs)
+		header := http.Header{"Content-Range": {fmt.Sprintf("%d-%d/0", start, start+f5ChunkSize-1)}}
+
+		chunk, h, err := f5Request(d, client, "GET", u, "/mgmt/shared/file-transfer/ucs-downloads/"+f5UCSName, nil, header)
+		if err != nil {
+			return fmt.Errorf("fetchF5UCS: download ucs: %v", err)
+		}
+
+		end, total, rangeErr := f5ContentRange(h.Get("Content-Range"))
+		if rangeErr != nil {
+			return fmt.Errorf("fetchF5UCS: download ucs: %v", rangeErr)
+		}
+		if end < start || end >= total {
+			return fmt.Errorf("fetchF5UCS: download ucs: range %d-%d/%d: no progress from offset %d", start, end, total, start)
+		}
+		if size < 0 {
+			size = total
+		} else if total != size {
+			return fmt.Errorf("fetchF5UCS: download ucs: range %d-%d/%d: size changed from %d", start, end, total, size)
+		}
+		if max := d.attr.MaxArtifactSize; max > 0 && int64(total) > max {
+			return fmt.Errorf("fetchF5UCS: download ucs: size %d exceeds maxartifactsize=%d", total, max)
+		}
+		if len(chunk) != end-start+1 {
+			return fmt.Errorf("fetchF5UCS: download ucs: range %d-%d/%d: got %d bytes", start, end, total, len(chunk))
+		}
+
+		ucs = append(ucs, chunk...)
+
+		d.debugf("fetchF5UCS: downloaded %d/%d bytes", len(ucs), total)
+
+		if len(ucs) >= total {
+			break
+		}
+	}
+
+	capture.artifacts = append(capture.artifacts, artifact{name: "ucs", data: ucs, contentType: "application/octet-stream"})
+
+	return nil
+}
+
+// f5Request issues an iControl REST request with basic authentication.
+func f5Request(d *Device, client *http.Client, method string, base *url.URL, path string, body []byte, header http.Header) ([]byte, http.Header, error) {
+	u := *base
+	u.Path = path
+
+	req, reqErr := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if reqErr != nil {
+		return nil, nil, reqErr
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(d.Username(), d.LoginPassword)
+
+	resp, doErr := client.Do(req)
+	if doErr != nil {
+		return nil, nil, doErr
+	}
+	defer resp.Body.Close()
+
+	respBody, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, nil, readErr
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("%s %s: http status=%d: %s", method, path, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+
+	return respBody, resp.Header, nil
+}
+
+// f5ContentRange parses the Content-Range response header from iControl REST file transfer: start-end/total
+func f5ContentRange(h string) (int, int, error) {
+	slash := strings.Index(h, "/")
+	dash := strings.Index(h, "-")
+	if slash < 0 || dash < 0 || dash > slash {
+		return 0, 0, fmt.Errorf("bad Content-Range: '%s'", h)
+	}
+	end, endErr := strconv.Atoi(h[dash+1 : slash])
+	if endErr != nil {
+		return 0, 0, fmt.Errorf("bad Content-Range: '%s': %v", h, endErr)
+	}
+	total, totalErr := strconv.Atoi(h[slash+1:])
+	if totalErr != nil {
+		return 0, 0, fmt.Errorf("bad Content-Range: '%s': %v", h, totalErr)
+	}
+	return end, total, nil
+}
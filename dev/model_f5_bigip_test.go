@@ -0,0 +1,326 @@
+package dev
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+var f5TestUCS = []byte("bogus ucs archive")
+
+func TestF5BigIP(t *testing.T) {
+
+	// launch bogus test servers
+	addr := ":2023"
+	s, listenErr := spawnServerF5BigIP(t, addr)
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus F5BigIP server: %v", listenErr)
+	}
+	t.Logf("TestF5BigIP: server running on %s", addr)
+
+	rest := &f5RestStub{}
+	api := httptest.NewTLSServer(rest)
+	defer api.Close()
+
+	saveChunk := f5ChunkSize
+	f5ChunkSize = 5 // force multiple chunks
+	defer func() { f5ChunkSize = saveChunk }()
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"})
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "f5-bigip", ID: "lab1", HostPort: "localhost" + addr, Transports: "telnet", LoginUser: "admin", LoginPassword: "pass",
+		Attr: conf.AttrOverrides{"apihostport": strings.TrimPrefix(api.URL, "https://"), "apiinsecuretls": true, "maxartifactfiles": 2, "secretmask": "f5-bigip"}}
+	d, _, newErr := NewDeviceFromConf(tab, logger, &cfg)
+	if newErr != nil {
+		t.Fatalf("new device: %v", newErr)
+	}
+	if err := tab.SetDevice(d); err != nil {
+		t.Fatalf("set device: %v", err)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	for i := 0; i < 3; i++ {
+		good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+		if good != 1 || bad != 0 || skip != 0 {
+			t.Errorf("scan %d: good=%d bad=%d skip=%d", i, good, bad, skip)
+		}
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.2"), 1000000)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if !strings.Contains(string(config), "hostname bigip1.example.com") || strings.Contains(string(config), "$6$abc") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+
+	// artifacts keep their own retention
+	_, configs, _ := store.ListConfig(DeviceFullPrefix(repo, "lab1"), logger)
+	_, archives, _ := store.ListConfig(DeviceArtifactPrefix(repo, "lab1", "ucs"), logger)
+	if len(configs) != 3 || len(archives) != 2 {
+		t.Errorf("retention: configs=%v archives=%v", configs, archives)
+	}
+
+	ucs, ucsErr := store.FileRead(DeviceArtifactPrefix(repo, "lab1", "ucs")+"2", 1000000)
+	if ucsErr != nil {
+		t.Fatalf("ucs: %v", ucsErr)
+	}
+	if !bytes.Equal(ucs, f5TestUCS) {
+		t.Errorf("ucs: wanted=%q got=%q", f5TestUCS, ucs)
+	}
+
+	if saved, deleted := rest.counters(); saved != 3 || deleted != 3 {
+		t.Errorf("ucs on device: saved=%d deleted=%d", saved, deleted)
+	}
+
+	if f, err := tab.GetFacts("lab1"); err != nil || f.Hostname != "bigip1.example.com" || f.Version != "15.1.8" || f.Platform != "BIG-IP" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+}
+
+func TestF5ContentRange(t *testing.T) {
+	if end, total, err := f5ContentRange("0-4/17"); err != nil || end != 4 || total != 17 {
+		t.Errorf("content range: end=%d total=%d err=%v", end, total, err)
+	}
+	for _, h := range []string{"", "0-4", "4/17", "0-x/17"} {
+		if _, _, err := f5ContentRange(h); err == nil {
+			t.Errorf("bad content range '%s': expected error", h)
+		}
+	}
+}
+
+// f5RestStub is a bogus iControl REST server for UCS save, download and delete.
+type f5RestStub struct {
+	lock    sync.Mutex
+	saved   int
+	deleted int
+}
+
+func (s *f5RestStub) counters() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.saved, s.deleted
+}
+
+func (s *f5RestStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"code":401,"message":"Authentication failed."}`)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/mgmt/tm/sys/ucs":
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"command":"save"`) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.saved++
+		io.WriteString(w, `{"kind":"tm:sys:ucs:runstate","command":"save","name":"jazigo.ucs"}`)
+	case r.Method == "GET" && r.URL.Path == "/mgmt/shared/file-transfer/ucs-downloads/jazigo.ucs":
+		var start, end, total int
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d/%d", &start, &end, &total); err != nil || start >= len(f5TestUCS) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if end >= len(f5TestUCS) {
+			end = len(f5TestUCS) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("%d-%d/%d", start, end, len(f5TestUCS)))
+		w.Write(f5TestUCS[start : end+1])
+	case r.Method == "DELETE" && r.URL.Path == "/mgmt/tm/sys/ucs/jazigo.ucs":
+		s.deleted++
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func spawnServerF5BigIP(t *testing.T, addr string) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopF5BigIP(t, s, handleConnectionF5BigIP)
+
+	return s, nil
+}
+
+func acceptLoopF5BigIP(t *testing.T, s *testServer, handler func(*testing.T, net.Conn)) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopF5BigIP: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn)
+	}
+
+	close(s.done)
+}
+
+func handleConnectionF5BigIP(t *testing.T, c net.Conn) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send username prompt
+	if _, err := c.Write([]byte("Bogus F5BigIP server\nlogin: ")); err != nil {
+		t.Logf("handleConnectionF5BigIP: send username prompt error: %v", err)
+		return
+	}
+
+	// consume username
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionF5BigIP: read username error: %v", err)
+		return
+	}
+
+	// send password prompt
+	if _, err := c.Write([]byte("\nPassword: ")); err != nil {
+		t.Logf("handleConnectionF5BigIP: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionF5BigIP: read password error: %v", err)
+		return
+	}
+
+LOOP:
+	for {
+		// send command prompt
+		if _, err := c.Write([]byte("\nadmin@(bigip1)(cfg-sync Standalone)(Active)(/Common)(tmos)# ")); err != nil {
+			t.Logf("handleConnectionF5BigIP: send command prompt error: %v", err)
+			return
+		}
+
+		// consume command
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionF5BigIP: read command error: %v", readErr)
+			return
+		}
+
+		cmd := strings.TrimSpace(string(buf[:n]))
+
+		var output string
+
+		switch cmd {
+		case "quit", "exit":
+			break LOOP
+		case "modify cli preference pager disabled display-threshold 0":
+		case "show sys version":
+			output = "Sys::Version\nMain Package\n  Product     BIG-IP\n  Version     15.1.8\n  Build       0.0.7"
+		case "show running-config recursive":
+			output = "auth user admin {\n    encrypted-password $6$abc\n    role admin\n}\nsys global-settings {\n    hostname bigip1.example.com\n}"
+		default:
+			output = "Syntax Error: unexpected argument"
+		}
+
+		if output != "" {
+			if _, err := c.Write([]byte("\n" + output)); err != nil {
+				t.Logf("handleConnectionF5BigIP: send output error: %v", err)
+				return
+			}
+		}
+	}
+
+	// send bye
+	if _, err := c.Write([]byte("\nbye\n")); err != nil {
+		t.Logf("handleConnectionF5BigIP: send bye error: %v", err)
+		return
+	}
+}
+
+func TestF5UCSBadRange(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	table := []struct {
+		name  string
+		chunk func(start int) (string, []byte) // Content-Range and body for chunk at offset start
+	}{
+		{"zero-length", func(start int) (string, []byte) { return fmt.Sprintf("%d-%d/17", start, start-1), nil }},
+		{"stale", func(start int) (string, []byte) { return "0-4/17", f5TestUCS[:5] }},
+		{"past total", func(start int) (string, []byte) { return fmt.Sprintf("%d-%d/17", start, start+20), make([]byte, 21) }},
+		{"growing", func(start int) (string, []byte) {
+			return fmt.Sprintf("%d-%d/%d", start, start+4, start+100), make([]byte, 5)
+		}},
+		{"too large", func(start int) (string, []byte) { return fmt.Sprintf("%d-%d/2000000", start, start+4), make([]byte, 5) }},
+	}
+
+	for _, c := range table {
+		chunk := c.chunk
+		api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" {
+				return // save and delete
+			}
+			var start, end, total int
+			fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d/%d", &start, &end, &total)
+			h, b := chunk(start)
+			w.Header().Set("Content-Range", h)
+			w.Write(b)
+		}))
+
+		cfg := conf.DevConfig{Model: "f5-bigip", ID: "lab1", HostPort: "localhost", LoginUser: "admin", LoginPassword: "pass",
+			Attr: conf.AttrOverrides{"apihostport": strings.TrimPrefix(api.URL, "https://"), "apiinsecuretls": true, "maxartifactsize": 1000000}}
+		d, _, newErr := NewDeviceFromConf(tab, logger, &cfg)
+		if newErr != nil {
+			t.Fatalf("new device: %v", newErr)
+		}
+
+		done := make(chan error)
+		go func() { done <- fetchF5UCS(d, logger, &dialog{}) }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%s: expected error", c.name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: download loop did not stop", c.name)
+		}
+
+		api.Close()
+	}
+}
@@ -39,9 +39,20 @@ func registerModelFortiOS(logger hasPrintf, t *DeviceTable) {
 	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow 'sh run'
 	a.CommandMatchTimeout = 60 * time.Second // larger timeout for slow 'sh run'
 	a.QuoteSentCommandsFormat = `##[%s]`
+	a.RestoreCommands = []string{"{config}"} // paste line by line
+	a.RestoreConfigCommand = "show"
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^System time: `, `^Uptime: `, `^#conf_file_ver=`}
 
 	m := &Model{name: "fortios"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`FortiGate`, `FortiOS`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^Hostname: (\S+)`,
+		FactVersion:  `(?m)^Version: \S+ (v[^,\s]+)`,
+		FactSerial:   `(?m)^Serial-Number: (\S+)`,
+		FactPlatform: `(?m)^Version: (\S+) v`,
+	}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelFortiOS: %v", err)
 	}
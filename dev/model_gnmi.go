@@ -0,0 +1,213 @@
+package dev
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelGNMI(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.CommandList = nil                      // gNMI Get requests are issued by fetchGNMI
+	a.APIPaths = []string{"/"}               // whole configuration tree
+	a.CommandMatchTimeout = 60 * time.Second // full Get request timeout: large configs
+
+	m := &Model{name: "gnmi"}
+	m.defaultAttr = a
+	m.apiFetch = fetchGNMI
+	m.facts = map[string]string{
+		FactHostname: `"(?:openconfig-system:)?hostname": "([^"]+)"`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelGNMI: %v", err)
+	}
+}
+
+// fetchGNMI issues a gNMI Get for configuration paths, then saves a canonical JSON document
+// mapping every update path to its value. Object keys are sorted, so diffs stay stable between fetches.
+func fetchGNMI(d *Device, logger hasPrintf, capture *dialog, opt *conf.AppConfig) (string, int, error) {
+	const transport = "grpc"
+
+	var paths []*gpb.Path
+	for _, p := range d.attr.APIPaths {
+		path, pathErr := gnmiPath(p)
+		if pathErr != nil {
+			return transport, fetchErrCommands, fmt.Errorf("fetchGNMI: %v", pathErr)
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) < 1 {
+		return transport, fetchErrCommands, fmt.Errorf("fetchGNMI: no path to get")
+	}
+
+	creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: d.attr.APIInsecureTLS})
+
+	conn, dialErr := grpc.Dial(d.HostPort, grpc.WithTransportCredentials(creds))
+	if dialErr != nil {
+		return transport, fetchErrTransp, fmt.Errorf("fetchGNMI: %v", dialErr)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.attr.CommandMatchTimeout)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "username", d.Username(), "password", d.LoginPassword)
+
+	resp, getErr := gpb.NewGNMIClient(conn).Get(ctx, &gpb.GetRequest{Path: paths, Type: gpb.GetRequest_CONFIG, Encoding: gpb.Encoding_JSON_IETF})
+	if getErr != nil {
+		switch status.Code(getErr) {
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return transport, fetchErrLogin, fmt.Errorf("fetchGNMI: %v", getErr)
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return transport, fetchErrTransp, fmt.Errorf("fetchGNMI: %v", getErr)
+		}
+		return transport, fetchErrCommands, fmt.Errorf("fetchGNMI: %v", getErr)
+	}
+
+	doc := map[string]interface{}{}
+	for _, n := range resp.GetNotification() {
+		for _, u := range n.GetUpdate() {
+			name := gnmiPathString(n.GetPrefix(), u.GetPath())
+			value, valueErr := gnmiValue(u.GetVal())
+			if valueErr != nil {
+				return transport, fetchErrCommands, fmt.Errorf("fetchGNMI: path '%s': %v", name, valueErr)
+			}
+			doc[name] = value
+		}
+	}
+
+	output, encodeErr := canonicalJSON(doc)
+	if encodeErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchGNMI: %v", encodeErr)
+	}
+
+	if saveErr := d.save(logger, capture, "", output); saveErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchGNMI: could not save configuration: %v", saveErr)
+	}
+
+	return transport, fetchErrNone, nil
+}
+
+// gnmiValue decodes a JSON value from an update. Numbers keep their original text.
+func gnmiValue(v *gpb.TypedValue) (interface{}, error) {
+	raw := v.GetJsonIetfVal()
+	if raw == nil {
+		raw = v.GetJsonVal()
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("value is not JSON encoded: %T", v.GetValue())
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("bad JSON value: %v", err)
+	}
+	return value, nil
+}
+
+// canonicalJSON encodes a decoded JSON value as indented text with sorted object keys.
+func canonicalJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gnmiPath parses a gNMI path in string form: /interfaces/interface[name=eth0]/config
+func gnmiPath(s string) (*gpb.Path, error) {
+	path := &gpb.Path{}
+	for _, e := range gnmiSplitPath(strings.TrimPrefix(s, "/")) {
+		if e == "" {
+			return nil, fmt.Errorf("bad path '%s': empty element", s)
+		}
+		elem := &gpb.PathElem{}
+		name := e
+		if i := strings.IndexByte(e, '['); i >= 0 {
+			name = e[:i]
+			keys := e[i:]
+			for keys != "" {
+				end := strings.IndexByte(keys, ']')
+				eq := strings.IndexByte(keys, '=')
+				if keys[0] != '[' || end < 0 || eq < 0 || eq > end {
+					return nil, fmt.Errorf("bad path '%s': bad key in element '%s'", s, e)
+				}
+				if elem.Key == nil {
+					elem.Key = map[string]string{}
+				}
+				elem.Key[keys[1:eq]] = keys[eq+1 : end]
+				keys = keys[end+1:]
+			}
+		}
+		elem.Name = name
+		path.Elem = append(path.Elem, elem)
+	}
+	return path, nil
+}
+
+// gnmiSplitPath splits path elements on slashes found outside key brackets.
+func gnmiSplitPath(s string) []string {
+	if s == "" {
+		return nil // root
+	}
+	var elems []string
+	var depth, start int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '/':
+			if depth == 0 {
+				elems = append(elems, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(elems, s[start:])
+}
+
+// gnmiPathString formats prefix plus path in string form, with sorted keys.
+func gnmiPathString(prefix, path *gpb.Path) string {
+	var elems []*gpb.PathElem
+	elems = append(elems, prefix.GetElem()...)
+	elems = append(elems, path.GetElem()...)
+
+	var buf strings.Builder
+	for _, e := range elems {
+		buf.WriteByte('/')
+		buf.WriteString(e.GetName())
+		keys := make([]string, 0, len(e.GetKey()))
+		for k := range e.GetKey() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "[%s=%s]", k, e.GetKey()[k])
+		}
+	}
+	if buf.Len() == 0 {
+		return "/"
+	}
+	return buf.String()
+}
@@ -0,0 +1,166 @@
+package dev
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+// keys deliberately out of order
+const gnmiTestConfig = `{"openconfig-system:system":{"config":{"hostname":"srl1"},"aaa":{"authentication":{"users":{"user":[{"username":"admin","config":{"username":"admin","password-hashed":"$6$abc$def"}}]}}}},"openconfig-interfaces:interfaces":{"interface":[{"name":"eth0","config":{"mtu":9000,"name":"eth0"}}]}}`
+
+func TestGNMI(t *testing.T) {
+
+	addr, stop := spawnServerGNMI(t)
+	defer stop()
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "gnmi", ID: "lab1", HostPort: addr, LoginUser: "admin", LoginPassword: "pass",
+		Attr: conf.AttrOverrides{"apiinsecuretls": true, "secretmask": "gnmi", "apipaths": []string{"/", "/interfaces/interface[name=eth0]/config"}}}
+	d, _, newErr := NewDeviceFromConf(tab, logger, &cfg)
+	if newErr != nil {
+		t.Fatalf("new device: %v", newErr)
+	}
+	if err := tab.SetDevice(d); err != nil {
+		t.Fatalf("set device: %v", err)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"}
+
+	result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger))
+	if result.Code != fetchErrNone || result.Transport != "grpc" {
+		t.Fatalf("gnmi: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), opt.MaxConfigLoadSize)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	c := string(config)
+	if !strings.Contains(c, `"/interfaces/interface[name=eth0]/config": {`) || !strings.Contains(c, `"mtu": 9000`) || strings.Contains(c, "$6$abc$def") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+	if i, j := strings.Index(c, `"openconfig-interfaces:interfaces"`), strings.Index(c, `"openconfig-system:system"`); i < 0 || j < 0 || i > j {
+		t.Errorf("config: keys not sorted: %s", config)
+	}
+
+	if f, err := tab.LoadFacts("lab1", repo, opt, logger); err != nil || f.Hostname != "srl1" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+
+	// bad credentials
+	d.LoginPassword = "wrong"
+	if result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger)); result.Code != fetchErrLogin {
+		t.Errorf("bad credentials: code=%d msg=[%s]", result.Code, result.Msg)
+	}
+}
+
+func TestGNMIPath(t *testing.T) {
+	table := []string{
+		"/",
+		"/interfaces",
+		"/interfaces/interface[name=eth0]/config",
+		"/network-instances/network-instance[name=default]/protocols/protocol[identifier=BGP][name=bgp]",
+		"/a/b[k=x/y]/c",
+	}
+	for _, p := range table {
+		path, err := gnmiPath(p)
+		if err != nil {
+			t.Errorf("path '%s': %v", p, err)
+			continue
+		}
+		if got := gnmiPathString(nil, path); got != p {
+			t.Errorf("path: wanted=%s got=%s", p, got)
+		}
+	}
+	for _, p := range []string{"/a//b", "/a[k]", "/a[k=v"} {
+		if _, err := gnmiPath(p); err == nil {
+			t.Errorf("bad path '%s': expected error", p)
+		}
+	}
+}
+
+// gnmiStub is a bogus gNMI server answering Get for configuration.
+type gnmiStub struct {
+	gpb.UnimplementedGNMIServer
+}
+
+func (s *gnmiStub) Get(ctx context.Context, req *gpb.GetRequest) (*gpb.GetResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if u, p := md.Get("username"), md.Get("password"); len(u) != 1 || len(p) != 1 || u[0] != "admin" || p[0] != "pass" {
+		return nil, status.Error(codes.Unauthenticated, "bad credentials")
+	}
+	if req.GetType() != gpb.GetRequest_CONFIG || req.GetEncoding() != gpb.Encoding_JSON_IETF {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported type=%v encoding=%v", req.GetType(), req.GetEncoding())
+	}
+
+	var notifications []*gpb.Notification
+	for _, p := range req.GetPath() {
+		value := gnmiTestConfig
+		if len(p.GetElem()) > 0 {
+			value = `{"name":"eth0","mtu":9000}`
+		}
+		notifications = append(notifications, &gpb.Notification{
+			Timestamp: time.Now().UnixNano(),
+			Update:    []*gpb.Update{{Path: p, Val: &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(value)}}}},
+		})
+	}
+
+	return &gpb.GetResponse{Notification: notifications}, nil
+}
+
+func spawnServerGNMI(t *testing.T) (string, func()) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("gnmi listen: %v", err)
+	}
+	s := grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(testCertificate(t))))
+	gpb.RegisterGNMIServer(s, &gnmiStub{})
+	go s.Serve(ln)
+	return ln.Addr().String(), s.Stop
+}
+
+// testCertificate creates a self-signed TLS certificate for localhost.
+func testCertificate(t *testing.T) *tls.Certificate {
+	key, keyErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyErr != nil {
+		t.Fatalf("certificate key: %v", keyErr)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, certErr := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if certErr != nil {
+		t.Fatalf("certificate: %v", certErr)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,43 @@
+package dev
+
+import (
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelHPEComware(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.NeedLoginChat = true
+	a.NeedPagingOff = true
+	a.UsernamePromptPattern = `(?:[Ll]ogin|Username):\s*$`
+	a.PasswordPromptPattern = `[Pp]assword:\s*$`
+	a.DisabledPromptPattern = `<[^<>\s]+>\s*$`    // user view: <hostname>
+	a.EnabledPromptPattern = `\[[^\[\]\s]+\]\s*$` // system view: [hostname]
+	// Comware 7 accepts only the former, Comware 5 only the latter
+	a.DisablePagerCommandList = []string{"screen-length disable", "screen-length 0 temporary"}
+	a.DisablePagerErrorPattern = `% (?:Unrecognized|Incomplete|Too many parameters|Wrong parameter)`
+	a.CommandList = []string{"display version", "display current-configuration"}
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 15 * time.Second  // larger timeout for slow 'disp curr'
+	a.CommandMatchTimeout = 25 * time.Second // larger timeout for slow 'disp curr'
+	a.QuoteSentCommandsFormat = `##[%s]`
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{` uptime is `, `^Last reboot reason`}
+
+	m := &Model{name: "hpe-comware"}
+	m.defaultAttr = a
+	m.fingerprints = []string{`(?:HPE?|H3C) Comware (?:Platform )?Software`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^\s*sysname (\S+)`,
+		FactVersion:  `Comware (?:Platform )?Software, Version ([^,\s]+(?:, Release \S+)?)`,
+		FactPlatform: `(?m)^(?:HPE?|H3C) (.+?) uptime is `,
+		FactUptime:   `(?m)^(?:HPE?|H3C) .* uptime is (.+?)\s*$`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelHPEComware: %v", err)
+	}
+}
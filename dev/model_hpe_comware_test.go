@@ -0,0 +1,219 @@
+package dev
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+type optionsHPEComware struct {
+	comware5 bool // rejects 'screen-length disable'
+}
+
+func TestHPEComware7(t *testing.T) {
+	testHPEComware(t, ":2030", optionsHPEComware{})
+}
+
+func TestHPEComware5(t *testing.T) {
+	testHPEComware(t, ":2031", optionsHPEComware{comware5: true})
+}
+
+func testHPEComware(t *testing.T, addr string, options optionsHPEComware) {
+
+	// launch bogus test server
+	s, listenErr := spawnServerHPEComware(t, addr, options)
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus HPEComware server: %v", listenErr)
+	}
+	t.Logf("TestHPEComware: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "hpe-comware", "lab1", "localhost"+addr, "telnet", "admin", "pass", "", false, nil)
+	enableMask(t, tab, "lab1", "hpe-comware")
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), 1000000)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if c := string(config); !strings.Contains(c, " sysname core1") || strings.Contains(c, "$h$6$abc") || strings.Contains(c, "---- More ----") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+
+	if f, err := tab.GetFacts("lab1"); err != nil || f.Hostname != "core1" || f.Version != "7.1.045, Release 2432P03" || f.Platform != "5130-24G-4SFP+ EI Switch" || f.Uptime != "1 week, 2 days, 3 hours, 4 minutes" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+}
+
+func TestHPEComwarePagerRejected(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2031"
+	s, listenErr := spawnServerHPEComware(t, addr, optionsHPEComware{comware5: true})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus HPEComware server: %v", listenErr)
+	}
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "hpe-comware", ID: "lab1", HostPort: "localhost" + addr, Transports: "telnet", LoginUser: "admin", LoginPassword: "pass",
+		Attr: conf.AttrOverrides{"disablepagercommandlist": []string{"screen-length disable"}}}
+	d, _, newErr := NewDeviceFromConf(tab, logger, &cfg)
+	if newErr != nil {
+		t.Fatalf("new device: %v", newErr)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	if result := d.fetch(logger, 0, repo, &conf.AppConfig{MaxConfigFiles: 10}, NewFilterTable(logger)); result.Code != fetchErrPager {
+		t.Errorf("rejected pager: code=%d msg=[%s]", result.Code, result.Msg)
+	}
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+}
+
+func spawnServerHPEComware(t *testing.T, addr string, options optionsHPEComware) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopHPEComware(t, s, handleConnectionHPEComware, options)
+
+	return s, nil
+}
+
+func acceptLoopHPEComware(t *testing.T, s *testServer, handler func(*testing.T, net.Conn, optionsHPEComware), options optionsHPEComware) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopHPEComware: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn, options)
+	}
+
+	close(s.done)
+}
+
+func handleConnectionHPEComware(t *testing.T, c net.Conn, options optionsHPEComware) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send username prompt
+	if _, err := c.Write([]byte("\n******************************************************************************\n* Copyright (c) 2010-2020 Hewlett Packard Enterprise Development LP          *\n******************************************************************************\n\nlogin: ")); err != nil {
+		t.Logf("handleConnectionHPEComware: send username prompt error: %v", err)
+		return
+	}
+
+	// consume username
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionHPEComware: read username error: %v", err)
+		return
+	}
+
+	// send password prompt
+	if _, err := c.Write([]byte("\nPassword: ")); err != nil {
+		t.Logf("handleConnectionHPEComware: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionHPEComware: read password error: %v", err)
+		return
+	}
+
+	paging := true
+
+LOOP:
+	for {
+		// send command prompt
+		if _, err := c.Write([]byte("\n<core1>")); err != nil {
+			t.Logf("handleConnectionHPEComware: send command prompt error: %v", err)
+			return
+		}
+
+		// consume command
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionHPEComware: read command error: %v", readErr)
+			return
+		}
+
+		cmd := strings.TrimSpace(string(buf[:n]))
+
+		var output string
+
+		switch {
+		case cmd == "quit":
+			break LOOP
+		case cmd == "screen-length disable" && !options.comware5:
+			paging = false
+		case cmd == "screen-length 0 temporary" && options.comware5:
+			paging = false
+		case cmd == "display version":
+			output = "HPE Comware Software, Version 7.1.045, Release 2432P03\nCopyright (c) 2010-2020 Hewlett Packard Enterprise Development LP\nHPE 5130-24G-4SFP+ EI Switch uptime is 1 week, 2 days, 3 hours, 4 minutes\nLast reboot reason : User reboot"
+		case cmd == "display current-configuration":
+			output = "#\n version 7.1.045, Release 2432P03\n#\n sysname core1\n#\nlocal-user admin class manage\n password hash $h$6$abc$def\n service-type telnet ssh\n#\nreturn"
+		default:
+			output = "                 ^\n % Unrecognized command found at '^' position."
+		}
+
+		if paging && strings.HasPrefix(cmd, "display") {
+			output += "\n  ---- More ----"
+		}
+
+		if output != "" {
+			if _, err := c.Write([]byte("\n" + output)); err != nil {
+				t.Logf("handleConnectionHPEComware: send output error: %v", err)
+				return
+			}
+		}
+	}
+
+	// send bye
+	if _, err := c.Write([]byte("\n")); err != nil {
+		t.Logf("handleConnectionHPEComware: send bye error: %v", err)
+		return
+	}
+}
@@ -41,9 +41,18 @@ func registerModelHuaweiVRP(logger hasPrintf, t *DeviceTable) {
 	a.CommandReadTimeout = 15 * time.Second  // larger timeout for slow 'sh run'
 	a.CommandMatchTimeout = 25 * time.Second // larger timeout for slow 'sh run'
 	a.QuoteSentCommandsFormat = `##[%s]`
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{` uptime is `, `^!Last configuration was (?:updated|saved) at `}
 
 	m := &Model{name: "huawei-vrp"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`Huawei Versatile Routing Platform`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^\s*sysname (\S+)`,
+		FactVersion:  `VRP \(R\) software, Version (\S+(?: \([^)]*\))?)`,
+		FactPlatform: `(?m)^(?:HUAWEI|Quidway) (\S+).* uptime is `,
+		FactUptime:   `(?m)^(?:HUAWEI|Quidway) .* uptime is (.+?)\s*$`,
+	}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelHuaweiVRP: %v", err)
 	}
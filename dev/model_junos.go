@@ -18,7 +18,7 @@ func registerModelJunOS(logger hasPrintf, t *DeviceTable) {
 	a.EnablePasswordPromptPattern = ""
 	a.DisabledPromptPattern = `\S+>\s*$`
 	a.EnabledPromptPattern = `\S+>\s*$`
-	a.CommandList = []string{"show ver", "show conf | disp set"}
+	a.CommandList = []string{"show ver", "show conf | disp set", "show configuration"} // curly format staged for restore
 	a.DisablePagerCommand = "set cli screen-length 0"
 	a.ReadTimeout = 10 * time.Second
 	a.MatchTimeout = 20 * time.Second
@@ -27,9 +27,26 @@ func registerModelJunOS(logger hasPrintf, t *DeviceTable) {
 	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'sh run'
 	a.QuoteSentCommandsFormat = `##[%s]`
 	a.S3ContentType = "detect"
+	a.LineFilter = "junos" // drop {master:0} lines
+	a.RestoreCommands = []string{
+		"configure",
+		"load override {url}", // replace whole candidate config, dropping statements missing from saved file
+		"commit confirmed 5",  // automatic rollback unless confirmed by operator: commit
+		"exit",
+	}
+	a.RestoreConfigCommand = "show configuration" // staged for {url}
+	a.RestorePromptPattern = `\S+#\s*$`           // configuration mode
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^## Last (?:commit|changed): `}
 
 	m := &Model{name: "junos"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`JUNOS`, `Junos:`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^Hostname: (\S+)`,
+		FactVersion:  `(?m)^(?:Junos: |JUNOS .*\[)([^\]\s]+)`,
+		FactPlatform: `(?m)^Model: (\S+)`,
+	}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelJunOS: %v", err)
 	}
@@ -3,8 +3,11 @@ package dev
 import (
 	"io"
 	"net"
+	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/udhos/jazigo/conf"
@@ -13,6 +16,25 @@ import (
 
 type optionsJunos struct {
 	breakConn bool
+	config    *junosConfig // serve show configuration, accept load override and commit
+}
+
+// junosConfig is the active configuration for the bogus JunOS server.
+type junosConfig struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (c *junosConfig) get() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.lines...)
+}
+
+func (c *junosConfig) set(lines []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lines = append([]string{}, lines...)
 }
 
 func TestJuniperJunOS1(t *testing.T) {
@@ -146,26 +168,68 @@ func handleConnectionJuniperJunOS(t *testing.T, c net.Conn, options optionsJunos
 		return
 	}
 
+	configMode := false
+	var candidate []string
+
 LOOP:
 	for {
 		// send command prompt
-		if _, err := c.Write([]byte("\n{master:0}\nlab@host.domain> ")); err != nil {
+		prompt := "\n{master:0}\nlab@host.domain> "
+		if configMode {
+			prompt = "\n[edit]\nlab@host.domain# "
+		}
+		if _, err := c.Write([]byte(prompt)); err != nil {
 			t.Logf("handleConnectionJuniperJunOS: send command prompt error: %v", err)
 			return
 		}
 
 		// consume command
-		if _, err := c.Read(buf); err != nil {
-			if err == io.EOF {
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
 				return // peer closed connection
 			}
-			t.Logf("handleConnectionJuniperJunOS: read command error: %v", err)
+			t.Logf("handleConnectionJuniperJunOS: read command error: %v", readErr)
 			return
 		}
 
 		str := string(buf)
+		line := strings.TrimSpace(strings.SplitN(string(buf[:n]), "\n", 2)[0])
 
 		switch {
+		case configMode && strings.HasPrefix(line, "load override "):
+			u, urlErr := url.Parse(strings.TrimPrefix(line, "load override "))
+			if urlErr != nil {
+				t.Logf("handleConnectionJuniperJunOS: load override: %v", urlErr)
+				return
+			}
+			b, loadErr := os.ReadFile(u.Path)
+			if loadErr != nil {
+				t.Logf("handleConnectionJuniperJunOS: load override: %v", loadErr)
+				return
+			}
+			candidate = strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+			if _, err := c.Write([]byte("\nload complete")); err != nil {
+				t.Logf("handleConnectionJuniperJunOS: send load error: %v", err)
+				return
+			}
+		case configMode && strings.HasPrefix(line, "commit"):
+			if candidate != nil {
+				options.config.set(candidate)
+			}
+			if _, err := c.Write([]byte("\ncommit complete")); err != nil {
+				t.Logf("handleConnectionJuniperJunOS: send commit error: %v", err)
+				return
+			}
+		case configMode && strings.HasPrefix(line, "exit"):
+			configMode = false
+		case options.config != nil && line == "configure":
+			configMode = true
+		case options.config != nil && line == "show configuration":
+			if _, err := c.Write([]byte("\n" + strings.Join(options.config.get(), "\n"))); err != nil {
+				t.Logf("handleConnectionJuniperJunOS: send show configuration error: %v", err)
+				return
+			}
 		case strings.HasPrefix(str, "q"): //quit
 			break LOOP
 		case strings.HasPrefix(str, "ex"): //exit
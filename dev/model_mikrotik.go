@@ -26,9 +26,18 @@ func registerModelMikrotik(logger hasPrintf, t *DeviceTable) {
 	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow 'sh run'
 	a.QuoteSentCommandsFormat = `##[%s]`
 	a.UsernameAppend = "+cte"
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^\s*(?:uptime|free-memory|cpu-load|free-hdd-space|write-sect-since-reboot): `, `^# \w{3}/\d{2}/\d{4} \d{2}:\d{2}:\d{2} by RouterOS`}
 
 	m := &Model{name: "mikrotik"}
 	m.defaultAttr = a
+	m.fingerprints = []string{`RouterOS`, `MikroTik`}
+	m.facts = map[string]string{
+		FactHostname: `/system identity\s+set name=("[^"]*"|\S+)`,
+		FactVersion:  `(?m)^\s*version: (.+?)\s*$`,
+		FactPlatform: `(?m)^\s*board-name: (.+?)\s*$`,
+		FactUptime:   `(?m)^\s*uptime: (.+?)\s*$`,
+	}
 	if err := t.SetModel(m, logger); err != nil {
 		logger.Printf("registerModelMikrotik: %v", err)
 	}
@@ -0,0 +1,73 @@
+package dev
+
+import (
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelNokiaSROS(logger hasPrintf, t *DeviceTable) {
+	a := nokiaSROSAttr()
+
+	// classic CLI: A:router#
+	promptPattern := `(?:^|\n)\*?[AB]:[^\s#]+#\s*$`
+	a.DisabledPromptPattern = promptPattern
+	a.EnabledPromptPattern = promptPattern
+	a.DisablePagerCommand = "environment no more"
+	a.CommandList = []string{"show version", "admin display-config"}
+
+	m := &Model{name: "nokia-sros"}
+	m.defaultAttr = a
+	m.fingerprints = []string{`TiMOS-`}
+	m.facts = nokiaSROSFacts
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelNokiaSROS: %v", err)
+	}
+}
+
+func registerModelNokiaSROSMD(logger hasPrintf, t *DeviceTable) {
+	a := nokiaSROSAttr()
+
+	// MD-CLI two-line prompt: context line, then prompt line
+	// [/]
+	// A:admin@router#
+	promptPattern := `\[[^\]\n]*\]\n\*?[AB]:\S+@[^\s#]+#\s*$`
+	a.DisabledPromptPattern = promptPattern
+	a.EnabledPromptPattern = promptPattern
+	a.DisablePagerCommand = "environment more false"
+	a.CommandList = []string{"show version", "admin show configuration full-context"}
+
+	m := &Model{name: "nokia-sros-md"}
+	m.defaultAttr = a
+	m.facts = nokiaSROSFacts
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelNokiaSROSMD: %v", err)
+	}
+}
+
+// nokiaSROSAttr gets attributes shared by classic CLI and MD-CLI.
+func nokiaSROSAttr() conf.DevAttributes {
+	a := conf.NewDevAttr()
+
+	a.NeedLoginChat = true
+	a.NeedPagingOff = true
+	a.MultiLinePrompt = true
+	a.UsernamePromptPattern = `[Ll]ogin:\s*$`
+	a.PasswordPromptPattern = `[Pp]assword:\s*$`
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow config display
+	a.CommandMatchTimeout = 60 * time.Second // larger timeout for slow config display
+	a.QuoteSentCommandsFormat = `#[%s]`
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^# (?:Generated|Finished) `}
+
+	return a
+}
+
+var nokiaSROSFacts = map[string]string{
+	FactHostname: `(?m)^\s*(?:/configure system )?name "([^"]+)"`,
+	FactVersion:  `TiMOS-[A-Z]+-(\S+)`,
+	FactPlatform: `TiMOS-\S+ \S+ Nokia (\S+ \S+)`,
+}
@@ -0,0 +1,201 @@
+package dev
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+type optionsNokiaSROS struct {
+	mdcli bool // MD-CLI two-line prompt
+}
+
+func TestNokiaSROSClassic(t *testing.T) {
+	testNokiaSROS(t, ":2026", "nokia-sros", optionsNokiaSROS{}, `name "router-classic"`)
+}
+
+func TestNokiaSROSMD(t *testing.T) {
+	testNokiaSROS(t, ":2027", "nokia-sros-md", optionsNokiaSROS{mdcli: true}, `/configure system name "router-md"`)
+}
+
+func testNokiaSROS(t *testing.T, addr, model string, options optionsNokiaSROS, wantConfig string) {
+
+	// launch bogus test server
+	s, listenErr := spawnServerNokiaSROS(t, addr, options)
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus NokiaSROS server: %v", listenErr)
+	}
+	t.Logf("TestNokiaSROS: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, model, "lab1", "localhost"+addr, "telnet", "admin", "pass", "", false, nil)
+	enableMask(t, tab, "lab1", "nokia-sros")
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), 1000000)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if !strings.Contains(string(config), wantConfig) || strings.Contains(string(config), "s3cr3t") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+
+	if f, err := tab.GetFacts("lab1"); err != nil || f.Version != "20.10.R1" || f.Platform != "7750 SR" || !strings.HasPrefix(f.Hostname, "router-") {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+}
+
+func TestPromptTail(t *testing.T) {
+	if got := string(promptTail([]byte("out\r\n[/]\r\nA:admin@router# "))); got != "out\n[/]\nA:admin@router# " {
+		t.Errorf("promptTail: got=%q", got)
+	}
+	long := strings.Repeat("x", 2*promptTailSize) + "\nA:router# "
+	if got := promptTail([]byte(long)); len(got) != promptTailSize || !strings.HasSuffix(string(got), "\nA:router# ") {
+		t.Errorf("promptTail: size=%d", len(got))
+	}
+}
+
+func spawnServerNokiaSROS(t *testing.T, addr string, options optionsNokiaSROS) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopNokiaSROS(t, s, handleConnectionNokiaSROS, options)
+
+	return s, nil
+}
+
+func acceptLoopNokiaSROS(t *testing.T, s *testServer, handler func(*testing.T, net.Conn, optionsNokiaSROS), options optionsNokiaSROS) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopNokiaSROS: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn, options)
+	}
+
+	close(s.done)
+}
+
+func handleConnectionNokiaSROS(t *testing.T, c net.Conn, options optionsNokiaSROS) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send username prompt
+	if _, err := c.Write([]byte("Bogus NokiaSROS server\nLogin: ")); err != nil {
+		t.Logf("handleConnectionNokiaSROS: send username prompt error: %v", err)
+		return
+	}
+
+	// consume username
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionNokiaSROS: read username error: %v", err)
+		return
+	}
+
+	// send password prompt
+	if _, err := c.Write([]byte("\nPassword: ")); err != nil {
+		t.Logf("handleConnectionNokiaSROS: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionNokiaSROS: read password error: %v", err)
+		return
+	}
+
+	// prompt is sent in two pieces, in order to split it across client reads
+	prompt := []string{"\nA:rou", "ter-classic# "}
+	if options.mdcli {
+		prompt = []string{"\n[/]\nA:adm", "in@router-md# "}
+	}
+
+LOOP:
+	for {
+		// send command prompt
+		for _, p := range prompt {
+			if _, err := c.Write([]byte(p)); err != nil {
+				t.Logf("handleConnectionNokiaSROS: send command prompt error: %v", err)
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		// consume command
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionNokiaSROS: read command error: %v", readErr)
+			return
+		}
+
+		cmd := strings.TrimSpace(string(buf[:n]))
+
+		var output string
+
+		switch {
+		case cmd == "logout" || cmd == "quit" || cmd == "exit":
+			break LOOP
+		case cmd == "environment no more" && !options.mdcli:
+		case cmd == "environment more false" && options.mdcli:
+		case cmd == "show version":
+			output = "TiMOS-C-20.10.R1 cpm/hops64 Nokia 7750 SR Copyright (c) 2000-2020 Nokia."
+		case cmd == "admin display-config" && !options.mdcli:
+			output = "# TiMOS-C-20.10.R1\n# Generated THU JAN 01 00:00:00 2020 UTC\nconfigure\n    system\n        name \"router-classic\"\n        security\n            user \"admin\"\n                password \"s3cr3t\" hash2\n    exit\nexit all\n# Finished THU JAN 01 00:00:01 2020 UTC"
+		case cmd == "admin show configuration full-context" && options.mdcli:
+			output = "# Generated 2020-01-01T00:00:00.0+00:00 by admin\n/configure system name \"router-md\"\n/configure system security user-params local-user user \"admin\" password \"s3cr3t\""
+		default:
+			output = "Error: Bad command."
+		}
+
+		if output != "" {
+			if _, err := c.Write([]byte("\n" + output)); err != nil {
+				t.Logf("handleConnectionNokiaSROS: send output error: %v", err)
+				return
+			}
+		}
+	}
+
+	// send bye
+	if _, err := c.Write([]byte("\nbye\n")); err != nil {
+		t.Logf("handleConnectionNokiaSROS: send bye error: %v", err)
+		return
+	}
+}
@@ -0,0 +1,170 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelPANOS(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.CommandList = nil                      // XML API requests are issued by fetchPANOS
+	a.CommandMatchTimeout = 60 * time.Second // full API request timeout: large configs and state bundles
+	a.ExportDeviceState = false              // device state bundle is large
+
+	m := &Model{name: "panos"}
+	m.defaultAttr = a
+	m.apiFetch = fetchPANOS
+	m.facts = map[string]string{
+		FactHostname: `<hostname>([^<]+)</hostname>`,
+		FactVersion:  `<config [^>]*\bdetail-version="([^"]+)"`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelPANOS: %v", err)
+	}
+}
+
+// panosResponse is the envelope for PAN-OS XML API responses.
+type panosResponse struct {
+	XMLName xml.Name `xml:"response"`
+	Status  string   `xml:"status,attr"`
+	Key     string   `xml:"result>key"`
+	Msg     string   `xml:"result>msg"`
+	Line    string   `xml:"msg>line"`
+}
+
+func (r *panosResponse) message() string {
+	if m := strings.TrimSpace(r.Msg); m != "" {
+		return m
+	}
+	return strings.TrimSpace(r.Line)
+}
+
+// panosError extracts the error message from an XML API error response.
+// Exported files are not wrapped into responses, then they are not errors.
+func panosError(body []byte) error {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("<response")) {
+		return nil
+	}
+	var r panosResponse
+	if err := xml.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("bad response: %v", err)
+	}
+	if r.Status != "success" {
+		return fmt.Errorf("status=%s: %s", r.Status, r.message())
+	}
+	return nil
+}
+
+// fetchPANOS obtains an API key from device credentials, then exports the running configuration
+// and optionally the device state bundle through the XML API.
+func fetchPANOS(d *Device, logger hasPrintf, capture *dialog, opt *conf.AppConfig) (string, int, error) {
+
+	u, urlErr := apiBaseURL(d.HostPort)
+	if urlErr != nil {
+		return "", fetchErrTransp, fmt.Errorf("fetchPANOS: %v", urlErr)
+	}
+	transport := u.Scheme
+	u.Path = "/api/"
+
+	client := d.apiClient()
+
+	// keygen
+
+	form := url.Values{"type": {"keygen"}, "user": {d.Username()}, "password": {d.LoginPassword}}
+	resp, postErr := client.PostForm(u.String(), form)
+	if postErr != nil {
+		return transport, fetchErrTransp, fmt.Errorf("fetchPANOS: keygen: %v", postErr)
+	}
+	body, readErr := panosRead(resp, opt.MaxConfigLoadSize)
+	if readErr != nil {
+		return transport, fetchErrLogin, fmt.Errorf("fetchPANOS: keygen: %v", readErr)
+	}
+	var keygen panosResponse
+	if err := xml.Unmarshal(body, &keygen); err != nil {
+		return transport, fetchErrLogin, fmt.Errorf("fetchPANOS: keygen: bad response: %v", err)
+	}
+	if keygen.Status != "success" || keygen.Key == "" {
+		return transport, fetchErrLogin, fmt.Errorf("fetchPANOS: keygen: status=%s: %s", keygen.Status, keygen.message())
+	}
+
+	d.debugf("fetchPANOS: got API key")
+
+	// export configuration
+
+	config, configErr := panosExport(client, u, keygen.Key, "configuration", opt.MaxConfigLoadSize)
+	if configErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchPANOS: %v", configErr)
+	}
+
+	if saveErr := d.save(logger, capture, "", config); saveErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchPANOS: could not save configuration: %v", saveErr)
+	}
+
+	if !d.attr.ExportDeviceState {
+		return transport, fetchErrNone, nil
+	}
+
+	// export device state
+
+	state, stateErr := panosExport(client, u, keygen.Key, "device-state", opt.MaxConfigLoadSize)
+	if stateErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchPANOS: %v", stateErr)
+	}
+
+	capture.artifacts = append(capture.artifacts, artifact{name: "device-state", data: state, contentType: "application/gzip"})
+
+	return transport, fetchErrNone, nil
+}
+
+// panosExport exports a category of device files through the XML API.
+func panosExport(client *http.Client, u *url.URL, key, category string, maxSize int64) ([]byte, error) {
+	export := *u
+	export.RawQuery = url.Values{"type": {"export"}, "category": {category}}.Encode()
+
+	req, reqErr := http.NewRequest("GET", export.String(), nil)
+	if reqErr != nil {
+		return nil, fmt.Errorf("export %s: %v", category, reqErr)
+	}
+	req.Header.Set("X-PAN-KEY", key) // keep key off URLs and logs
+
+	resp, getErr := client.Do(req)
+	if getErr != nil {
+		return nil, fmt.Errorf("export %s: %v", category, getErr)
+	}
+	body, readErr := panosRead(resp, maxSize)
+	if readErr != nil {
+		return nil, fmt.Errorf("export %s: %v", category, readErr)
+	}
+	if err := panosError(body); err != nil {
+		return nil, fmt.Errorf("export %s: %v", category, err)
+	}
+	if len(body) < 1 {
+		return nil, fmt.Errorf("export %s: empty response", category)
+	}
+
+	return body, nil
+}
+
+// panosRead reads the response body up to maxSize bytes, rejecting HTTP errors.
+func panosRead(resp *http.Response, maxSize int64) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := apiReadBody(resp.Body, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if apiErr := panosError(body); apiErr != nil {
+			return nil, fmt.Errorf("http status=%d: %v", resp.StatusCode, apiErr)
+		}
+		return nil, fmt.Errorf("http status=%d", resp.StatusCode)
+	}
+	return body, nil
+}
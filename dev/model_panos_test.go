@@ -0,0 +1,134 @@
+package dev
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+const panosTestKey = "LUFRPT1bogus=="
+
+const panosTestConfig = `<config version="10.1.0" urldb="paloaltonetworks" detail-version="10.1.6">
+  <devices>
+    <entry name="localhost.localdomain">
+      <deviceconfig>
+        <system>
+          <hostname>fw1</hostname>
+        </system>
+      </deviceconfig>
+    </entry>
+  </devices>
+</config>
+`
+
+var panosTestState = []byte{0x1f, 0x8b, 0x08, 0x00, 'b', 'o', 'g', 'u', 's'}
+
+func TestPANOS(t *testing.T) {
+
+	s := httptest.NewTLSServer(http.HandlerFunc(handlePANOS))
+	defer s.Close()
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000})
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "panos", ID: "fw1", HostPort: strings.TrimPrefix(s.URL, "https://"), LoginUser: "admin", LoginPassword: "pass",
+		Attr: conf.AttrOverrides{"apiinsecuretls": true, "exportdevicestate": true}}
+	d, _, newErr := NewDeviceFromConf(tab, logger, &cfg)
+	if newErr != nil {
+		t.Fatalf("new device: %v", newErr)
+	}
+	if err := tab.SetDevice(d); err != nil {
+		t.Fatalf("set device: %v", err)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "fw1", "fw1.0"), 1000000)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if string(config) != panosTestConfig {
+		t.Errorf("config: wanted=%q got=%q", panosTestConfig, config)
+	}
+
+	state, stateErr := store.FileRead(DeviceArtifactPrefix(repo, "fw1", "device-state")+"0", 1000000)
+	if stateErr != nil {
+		t.Fatalf("device state: %v", stateErr)
+	}
+	if !bytes.Equal(state, panosTestState) {
+		t.Errorf("device state: wanted=%v got=%v", panosTestState, state)
+	}
+
+	if f, err := tab.GetFacts("fw1"); err != nil || f.Hostname != "fw1" || f.Version != "10.1.6" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+
+	// bad credentials
+	dev, _ := tab.GetDevice("fw1")
+	dev.LoginPassword = "wrong"
+	if result := dev.fetch(logger, 0, repo, opt.Get(), NewFilterTable(logger)); result.Code != fetchErrLogin || result.Transport != "https" {
+		t.Errorf("bad credentials: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+
+	// configuration larger than maxconfigloadsize
+	dev.LoginPassword = "pass"
+	small := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 100}
+	if result := dev.fetch(logger, 0, repo, small, NewFilterTable(logger)); result.Code != fetchErrCommands || !strings.Contains(result.Msg, "exceeds max=100") {
+		t.Errorf("oversized config: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+}
+
+func handlePANOS(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.FormValue("type") == "keygen" {
+		if r.Method != "POST" || r.PostFormValue("user") != "admin" || r.PostFormValue("password") != "pass" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<response status="error"><result><msg>Invalid Credential</msg></result></response>`)
+			return
+		}
+		io.WriteString(w, `<response status="success"><result><key>`+panosTestKey+`</key></result></response>`)
+		return
+	}
+
+	if r.Header.Get("X-PAN-KEY") != panosTestKey {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `<response status="error"><msg><line>Invalid key</line></msg></response>`)
+		return
+	}
+
+	switch {
+	case r.FormValue("type") == "export" && r.FormValue("category") == "configuration":
+		io.WriteString(w, panosTestConfig)
+	case r.FormValue("type") == "export" && r.FormValue("category") == "device-state":
+		w.Write(panosTestState)
+	default:
+		io.WriteString(w, `<response status="error"><msg><line>bad request</line></msg></response>`)
+	}
+}
@@ -0,0 +1,263 @@
+package dev
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelRESTCONF(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	a.CommandList = nil                                                       // RESTCONF requests are issued by fetchRESTCONF
+	a.APIPaths = []string{"ietf-system:system", "ietf-interfaces:interfaces"} // top-level modules: Cisco-IOS-XE-native:native
+	a.APIFormat = "json"                                                      // yang-data format: json or xml
+	a.CommandMatchTimeout = 60 * time.Second                                  // timeout for every API request
+
+	m := &Model{name: "restconf"}
+	m.defaultAttr = a
+	m.apiFetch = fetchRESTCONF
+	m.facts = map[string]string{
+		FactHostname: `(?:"hostname": "|<hostname>)([^"<]+)`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelRESTCONF: %v", err)
+	}
+}
+
+// restconfDefaultRoot is the RESTCONF API root assumed when the device does not provide host-meta.
+const restconfDefaultRoot = "/restconf"
+
+// restconfHostMeta is the XRD document listing the RESTCONF API root.
+type restconfHostMeta struct {
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"Link"`
+}
+
+// restconfErrors is the JSON error report from RESTCONF servers.
+type restconfErrors struct {
+	Errors struct {
+		Error []struct {
+			Tag     string `json:"error-tag"`
+			Message string `json:"error-message"`
+		} `json:"error"`
+	} `json:"ietf-restconf:errors"`
+}
+
+// fetchRESTCONF discovers the API root, then gets configuration data for every top-level module,
+// merged into one canonical document. A failed module is reported as warning, unless APIStrict is set.
+func fetchRESTCONF(d *Device, logger hasPrintf, capture *dialog, opt *conf.AppConfig) (string, int, error) {
+
+	u, urlErr := apiBaseURL(d.HostPort)
+	if urlErr != nil {
+		return "", fetchErrTransp, fmt.Errorf("fetchRESTCONF: %v", urlErr)
+	}
+	transport := u.Scheme
+
+	client := d.apiClient()
+
+	root, code, rootErr := restconfRoot(d, logger, client, u, opt.MaxConfigLoadSize)
+	if rootErr != nil {
+		return transport, code, fmt.Errorf("fetchRESTCONF: %v", rootErr)
+	}
+
+	format := d.attr.APIFormat
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "xml" {
+		return transport, fetchErrCommands, fmt.Errorf("fetchRESTCONF: bad format '%s'", format)
+	}
+
+	modules := append([]string{}, d.attr.APIPaths...)
+	if len(modules) < 1 {
+		return transport, fetchErrCommands, fmt.Errorf("fetchRESTCONF: no module to get")
+	}
+	sort.Strings(modules) // stable document
+
+	data := map[string][]byte{}
+	var failed []string
+
+	for _, m := range modules {
+		body, status, getErr := restconfGet(d, client, u, path.Join(root, "data", m)+"?content=config", "application/yang-data+"+format, opt.MaxConfigLoadSize)
+		switch {
+		case getErr != nil:
+			return transport, fetchErrTransp, fmt.Errorf("fetchRESTCONF: module '%s': %v", m, getErr)
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return transport, fetchErrLogin, fmt.Errorf("fetchRESTCONF: module '%s': http status=%d", m, status)
+		case status == http.StatusNoContent:
+			continue // module holds no configuration
+		case status != http.StatusOK:
+			msg := fmt.Sprintf("module '%s': http status=%d%s", m, status, restconfErrorMessage(body))
+			if d.attr.APIStrict {
+				return transport, fetchErrCommands, fmt.Errorf("fetchRESTCONF: %s", msg)
+			}
+			logger.Printf("fetchRESTCONF: dev '%s' %s", d.ID, msg)
+			failed = append(failed, msg)
+			continue
+		}
+		data[m] = body
+	}
+
+	if len(failed) == len(modules) {
+		return transport, fetchErrCommands, fmt.Errorf("fetchRESTCONF: all modules failed: %s", strings.Join(failed, "; "))
+	}
+
+	var doc []byte
+	var docErr error
+	if format == "json" {
+		doc, docErr = restconfMergeJSON(modules, data)
+	} else {
+		doc = restconfMergeXML(modules, data)
+	}
+	if docErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchRESTCONF: %v", docErr)
+	}
+
+	if saveErr := d.save(logger, capture, "", doc); saveErr != nil {
+		return transport, fetchErrCommands, fmt.Errorf("fetchRESTCONF: could not save configuration: %v", saveErr)
+	}
+
+	if len(failed) > 0 {
+		return transport, fetchErrNone, fmt.Errorf("fetchRESTCONF: %s", strings.Join(failed, "; ")) // warning
+	}
+
+	return transport, fetchErrNone, nil
+}
+
+// restconfRoot discovers the API root from host-meta (RFC 8040).
+// Devices missing host-meta are assumed to use the default root.
+func restconfRoot(d *Device, logger hasPrintf, client *http.Client, u *url.URL, maxSize int64) (string, int, error) {
+
+	body, status, getErr := restconfGet(d, client, u, "/.well-known/host-meta", "application/xrd+xml", maxSize)
+	switch {
+	case getErr != nil:
+		return "", fetchErrTransp, fmt.Errorf("host-meta: %v", getErr)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "", fetchErrLogin, fmt.Errorf("host-meta: http status=%d", status)
+	case status == http.StatusNotFound:
+		logger.Printf("restconfRoot: dev '%s' host-meta not found, assuming root '%s'", d.ID, restconfDefaultRoot)
+		return restconfDefaultRoot, fetchErrNone, nil
+	case status != http.StatusOK:
+		return "", fetchErrTransp, fmt.Errorf("host-meta: http status=%d", status)
+	}
+
+	var meta restconfHostMeta
+	if err := xml.Unmarshal(body, &meta); err != nil {
+		return "", fetchErrTransp, fmt.Errorf("host-meta: bad response: %v", err)
+	}
+	for _, l := range meta.Links {
+		if l.Rel != "restconf" || l.Href == "" {
+			continue
+		}
+		href, hrefErr := u.Parse(l.Href) // href might be absolute
+		if hrefErr != nil {
+			return "", fetchErrTransp, fmt.Errorf("host-meta: bad restconf link '%s': %v", l.Href, hrefErr)
+		}
+		return href.Path, fetchErrNone, nil
+	}
+
+	return "", fetchErrTransp, fmt.Errorf("host-meta: restconf link not found")
+}
+
+// restconfGet issues a GET request relative to the device base URL.
+// Response bodies larger than maxSize are refused.
+func restconfGet(d *Device, client *http.Client, u *url.URL, ref, accept string, maxSize int64) ([]byte, int, error) {
+	target, refErr := u.Parse(ref)
+	if refErr != nil {
+		return nil, 0, refErr
+	}
+	req, reqErr := http.NewRequest("GET", target.String(), nil)
+	if reqErr != nil {
+		return nil, 0, reqErr
+	}
+	req.Header.Set("Accept", accept)
+	req.SetBasicAuth(d.Username(), d.LoginPassword)
+
+	resp, doErr := client.Do(req)
+	if doErr != nil {
+		return nil, 0, doErr
+	}
+	defer resp.Body.Close()
+
+	body, readErr := apiReadBody(resp.Body, maxSize)
+	if readErr != nil {
+		return nil, 0, readErr
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// restconfErrorMessage extracts error messages from a JSON error report, if any.
+func restconfErrorMessage(body []byte) string {
+	var r restconfErrors
+	if json.Unmarshal(body, &r) != nil {
+		return ""
+	}
+	var msg []string
+	for _, e := range r.Errors.Error {
+		m := e.Tag
+		if e.Message != "" {
+			m += ": " + e.Message
+		}
+		msg = append(msg, m)
+	}
+	if len(msg) < 1 {
+		return ""
+	}
+	return ": " + strings.Join(msg, ", ")
+}
+
+// restconfMergeJSON merges top-level members from every module into one document with sorted keys.
+func restconfMergeJSON(modules []string, data map[string][]byte) ([]byte, error) {
+	doc := map[string]interface{}{}
+	for _, m := range modules {
+		body, found := data[m]
+		if !found {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var members map[string]interface{}
+		if err := dec.Decode(&members); err != nil {
+			return nil, fmt.Errorf("module '%s': bad JSON: %v", m, err)
+		}
+		for k, v := range members {
+			doc[k] = v
+		}
+	}
+	return canonicalJSON(doc)
+}
+
+// restconfMergeXML wraps every module document into a single data element, in module order.
+func restconfMergeXML(modules []string, data map[string][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<data xmlns="urn:ietf:params:xml:ns:yang:ietf-restconf">` + "\n")
+	for _, m := range modules {
+		body, found := data[m]
+		if !found {
+			continue
+		}
+		body = bytes.TrimSpace(body)
+		if bytes.HasPrefix(body, []byte("<?xml")) {
+			if i := bytes.Index(body, []byte("?>")); i >= 0 {
+				body = bytes.TrimSpace(body[i+2:])
+			}
+		}
+		buf.Write(body)
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("</data>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,152 @@
+package dev
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestRESTCONF(t *testing.T) {
+
+	stub := &restconfStub{root: "/top/restconf"}
+	api := httptest.NewTLSServer(stub)
+	defer api.Close()
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"}
+
+	modules := []string{"ietf-system:system", "ietf-interfaces:interfaces", "bogus:missing"}
+
+	d := newTestRESTCONF(t, tab, logger, api.URL, conf.AttrOverrides{"apipaths": modules, "secretmask": "restconf"})
+	if err := tab.SetDevice(d); err != nil {
+		t.Fatalf("set device: %v", err)
+	}
+
+	// missing module is reported as warning
+	result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger))
+	if result.Code != fetchErrNone || result.Transport != "https" || !strings.Contains(result.Msg, "'bogus:missing': http status=404: invalid-value: unknown module") {
+		t.Fatalf("json: code=%d transport=%s msg=[%s]", result.Code, result.Transport, result.Msg)
+	}
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), opt.MaxConfigLoadSize)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	c := string(config)
+	if !strings.Contains(c, `"hostname": "csr1"`) || !strings.Contains(c, `"mtu": 1500`) || strings.Contains(c, "$9$abc$def") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+	if i, j := strings.Index(c, `"ietf-interfaces:interfaces"`), strings.Index(c, `"ietf-system:system"`); i < 0 || j < 0 || i > j {
+		t.Errorf("config: keys not sorted: %s", config)
+	}
+
+	if f, err := tab.LoadFacts("lab1", repo, opt, logger); err != nil || f.Hostname != "csr1" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+
+	// strict mode fails on missing module
+	d = newTestRESTCONF(t, tab, logger, api.URL, conf.AttrOverrides{"apipaths": modules, "apistrict": true})
+	if result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger)); result.Code != fetchErrCommands {
+		t.Errorf("strict: code=%d msg=[%s]", result.Code, result.Msg)
+	}
+
+	// xml format, default root
+	stub.root = ""
+	d = newTestRESTCONF(t, tab, logger, api.URL, conf.AttrOverrides{"apiformat": "xml", "secretmask": "restconf"})
+	if result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger)); result.Code != fetchErrNone || result.Msg != "" {
+		t.Fatalf("xml: code=%d msg=[%s]", result.Code, result.Msg)
+	}
+	config, configErr = store.FileRead(DeviceFullPath(repo, "lab1", "lab1.1"), opt.MaxConfigLoadSize)
+	if configErr != nil {
+		t.Fatalf("xml config: %v", configErr)
+	}
+	if c := string(config); !strings.HasPrefix(c, `<data xmlns="urn:ietf:params:xml:ns:yang:ietf-restconf">`) || !strings.Contains(c, "<hostname>csr1</hostname>") || strings.Contains(c, "<?xml") || strings.Contains(c, "s3cr3t") {
+		t.Errorf("xml config: unexpected content: %s", config)
+	}
+
+	// bad credentials
+	d.LoginPassword = "wrong"
+	if result := d.fetch(logger, 0, repo, opt, NewFilterTable(logger)); result.Code != fetchErrLogin {
+		t.Errorf("bad credentials: code=%d msg=[%s]", result.Code, result.Msg)
+	}
+
+	// module larger than maxconfigloadsize
+	d.LoginPassword = "pass"
+	small := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 50}
+	if result := d.fetch(logger, 0, repo, small, NewFilterTable(logger)); result.Code == fetchErrNone || !strings.Contains(result.Msg, "exceeds max=50") {
+		t.Errorf("oversized module: code=%d msg=[%s]", result.Code, result.Msg)
+	}
+}
+
+func newTestRESTCONF(t *testing.T, tab *DeviceTable, logger hasPrintf, hostPort string, attr conf.AttrOverrides) *Device {
+	attr["apiinsecuretls"] = true
+	cfg := conf.DevConfig{Model: "restconf", ID: "lab1", HostPort: hostPort, LoginUser: "admin", LoginPassword: "pass", Attr: attr}
+	d, _, err := NewDeviceFromConf(tab, logger, &cfg)
+	if err != nil {
+		t.Fatalf("new device: %v", err)
+	}
+	return d
+}
+
+// restconfStub is a bogus RESTCONF server.
+// Empty root means host-meta is not provided, then default root is used.
+type restconfStub struct {
+	root string
+}
+
+func (s *restconfStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/.well-known/host-meta" {
+		if s.root == "" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0"><Link rel="restconf" href="`+s.root+`"/></XRD>`)
+		return
+	}
+
+	root := s.root
+	if root == "" {
+		root = restconfDefaultRoot
+	}
+
+	xml := r.Header.Get("Accept") == "application/yang-data+xml"
+	if r.URL.Query().Get("content") != "config" || (!xml && r.Header.Get("Accept") != "application/yang-data+json") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Path {
+	case root + "/data/ietf-system:system":
+		if xml {
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<system xmlns="urn:ietf:params:xml:ns:yang:ietf-system"><hostname>csr1</hostname><authentication><user><name>admin</name><password>s3cr3t</password></user></authentication></system>`)
+			return
+		}
+		io.WriteString(w, `{"ietf-system:system":{"hostname":"csr1","authentication":{"user":[{"password":"$9$abc$def","name":"admin"}]}}}`)
+	case root + "/data/ietf-interfaces:interfaces":
+		if xml {
+			io.WriteString(w, `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface><name>Gi1</name></interface></interfaces>`)
+			return
+		}
+		io.WriteString(w, `{"ietf-interfaces:interfaces":{"interface":[{"name":"Gi1","ietf-ip:ipv4":{"mtu":1500}}]}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"ietf-restconf:errors":{"error":[{"error-type":"application","error-tag":"invalid-value","error-message":"unknown module"}]}}`)
+	}
+}
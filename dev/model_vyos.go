@@ -0,0 +1,47 @@
+package dev
+
+import (
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func registerModelVyOS(logger hasPrintf, t *DeviceTable) {
+	a := conf.NewDevAttr()
+
+	// vbash prompt: vyos@vyos:~$
+	promptPattern := `\S+@\S+:[^\s$#]*[$#]\s*$`
+
+	a.NeedLoginChat = true
+	a.NeedPagingOff = true
+	a.UsernamePromptPattern = `[Ll]ogin:\s*$`
+	a.PasswordPromptPattern = `[Pp]assword:\s*$`
+	a.DisabledPromptPattern = promptPattern
+	a.EnabledPromptPattern = promptPattern
+	a.DisablePagerCommand = "set terminal length 0"
+	a.CommandList = []string{"show version", "show configuration commands"} // set format
+	a.SeparateCommands = []string{"show configuration"}                     // curly format, saved under outputs/show-configuration
+	a.ReadTimeout = 10 * time.Second
+	a.MatchTimeout = 20 * time.Second
+	a.SendTimeout = 5 * time.Second
+	a.CommandReadTimeout = 20 * time.Second  // larger timeout for slow config display
+	a.CommandMatchTimeout = 30 * time.Second // larger timeout for slow config display
+	a.QuoteSentCommandsFormat = `#[%s]`
+	a.LineFilter = "vyos" // drop [edit] lines
+	// volatile lines ignored by ChangesOnly
+	a.IgnoreForComparison = []string{`^Uptime:`, `^Boot via:`}
+
+	m := &Model{name: "vyos"}
+	m.defaultAttr = a
+	m.fingerprints = []string{`Welcome to (?:VyOS|EdgeOS)`, `(?m)^Version:\s+(?:VyOS|v\d+\.\d+\.\d+)`}
+	m.facts = map[string]string{
+		FactHostname: `(?m)^set system host-name '?([^'\s]+)'?`,
+		FactVersion:  `(?m)^Version:\s+(\S+(?: \S+)?)\s*$`,
+		FactSerial:   `(?m)^(?:Hardware S/N|HW S/N):[ \t]+(\S+)`,
+		FactPlatform: `(?m)^(?:Hardware model|HW model):\s+(.+?)\s*$`,
+		FactUptime:   `(?m)^Uptime:\s+(.+?)\s*$`,
+	}
+	if err := t.SetModel(m, logger); err != nil {
+		logger.Printf("registerModelVyOS: %v", err)
+	}
+}
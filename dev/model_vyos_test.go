@@ -0,0 +1,196 @@
+package dev
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestVyOS(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2029"
+	s, listenErr := spawnServerVyOS(t, addr)
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus VyOS server: %v", listenErr)
+	}
+	t.Logf("TestVyOS: server running on %s", addr)
+
+	// run client test
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, SecretMaskKey: "key"})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "vyos", "lab1", "localhost"+addr, "telnet", "vyos", "pass", "", false, nil)
+	enableMask(t, tab, "lab1", "vyos")
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	requestCh := make(chan FetchRequest)
+	errlogPrefix := filepath.Join(repo, "errlog_test.")
+	go Spawner(tab, logger, requestCh, repo, errlogPrefix, opt, NewFilterTable(logger))
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+
+	close(requestCh) // shutdown Spawner - we might exit first though
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+
+	config, configErr := store.FileRead(DeviceFullPath(repo, "lab1", "lab1.0"), 1000000)
+	if configErr != nil {
+		t.Fatalf("config: %v", configErr)
+	}
+	if c := string(config); !strings.Contains(c, "set system host-name 'vyos1'") || strings.Contains(c, "[edit]") || strings.Contains(c, "Welcome to VyOS") || strings.Contains(c, "$6$abc") || strings.Contains(c, "host-name vyos1") {
+		t.Errorf("config: unexpected content: %s", config)
+	}
+
+	curly, curlyErr := store.FileRead(DeviceOutputPrefix(repo, "lab1", "show-configuration")+"0", 1000000)
+	if curlyErr != nil {
+		t.Fatalf("separate output: %v", curlyErr)
+	}
+	if c := string(curly); !strings.Contains(c, "host-name vyos1") || strings.Contains(c, "[edit]") || strings.Contains(c, "$6$abc") || strings.Contains(c, "set system") {
+		t.Errorf("separate output: unexpected content: %s", curly)
+	}
+
+	if f, err := tab.GetFacts("lab1"); err != nil || f.Hostname != "vyos1" || f.Version != "VyOS 1.3.2" || f.Platform != "Standard PC (Q35 + ICH9, 2009)" || f.Serial != "" {
+		t.Errorf("facts: %v err=%v", f, err)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	table := map[string]string{
+		"show configuration":       "show-configuration",
+		"  show   configuration  ": "show-configuration",
+		"show run | include a/b":   "show-run-_-include-a_b",
+	}
+	for command, want := range table {
+		if got := OutputName(command); got != want {
+			t.Errorf("OutputName(%q): wanted=%q got=%q", command, want, got)
+		}
+	}
+}
+
+func spawnServerVyOS(t *testing.T, addr string) (*testServer, error) {
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &testServer{listener: ln, done: make(chan int)}
+
+	go acceptLoopVyOS(t, s, handleConnectionVyOS)
+
+	return s, nil
+}
+
+func acceptLoopVyOS(t *testing.T, s *testServer, handler func(*testing.T, net.Conn)) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			t.Logf("acceptLoopVyOS: accept failure, exiting: %v", err)
+			break
+		}
+		go handler(t, conn)
+	}
+
+	close(s.done)
+}
+
+func handleConnectionVyOS(t *testing.T, c net.Conn) {
+	defer c.Close()
+
+	buf := make([]byte, 1000)
+
+	// send username prompt
+	if _, err := c.Write([]byte("\nWelcome to VyOS - vyos1 ttyS0\n\nvyos1 login: ")); err != nil {
+		t.Logf("handleConnectionVyOS: send username prompt error: %v", err)
+		return
+	}
+
+	// consume username
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionVyOS: read username error: %v", err)
+		return
+	}
+
+	// send password prompt
+	if _, err := c.Write([]byte("\nPassword: ")); err != nil {
+		t.Logf("handleConnectionVyOS: send password prompt error: %v", err)
+		return
+	}
+
+	// consume password
+	if _, err := c.Read(buf); err != nil {
+		t.Logf("handleConnectionVyOS: read password error: %v", err)
+		return
+	}
+
+	// send motd
+	if _, err := c.Write([]byte("\nLinux vyos1 5.4.0-amd64-vyos #1 SMP x86_64\nWelcome to VyOS!\n")); err != nil {
+		t.Logf("handleConnectionVyOS: send motd error: %v", err)
+		return
+	}
+
+LOOP:
+	for {
+		// send command prompt
+		if _, err := c.Write([]byte("\nvyos@vyos1:~$ ")); err != nil {
+			t.Logf("handleConnectionVyOS: send command prompt error: %v", err)
+			return
+		}
+
+		// consume command
+		n, readErr := c.Read(buf)
+		if readErr != nil {
+			if readErr == io.EOF {
+				return // peer closed connection
+			}
+			t.Logf("handleConnectionVyOS: read command error: %v", readErr)
+			return
+		}
+
+		cmd := strings.TrimSpace(string(buf[:n]))
+
+		var output string
+
+		switch cmd {
+		case "exit", "logout":
+			break LOOP
+		case "set terminal length 0":
+		case "show version":
+			output = "Version:          VyOS 1.3.2\nBuilt by:         maintainers@vyos.net\nHardware model:   Standard PC (Q35 + ICH9, 2009)\nHardware S/N:\nUptime:           10:00:00 up 3 days"
+		case "show configuration commands":
+			output = "set system host-name 'vyos1'\nset system login user vyos authentication encrypted-password '$6$abc$def'\n[edit]"
+		case "show configuration":
+			output = "system {\n    host-name vyos1\n    login {\n        user vyos {\n            authentication {\n                encrypted-password $6$abc$def\n            }\n        }\n    }\n}\n[edit]"
+		default:
+			output = "\n  Invalid command: [" + cmd + "]\n"
+		}
+
+		if output != "" {
+			if _, err := c.Write([]byte("\n" + output)); err != nil {
+				t.Logf("handleConnectionVyOS: send output error: %v", err)
+				return
+			}
+		}
+	}
+
+	// send bye
+	if _, err := c.Write([]byte("\nlogout\n")); err != nil {
+		t.Logf("handleConnectionVyOS: send bye error: %v", err)
+		return
+	}
+}
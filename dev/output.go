@@ -0,0 +1,128 @@
+package dev
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/udhos/jazigo/store"
+)
+
+// namedCapture holds output saved apart from main device output,
+// like output captured within a security context, or output from a separate command.
+type namedCapture struct {
+	name string
+	save [][]byte
+}
+
+// DeviceOutputPrefix gets the path prefix for output from a command saved apart from main device output.
+// Output files are numbered after the main files they were fetched along with.
+func DeviceOutputPrefix(repository, id, name string) string {
+	return filepath.Join(deviceDirectory(repository, id), "outputs", name, id+".")
+}
+
+// outputNameRegexp matches characters replaced in output names, since they become directory names.
+var outputNameRegexp = regexp.MustCompile(`[^\w.-]`)
+
+// OutputName gets the name for output from a command saved apart: show configuration => show-configuration
+func OutputName(command string) string {
+	return outputNameRegexp.ReplaceAllString(strings.Join(strings.Fields(command), "-"), "_")
+}
+
+// namedGroup is a list of named outputs saved under the same kind of directory.
+type namedGroup struct {
+	list   []namedCapture
+	prefix func(name string) string
+}
+
+// namedGroups gets all named outputs in the capture, along with their path prefixes under repository.
+func (d *Device) namedGroups(capture *dialog, repository string) []namedGroup {
+	return []namedGroup{
+		{capture.contexts, func(name string) string { return DeviceContextPrefix(repository, d.ID, name) }},
+		{capture.outputs, func(name string) string { return DeviceOutputPrefix(repository, d.ID, name) }},
+	}
+}
+
+// fetchSeparateOutputs captures output from separate commands, each one saved apart from main device output.
+func (d *Device) fetchSeparateOutputs(logger hasPrintf, t transp, capture *dialog) error {
+	for _, c := range d.attr.SeparateCommands {
+		var out dialog
+		if err := d.sendCommandList(logger, t, &out, []string{c}); err != nil {
+			return fmt.Errorf("fetchSeparateOutputs: %v", err)
+		}
+		capture.outputs = append(capture.outputs, namedCapture{name: OutputName(c), save: out.save})
+	}
+	return nil
+}
+
+// namedChanged checks whether any named output differs from its copy saved along with the last main file.
+// prefix gets the path prefix for a named output.
+func (d *Device) namedChanged(logger hasPrintf, list []namedCapture, prefix func(name string) string, repository string, ft *FilterTable, mask *secretMask, normalize store.NormalizeFunc, maxSize int64) bool {
+
+	if len(list) < 1 {
+		return false
+	}
+
+	lastConfig, lastErr := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repository)), logger)
+	if lastErr != nil {
+		return true
+	}
+
+	id, idErr := store.ExtractCommitIDFromFilename(lastConfig)
+	if idErr != nil {
+		return true
+	}
+
+	for _, c := range list {
+		previous, readErr := store.FileRead(prefix(c.name)+strconv.Itoa(id), maxSize)
+		if readErr != nil {
+			d.debugf("namedChanged: '%s': %v", c.name, readErr)
+			return true
+		}
+
+		var buf bytes.Buffer
+		if err := d.captureWriter(&dialog{save: c.save}, ft, mask)(&buf); err != nil {
+			return true
+		}
+
+		current := buf.Bytes()
+		if normalize != nil {
+			previous, current = normalize(previous), normalize(current)
+		}
+
+		if !bytes.Equal(previous, current) {
+			d.debugf("namedChanged: '%s' changed", c.name)
+			return true
+		}
+	}
+
+	return false
+}
+
+// saveNamed saves named outputs, numbered after the main file saved as path.
+// prefix gets the path prefix for a named output.
+func (d *Device) saveNamed(logger hasPrintf, list []namedCapture, prefix func(name string) string, path string, maxFiles int, ft *FilterTable, mask *secretMask) error {
+
+	if len(list) < 1 {
+		return nil
+	}
+
+	id, idErr := store.ExtractCommitIDFromFilename(path)
+	if idErr != nil {
+		return fmt.Errorf("saveNamed: %v", idErr)
+	}
+
+	for _, c := range list {
+		writeFunc := d.captureWriter(&dialog{save: c.save}, ft, mask)
+		namedPath, saveErr := store.SaveConfigCopy(prefix(c.name), id, maxFiles, logger, writeFunc, d.attr.S3ContentType)
+		if saveErr != nil {
+			return fmt.Errorf("saveNamed: '%s': %v", c.name, saveErr)
+		}
+		logger.Printf("saveNamed: dev '%s' output '%s' saved to '%s'", d.ID, c.name, namedPath)
+	}
+
+	return nil
+}
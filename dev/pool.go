@@ -0,0 +1,137 @@
+package dev
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+// poolHostPlaceholder in a pool name is replaced by the resolved address of device HostPort.
+const poolHostPlaceholder = "{host}"
+
+// PoolStatus reports occupancy for a concurrency pool.
+type PoolStatus struct {
+	Name  string
+	Busy  int // device sessions open: backups, ad-hoc commands and restores
+	Limit int // 0 means unlimited
+}
+
+// concurrencyPool gets the name of the concurrency pool for the device. Empty name means no pool.
+func (d *Device) concurrencyPool() string {
+	pool := d.attr.ConcurrencyPool
+	if !strings.Contains(pool, poolHostPlaceholder) {
+		return pool
+	}
+	return strings.ReplaceAll(pool, poolHostPlaceholder, resolveHost(d.HostPort))
+}
+
+// resolveHost finds the address for the host in hostPort, which might be an URL.
+// Unresolvable hosts are kept by name.
+func resolveHost(hostPort string) string {
+	host := hostPort
+	if strings.Contains(hostPort, "://") {
+		if u, err := url.Parse(hostPort); err == nil {
+			host = u.Hostname()
+		}
+	} else if h, _, err := net.SplitHostPort(hostPort); err == nil {
+		host = h
+	}
+
+	addrs, lookupErr := net.LookupHost(host)
+	if lookupErr != nil || len(addrs) < 1 {
+		return host
+	}
+	sort.Strings(addrs) // stable choice for round-robin names
+	return addrs[0]
+}
+
+// PoolLimit gets the limit of concurrent device sessions for a pool. 0 means unlimited.
+func PoolLimit(opt *conf.AppConfig, pool string) int {
+	if limit, found := opt.PoolConcurrency[pool]; found {
+		return limit
+	}
+	return opt.DefaultPoolLimit
+}
+
+// AcquirePool takes a slot in a concurrency pool. Empty pool name means no pool.
+// If wait is true, AcquirePool blocks until a slot is available. Otherwise it reports whether the slot was taken.
+func (t *DeviceTable) AcquirePool(pool string, limit int, wait bool) bool {
+	if pool == "" {
+		return true
+	}
+
+	t.poolLock.Lock()
+	defer t.poolLock.Unlock()
+
+	for limit > 0 && t.pools[pool] >= limit {
+		if !wait {
+			return false
+		}
+		t.poolCond.Wait()
+	}
+
+	t.pools[pool]++
+	return true
+}
+
+// ReleasePool gives back a slot taken by AcquirePool.
+func (t *DeviceTable) ReleasePool(pool string) {
+	if pool == "" {
+		return
+	}
+
+	t.poolLock.Lock()
+	defer t.poolLock.Unlock()
+
+	if t.pools[pool]--; t.pools[pool] < 1 {
+		delete(t.pools, pool)
+	}
+	t.poolCond.Broadcast()
+}
+
+// AcquireSession takes a slot shared by all device sessions: backups, ad-hoc commands and restores.
+// It blocks until a slot is available. Zero limit means unlimited.
+// Callers holding a pool slot must acquire it before the session slot, so that session holders never wait.
+func (t *DeviceTable) AcquireSession(limit int) {
+	t.poolLock.Lock()
+	defer t.poolLock.Unlock()
+
+	for limit > 0 && t.sessions >= limit {
+		t.poolCond.Wait()
+	}
+
+	t.sessions++
+}
+
+// ReleaseSession gives back a slot taken by AcquireSession.
+func (t *DeviceTable) ReleaseSession() {
+	t.poolLock.Lock()
+	defer t.poolLock.Unlock()
+
+	t.sessions--
+	t.poolCond.Broadcast()
+}
+
+// ListPools gets occupancy for configured pools and for pools in use, sorted by name.
+func (t *DeviceTable) ListPools(opt *conf.AppConfig) []PoolStatus {
+	t.poolLock.Lock()
+	defer t.poolLock.Unlock()
+
+	names := map[string]struct{}{}
+	for p := range opt.PoolConcurrency {
+		names[p] = struct{}{}
+	}
+	for p := range t.pools {
+		names[p] = struct{}{}
+	}
+
+	list := make([]PoolStatus, 0, len(names))
+	for p := range names {
+		list = append(list, PoolStatus{Name: p, Busy: t.pools[p], Limit: PoolLimit(opt, p)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,177 @@
+package dev
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func TestResolveHost(t *testing.T) {
+	table := []struct {
+		hostPort string
+		want     string
+	}{
+		{"10.1.2.3", "10.1.2.3"},
+		{"10.1.2.3:2001", "10.1.2.3"},
+		{"[2001:db8::1]:22", "2001:db8::1"},
+		{"https://10.1.2.3:8443/api", "10.1.2.3"},
+		{"unresolvable.invalid:23", "unresolvable.invalid"},
+	}
+	for _, data := range table {
+		if got := resolveHost(data.hostPort); got != data.want {
+			t.Errorf("resolveHost(%s): wanted=%s got=%s", data.hostPort, data.want, got)
+		}
+	}
+}
+
+func TestPoolAcquire(t *testing.T) {
+	tab := NewDeviceTable()
+	opt := &conf.AppConfig{PoolConcurrency: map[string]int{"console1": 2, "idle": 3}, DefaultPoolLimit: 1}
+
+	if !tab.AcquirePool("", 0, false) {
+		t.Errorf("empty pool should never be full")
+	}
+	for i := 0; i < 2; i++ {
+		if !tab.AcquirePool("console1", PoolLimit(opt, "console1"), false) {
+			t.Errorf("console1 slot %d refused", i)
+		}
+	}
+	if tab.AcquirePool("console1", PoolLimit(opt, "console1"), false) {
+		t.Errorf("console1 over limit")
+	}
+	if !tab.AcquirePool("10.0.0.1", PoolLimit(opt, "10.0.0.1"), false) {
+		t.Errorf("default pool slot refused")
+	}
+
+	pools := tab.ListPools(opt)
+	want := []PoolStatus{{"10.0.0.1", 1, 1}, {"console1", 2, 2}, {"idle", 0, 3}}
+	if len(pools) != len(want) {
+		t.Fatalf("pools: wanted=%v got=%v", want, pools)
+	}
+	for i, p := range pools {
+		if p != want[i] {
+			t.Errorf("pool %d: wanted=%v got=%v", i, want[i], p)
+		}
+	}
+
+	// blocked request proceeds on release
+	done := make(chan struct{})
+	go func() {
+		tab.AcquirePool("console1", 2, true)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Errorf("console1 acquired over limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tab.ReleasePool("console1")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("console1 waiter not released")
+	}
+}
+
+func TestScanPools(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	// 4 devices behind one console server, 2 devices on their own
+	hosts := []string{"10.9.9.9:2001", "10.9.9.9:2002", "10.9.9.9:2003", "10.9.9.9:2004", "10.0.0.1", "10.0.0.2"}
+	for i, h := range hosts {
+		cfg := conf.DevConfig{Model: "cisco-ios", ID: string(rune('a' + i)), HostPort: h}
+		if i < 4 {
+			cfg.Attr = conf.AttrOverrides{"concurrencypool": "console-{host}"}
+		}
+		d, _, err := NewDeviceFromConf(tab, logger, &cfg)
+		if err != nil {
+			t.Fatalf("new device: %v", err)
+		}
+		if err := tab.SetDevice(d); err != nil {
+			t.Fatalf("set device: %v", err)
+		}
+	}
+
+	opt := &conf.AppConfig{MaxConcurrency: 4, PoolConcurrency: map[string]int{"console-10.9.9.9": 2}}
+
+	// bogus spawner tracking pool occupancy
+	var lock sync.Mutex
+	busy := map[string]int{}
+	peak := map[string]int{}
+	reqChan := make(chan FetchRequest)
+	go func() {
+		for req := range reqChan {
+			lock.Lock()
+			busy[req.Pool]++
+			if busy[req.Pool] > peak[req.Pool] {
+				peak[req.Pool] = busy[req.Pool]
+			}
+			lock.Unlock()
+			go func(req FetchRequest) {
+				time.Sleep(20 * time.Millisecond)
+				lock.Lock()
+				busy[req.Pool]--
+				lock.Unlock()
+				tab.ReleasePool(req.Pool)
+				req.ReplyChan <- FetchResult{DevID: req.ID, Code: fetchErrNone}
+			}(req)
+		}
+	}()
+	defer close(reqChan)
+
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt, reqChan)
+	if good != len(hosts) || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+	if p := peak["console-10.9.9.9"]; p != 2 {
+		t.Errorf("console pool peak: wanted=2 got=%d", p)
+	}
+	if p := peak[""]; p != 2 {
+		t.Errorf("unpooled peak: wanted=2 got=%d", p)
+	}
+	if pools := tab.ListPools(opt); len(pools) != 1 || pools[0].Busy != 0 {
+		t.Errorf("pools not released: %v", pools)
+	}
+}
+
+func TestRunCommandsPool(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	cfg := conf.DevConfig{Model: "cisco-ios", ID: "lab1", HostPort: "localhost:2033", Transports: "telnet", Attr: conf.AttrOverrides{"concurrencypool": "console1"}} // nothing listening
+	d, _, err := NewDeviceFromConf(tab, logger, &cfg)
+	if err != nil {
+		t.Fatalf("new device: %v", err)
+	}
+	if err := tab.SetDevice(d); err != nil {
+		t.Fatalf("set device: %v", err)
+	}
+
+	opt := &conf.AppConfig{MaxConcurrency: 10, PoolConcurrency: map[string]int{"console1": 1}}
+
+	tab.AcquirePool("console1", 1, false) // busy with a backup
+
+	done := make(chan []CommandResult)
+	go func() {
+		done <- RunCommands(tab, logger, tab.ListDevices(), []string{"show clock"}, opt, nil)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("ad-hoc command exceeded pool limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tab.ReleasePool("console1")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("ad-hoc command not released")
+	}
+}
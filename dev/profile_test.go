@@ -0,0 +1,80 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func TestProfileMerge(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	profiles := []conf.Profile{
+		{Name: "asr9k-sp", LoginUser: "backup", LoginPassword: "env:SP_PASS", Attr: conf.AttrOverrides{"linefilter": "iosxr", "commandmatchtimeout": "120s", "readtimeout": "15s"}},
+		{Name: "slow", Attr: conf.AttrOverrides{"commandmatchtimeout": "300s"}},
+	}
+	if err := tab.SetProfiles(profiles); err != nil {
+		t.Fatalf("set profiles: %v", err)
+	}
+
+	cfg := conf.DevConfig{Model: "cisco-iosxr", ID: "lab1", LoginPassword: "devpass", Profiles: []string{"asr9k-sp", "slow"}, Attr: conf.AttrOverrides{"readtimeout": "5s"}}
+	d, _, err := NewDeviceFromConf(tab, logger, &cfg)
+	if err != nil {
+		t.Fatalf("new device: %v", err)
+	}
+	tab.SetDevice(d)
+
+	// model -> profile(s) -> device
+	a := d.Attributes()
+	if a.CommandMatchTimeout != 300*time.Second {
+		t.Errorf("later profile must win: %v", a.CommandMatchTimeout)
+	}
+	if a.ReadTimeout != 5*time.Second {
+		t.Errorf("device must win over profile: %v", a.ReadTimeout)
+	}
+	origin := d.AttributesOrigin()
+	if origin["commandmatchtimeout"] != conf.ProfileOrigin("slow") || origin["linefilter"] != conf.ProfileOrigin("asr9k-sp") || origin["readtimeout"] != conf.OriginDevice {
+		t.Errorf("unexpected origins: %v", origin)
+	}
+
+	d.applyProfileCredentials()
+	if d.LoginUser != "backup" {
+		t.Errorf("user from profile: got=%s", d.LoginUser)
+	}
+	if d.LoginPassword != "devpass" {
+		t.Errorf("device password must win over profile: got=%s", d.LoginPassword)
+	}
+
+	if members := tab.ListProfileMembers("slow"); len(members) != 1 || members[0] != "lab1" {
+		t.Errorf("unexpected members: %v", members)
+	}
+
+	// profile in use cannot be removed
+	if err := tab.SetProfiles(profiles[:1]); err == nil {
+		t.Errorf("removing profile in use: expected error")
+	}
+	if _, err := tab.GetProfile("slow"); err != nil {
+		t.Errorf("profile in use was removed: %v", err)
+	}
+}
+
+func TestProfileInvalid(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	if err := tab.SetProfiles([]conf.Profile{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Errorf("duplicate profile: expected error")
+	}
+	if err := tab.SetProfiles([]conf.Profile{{Name: "a", Attr: conf.AttrOverrides{"bogus": true}}}); err == nil {
+		t.Errorf("bad attribute: expected error")
+	}
+
+	cfg := conf.DevConfig{Model: "cisco-ios", ID: "lab1", Profiles: []string{"missing"}}
+	if _, _, err := NewDeviceFromConf(tab, logger, &cfg); err == nil {
+		t.Errorf("missing profile: expected error")
+	}
+}
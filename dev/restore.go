@@ -0,0 +1,442 @@
+package dev
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/udhos/difflib"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+)
+
+// Restore recipe placeholders.
+const (
+	restoreURL    = "{url}"    // replaced by URL for staged copy of saved configuration, as seen from the device
+	restoreConfig = "{config}" // whole command replaced by saved configuration, line by line
+)
+
+// RestoreReport describes a restore run.
+type RestoreReport struct {
+	DevID      string
+	File       string
+	DryRun     bool
+	Plan       []string // commands sent (or to be sent) to the device
+	Diff       []string // dry run: "-" line only in saved file, "+" line only in live config
+	Transcript []byte   // full device dialog
+	Audit      string   // path for audit record
+	Begin      time.Time
+	End        time.Time
+}
+
+// restoreStageToken gets a random name for the directory holding a staged configuration, so its location cannot be guessed.
+func restoreStageToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("restoreStageToken: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// pathInside informs whether path is dir itself or lies under dir.
+func pathInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// RestoreAuditPrefix builds the path prefix for device restore audit records.
+func RestoreAuditPrefix(logPathPrefix, id string) string {
+	dir := filepath.Dir(logPathPrefix)
+	return filepath.Join(dir, id) + ".restore."
+}
+
+// Restore pushes a saved configuration file back into a device, following the restore recipe
+// from device attributes.
+// A dry run only retrieves the live configuration and compares it with the saved file.
+// An actual restore requires confirm to match the device ID.
+// Every run reaching the device, successful or not, is saved as an audit record.
+// Configurations fetched by the device thru {url} are staged under stagePath, which must lie outside the repository.
+func Restore(tab DeviceUpdater, logger hasPrintf, devID, file string, dryRun bool, confirm string, change conf.Change, repository, stagePath, logPathPrefix string, opt *conf.AppConfig, ft *FilterTable) (*RestoreReport, error) {
+
+	report := &RestoreReport{DevID: devID, File: file, DryRun: dryRun, Begin: time.Now()}
+
+	if !dryRun && confirm != devID {
+		return report, fmt.Errorf("Restore: not confirmed: confirmation must match device ID '%s'", devID)
+	}
+
+	if file == "" || filepath.Base(file) != file || !strings.HasPrefix(file, devID+".") {
+		return report, fmt.Errorf("Restore: bad file name '%s' for device '%s'", file, devID)
+	}
+
+	d, getErr := tab.GetDevice(devID)
+	if getErr != nil {
+		return report, fmt.Errorf("Restore: device '%s': %v", devID, getErr)
+	}
+	if attrErr := d.refresh(tab); attrErr != nil {
+		return report, fmt.Errorf("Restore: device '%s': %v", devID, attrErr)
+	}
+
+	saved, readErr := store.FileRead(DeviceFullPath(repository, devID, file), opt.MaxConfigLoadSize)
+	if readErr != nil {
+		return report, fmt.Errorf("Restore: %v", readErr)
+	}
+
+	// saved file might hold masked secrets: push the unmasked copy instead
+	config := saved
+	if hasMaskedSecrets(saved) {
+		if opt.UnmaskedPath == "" {
+			return report, fmt.Errorf("Restore: file '%s' holds masked secrets and there is no unmasked copy (global option unmaskedpath)", file)
+		}
+		unmasked, unmaskedErr := store.FileRead(DeviceFullPath(opt.UnmaskedPath, devID, file), opt.MaxConfigLoadSize)
+		if unmaskedErr != nil {
+			return report, fmt.Errorf("Restore: unmasked copy: %v", unmaskedErr)
+		}
+		config = unmasked
+	}
+
+	mask, maskErr := d.mask(ft, opt.SecretMaskKey, opt.SecretCacheTTL)
+	if maskErr != nil {
+		return report, fmt.Errorf("Restore: %v", maskErr)
+	}
+
+	token, tokenErr := restoreStageToken()
+	if tokenErr != nil {
+		return report, fmt.Errorf("Restore: %v", tokenErr)
+	}
+
+	plan, staged, planErr := d.restorePlan(file, config, opt.RestoreURL, token)
+	if planErr != nil {
+		return report, fmt.Errorf("Restore: %v", planErr)
+	}
+	report.Plan = plan
+
+	if staged != nil && !dryRun {
+		if stagePath == "" || store.S3Path(stagePath) || pathInside(repository, stagePath) {
+			// staged copy might hold unmasked secrets: keep it away from the web UI
+			return report, fmt.Errorf("Restore: stage path '%s' must be a local directory outside the repository '%s'", stagePath, repository)
+		}
+		stageDir, stageErr := stageRestoreConfig(stagePath, token, file, staged)
+		if stageErr != nil {
+			return report, fmt.Errorf("Restore: %v", stageErr)
+		}
+		defer func() {
+			// staged copy might hold unmasked secrets
+			if err := os.RemoveAll(stageDir); err != nil {
+				logger.Printf("Restore: device '%s': could not remove staged config: %v", devID, err)
+			}
+		}()
+	}
+
+	capture := dialog{}
+
+	pool := d.concurrencyPool()
+	tab.AcquirePool(pool, PoolLimit(opt, pool), true)
+	tab.AcquireSession(opt.MaxConcurrency) // shared with backups
+	runErr := d.restore(logger, &capture, report, plan, saved, opt.SecretCacheTTL, ft, mask)
+	tab.ReleaseSession()
+	tab.ReleasePool(pool)
+
+	for _, b := range capture.save {
+		report.Transcript = append(report.Transcript, b...)
+	}
+	report.End = time.Now()
+
+	if mask != nil {
+		// keep secrets from unmasked copy out of report and audit record
+		masked := make([]string, len(plan))
+		for i, c := range plan {
+			masked[i] = string(mask.line([]byte(c)))
+		}
+		report.Plan = masked
+		report.Transcript = mask.text(report.Transcript)
+	}
+
+	audit, auditErr := saveRestoreAudit(logger, report, change, runErr, logPathPrefix)
+	if auditErr != nil {
+		logger.Printf("Restore: device '%s': could not save audit record: %v", devID, auditErr)
+	}
+	report.Audit = audit
+
+	if runErr != nil {
+		return report, fmt.Errorf("Restore: %v", runErr)
+	}
+
+	return report, nil
+}
+
+func (d *Device) restore(logger hasPrintf, capture *dialog, report *RestoreReport, plan []string, saved []byte, secretTTL time.Duration, ft *FilterTable, mask *secretMask) error {
+
+	session, _, _, _, err := d.connect(logger, capture, secretTTL)
+	if err != nil {
+		return err
+	}
+
+	defer session.Close()
+
+	if d.attr.NeedPagingOff {
+		if pagingErr := d.pagingOff(logger, session, capture); pagingErr != nil {
+			return fmt.Errorf("restore pager off: %v", pagingErr)
+		}
+	}
+
+	if report.DryRun {
+		// retrieve live config exactly as a backup would
+		if cmdErr := d.sendCommands(logger, session, capture); cmdErr != nil {
+			return fmt.Errorf("restore dry run: %v", cmdErr)
+		}
+		var live bytes.Buffer
+		if writeErr := d.captureWriter(capture, ft, mask)(&live); writeErr != nil {
+			return fmt.Errorf("restore dry run: %v", writeErr)
+		}
+		report.Diff = diffLines(saved, live.Bytes())
+		return nil
+	}
+
+	logger.Printf("restore: device '%s': pushing file '%s'", d.ID, report.File)
+
+	return d.sendRestore(logger, session, capture, plan)
+}
+
+// restorePlan expands the restore recipe into the list of commands to send.
+// When the recipe refers to {url}, it also returns the configuration lines to be staged for the device,
+// under directory token.
+func (d *Device) restorePlan(file string, saved []byte, baseURL, token string) ([]string, []string, error) {
+	if len(d.attr.RestoreCommands) < 1 {
+		return nil, nil, fmt.Errorf("restorePlan: model '%s' has no restore recipe (attribute restorecommands)", d.devModel.name)
+	}
+
+	var lines []string
+	extract := func() error {
+		if lines != nil {
+			return nil
+		}
+		var extractErr error
+		lines, extractErr = d.extractConfig(saved)
+		return extractErr
+	}
+
+	var plan []string
+	var staged []string
+
+	for _, c := range d.attr.RestoreCommands {
+		switch {
+		case c == restoreConfig:
+			if err := extract(); err != nil {
+				return nil, nil, fmt.Errorf("restorePlan: %v", err)
+			}
+			plan = append(plan, lines...)
+		case strings.Contains(c, restoreURL):
+			if baseURL == "" {
+				return nil, nil, fmt.Errorf("restorePlan: recipe requires global option restoreurl")
+			}
+			if err := extract(); err != nil {
+				return nil, nil, fmt.Errorf("restorePlan: %v", err)
+			}
+			staged = lines
+			url := strings.TrimRight(baseURL, "/") + "/" + token + "/" + file
+			plan = append(plan, strings.Replace(c, restoreURL, url, -1))
+		default:
+			plan = append(plan, c)
+		}
+	}
+
+	return plan, staged, nil
+}
+
+// stageRestoreConfig saves configuration lines where {url} points to: stagePath/token/file.
+// It returns the token directory, to be removed after the restore.
+// Directory and file are accessible only by the jazigo user.
+func stageRestoreConfig(stagePath, token, file string, lines []string) (string, error) {
+	dir := filepath.Join(stagePath, token)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("stageRestoreConfig: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("stageRestoreConfig: %v", err)
+	}
+
+	return dir, nil
+}
+
+// sentCommandHeader renders a command as recorded into saved files.
+func (d *Device) sentCommandHeader(command string) string {
+	command = fmt.Sprintf("%q", command)
+	if d.attr.QuoteSentCommandsFormat != "" {
+		command = fmt.Sprintf(d.attr.QuoteSentCommandsFormat, command)
+	}
+	return command
+}
+
+// extractConfig recovers the output for RestoreConfigCommand from a saved file,
+// dropping command echo, prompts and empty lines.
+func (d *Device) extractConfig(saved []byte) ([]string, error) {
+	if d.attr.RestoreConfigCommand == "" {
+		return nil, fmt.Errorf("extractConfig: missing attribute restoreconfigcommand")
+	}
+
+	headers := map[string]bool{}
+	for _, c := range d.attr.CommandList {
+		headers[d.sentCommandHeader(c)] = true
+	}
+	want := d.sentCommandHeader(d.attr.RestoreConfigCommand)
+
+	var prompts []*regexp.Regexp
+	for _, p := range []string{d.attr.DisabledPromptPattern, d.attr.EnabledPromptPattern} {
+		if p == "" {
+			continue
+		}
+		exp, badExp := regexp.Compile(p)
+		if badExp != nil {
+			return nil, fmt.Errorf("extractConfig: bad pattern '%s': %v", p, badExp)
+		}
+		prompts = append(prompts, exp)
+	}
+
+	var lines []string
+	found := false
+	inside := false
+
+LINES:
+	for _, line := range splitLines(saved) {
+		line = strings.TrimRight(line, "\r")
+		if headers[line] || line == want {
+			inside = line == want
+			found = found || inside
+			continue
+		}
+		if !inside || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.TrimSpace(line) == d.attr.RestoreConfigCommand {
+			continue // command echo
+		}
+		for _, exp := range prompts {
+			if exp.MatchString(line) {
+				continue LINES
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("extractConfig: output for command '%s' not found in saved file", d.attr.RestoreConfigCommand)
+	}
+
+	return lines, nil
+}
+
+// sendRestore sends restore commands, accepting the restore prompt besides regular command prompts.
+func (d *Device) sendRestore(logger hasPrintf, t transp, capture *dialog, plan []string) error {
+
+	// save timeouts
+	saveReadTimeout := d.attr.ReadTimeout
+	saveMatchTimeout := d.attr.MatchTimeout
+
+	// temporarily change timeouts
+	d.attr.ReadTimeout = d.attr.CommandReadTimeout
+	d.attr.MatchTimeout = d.attr.CommandMatchTimeout
+
+	// restore timeouts
+	defer func() {
+		d.attr.ReadTimeout = saveReadTimeout
+		d.attr.MatchTimeout = saveMatchTimeout
+	}()
+
+	var prompts []string
+	for _, p := range []string{d.attr.DisabledPromptPattern, d.attr.EnabledPromptPattern, d.attr.RestorePromptPattern} {
+		if p != "" {
+			prompts = append(prompts, p)
+		}
+	}
+	if len(prompts) < 1 {
+		return fmt.Errorf("sendRestore: no prompt pattern")
+	}
+
+	for i, c := range plan {
+
+		if err := d.sendln(logger, t, c); err != nil {
+			return fmt.Errorf("sendRestore: could not send command [%d] '%s': %v", i, c, err)
+		}
+
+		_, matchBuf, matchErr := d.match(logger, t, capture, prompts)
+
+		d.save(logger, capture, c, matchBuf)
+
+		switch matchErr {
+		case nil: // ok
+		case io.EOF:
+			return fmt.Errorf("sendRestore: EOF after command [%d] '%s'", i, c)
+		default:
+			return fmt.Errorf("sendRestore: could not match prompt after command [%d] '%s': %v", i, c, matchErr)
+		}
+	}
+
+	return nil
+}
+
+func splitLines(b []byte) []string {
+	return strings.Split(string(b), "\n")
+}
+
+// diffLines reports lines only in a (prefixed with "-") and lines only in b (prefixed with "+").
+func diffLines(a, b []byte) []string {
+	var diff []string
+	for _, d := range difflib.Diff(splitLines(a), splitLines(b)) {
+		switch d.Delta {
+		case difflib.LeftOnly:
+			diff = append(diff, "-"+d.Payload)
+		case difflib.RightOnly:
+			diff = append(diff, "+"+d.Payload)
+		}
+	}
+	return diff
+}
+
+func saveRestoreAudit(logger hasPrintf, report *RestoreReport, change conf.Change, runErr error, logPathPrefix string) (string, error) {
+
+	result := "success"
+	if runErr != nil {
+		result = fmt.Sprintf("failure: %v", runErr)
+	}
+
+	writeFunc := func(w store.HasWrite) error {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "device: %s\n", report.DevID)
+		fmt.Fprintf(&buf, "file: %s\n", report.File)
+		fmt.Fprintf(&buf, "dry run: %v\n", report.DryRun)
+		fmt.Fprintf(&buf, "by: %s\n", change.By)
+		fmt.Fprintf(&buf, "from: %s\n", change.From)
+		fmt.Fprintf(&buf, "begin: %s\n", report.Begin)
+		fmt.Fprintf(&buf, "end: %s\n", report.End)
+		fmt.Fprintf(&buf, "result: %s\n", result)
+		buf.WriteString("\nplan:\n")
+		for _, c := range report.Plan {
+			fmt.Fprintf(&buf, "%s\n", c)
+		}
+		if report.DryRun {
+			buf.WriteString("\ndiff (-saved +live):\n")
+			for _, l := range report.Diff {
+				fmt.Fprintf(&buf, "%s\n", l)
+			}
+		}
+		buf.WriteString("\ntranscript:\n")
+		buf.Write(report.Transcript)
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	return store.SaveNewConfig(RestoreAuditPrefix(logPathPrefix, report.DevID), 0, logger, writeFunc, false, "text/plain")
+}
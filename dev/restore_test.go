@@ -0,0 +1,233 @@
+package dev
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/store"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestRestore(t *testing.T) {
+
+	// launch bogus test server
+	addr := ":2016"
+	s, listenErr := spawnServerFortiOS(t, addr, optionsFortiOS{requestPassword: true})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus FortiOS server: %v", listenErr)
+	}
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "fortios", "lab1", "localhost"+addr, "telnet", "lab", "pass", "en", false, nil)
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+	stage := t.TempDir()
+
+	// take backup to restore from
+	requestCh := make(chan FetchRequest)
+	logPrefix := filepath.Join(repo, "errlog_test.")
+	ft := NewFilterTable(logger)
+	go Spawner(tab, logger, requestCh, repo, logPrefix, opt, ft)
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+	close(requestCh)
+
+	d, _ := tab.GetDevice("lab1")
+	last, lastErr := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repo)), logger)
+	if lastErr != nil {
+		t.Fatalf("no backup: %v", lastErr)
+	}
+	file := filepath.Base(last)
+
+	change := conf.Change{When: time.Now(), By: "tester"}
+
+	// dry run: live config matches saved file
+	report, dryErr := Restore(tab, logger, "lab1", file, true, "", change, repo, stage, logPrefix, opt.Get(), ft)
+	if dryErr != nil {
+		t.Fatalf("dry run: %v", dryErr)
+	}
+	if len(report.Diff) != 0 {
+		t.Errorf("dry run: unexpected diff: %q", report.Diff)
+	}
+	if len(report.Plan) != 3 || report.Plan[1] != "this is the full FortiOS config" {
+		t.Errorf("dry run: unexpected plan: %q", report.Plan)
+	}
+
+	// actual restore requires confirmation
+	if _, err := Restore(tab, logger, "lab1", file, false, "lab2", change, repo, stage, logPrefix, opt.Get(), ft); err == nil {
+		t.Errorf("unconfirmed restore: expected error")
+	}
+
+	// no path tricks
+	if _, err := Restore(tab, logger, "lab1", "../lab2/lab2.0", true, "", change, repo, stage, logPrefix, opt.Get(), ft); err == nil {
+		t.Errorf("bad file: expected error")
+	}
+
+	report, restoreErr := Restore(tab, logger, "lab1", file, false, "lab1", change, repo, stage, logPrefix, opt.Get(), ft)
+	if restoreErr != nil {
+		t.Errorf("restore: %v", restoreErr)
+	}
+	if len(report.Transcript) == 0 {
+		t.Errorf("restore: empty transcript")
+	}
+
+	// audit records: dry run + restore
+	_, audits, listErr := store.ListConfig(RestoreAuditPrefix(logPrefix, "lab1"), logger)
+	if listErr != nil {
+		t.Errorf("audit list: %v", listErr)
+	}
+	if len(audits) != 2 {
+		t.Errorf("audit records: wanted=2 got=%d", len(audits))
+	}
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+}
+
+func TestRestoreStage(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost", "telnet", "lab", "pass", "en", false, nil)
+	d, _ := tab.GetDevice("lab1")
+
+	saved := []byte(`!!["show ver"]
+Cisco IOS Software, Version 15.1
+lab1#
+!!["show run"]
+hostname lab1
+!
+interface Gi0/0
+lab1#
+`)
+
+	plan, staged, planErr := d.restorePlan("lab1.3", saved, "scp://backup@10.0.0.1/restore/", "t0k3n")
+	if planErr != nil {
+		t.Fatalf("plan: %v", planErr)
+	}
+	if len(plan) != 2 || plan[0] != "configure replace scp://backup@10.0.0.1/restore/t0k3n/lab1.3 time 300" {
+		t.Errorf("unexpected plan: %q", plan)
+	}
+
+	// only config section is served to the device
+	want := "hostname lab1\n!\ninterface Gi0/0\n"
+	if got := strings.Join(staged, "\n") + "\n"; got != want {
+		t.Errorf("staged: wanted=%q got=%q", want, got)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+	stage := t.TempDir()
+
+	token1, _ := restoreStageToken()
+	token2, _ := restoreStageToken()
+	if len(token1) != 32 || token1 == token2 {
+		t.Errorf("guessable tokens: %s %s", token1, token2)
+	}
+
+	dir, stageErr := stageRestoreConfig(stage, token1, "lab1.3", staged)
+	if stageErr != nil {
+		t.Fatalf("stage: %v", stageErr)
+	}
+	path := filepath.Join(dir, "lab1.3")
+	if pathInside(repo, path) || path != filepath.Join(stage, token1, "lab1.3") {
+		t.Errorf("staged path: %s", path)
+	}
+	b, _ := store.FileRead(path, 1000)
+	if string(b) != want {
+		t.Errorf("staged file: wanted=%q got=%q", want, b)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("staged file: bad permissions: %v %v", info, err)
+	}
+
+	if _, _, err := d.restorePlan("lab1.3", saved, "", token1); err == nil {
+		t.Errorf("missing restoreurl: expected error")
+	}
+
+	// staging under the repository would expose unmasked secrets thru the web UI
+	opt := &conf.AppConfig{MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, RestoreURL: "file://" + repo}
+	change := conf.Change{When: time.Now(), By: "tester"}
+	if err := store.MkDir(d.DeviceDir(repo)); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := store.SaveConfigCopy(d.DevicePathPrefix(d.DeviceDir(repo)), 3, 0, logger, func(w store.HasWrite) error {
+		_, err := w.Write(saved)
+		return err
+	}, ""); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := Restore(tab, logger, "lab1", "lab1.3", false, "lab1", change, repo, filepath.Join(repo, "restore"), filepath.Join(repo, "errlog_test."), opt, NewFilterTable(logger)); err == nil || !strings.Contains(err.Error(), "outside the repository") {
+		t.Errorf("stage path under repository: expected error, got: %v", err)
+	}
+}
+
+func TestRestoreJunosOverride(t *testing.T) {
+
+	saved := []string{"system {", "    host-name lab1;", "}"}
+	config := &junosConfig{}
+	config.set(saved)
+
+	// launch bogus test server
+	addr := ":2035"
+	s, listenErr := spawnServerJuniperJunOS(t, addr, optionsJunos{config: config})
+	if listenErr != nil {
+		t.Errorf("could not spawn bogus JunOS server: %v", listenErr)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+	stage := t.TempDir()
+
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	opt := conf.NewOptions()
+	opt.Set(&conf.AppConfig{MaxConcurrency: 3, MaxConfigFiles: 10, MaxConfigLoadSize: 1000000, RestoreURL: "file://" + stage})
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "junos", "lab1", "localhost"+addr, "telnet", "lab", "pass", "", false, nil)
+
+	// take backup to restore from
+	requestCh := make(chan FetchRequest)
+	logPrefix := filepath.Join(repo, "errlog_test.")
+	ft := NewFilterTable(logger)
+	go Spawner(tab, logger, requestCh, repo, logPrefix, opt, ft)
+	good, bad, skip := Scan(tab, tab.ListDevices(), logger, opt.Get(), requestCh)
+	if good != 1 || bad != 0 || skip != 0 {
+		t.Errorf("good=%d bad=%d skip=%d", good, bad, skip)
+	}
+	close(requestCh)
+
+	d, _ := tab.GetDevice("lab1")
+	last, lastErr := store.FindLastConfig(d.DevicePathPrefix(d.DeviceDir(repo)), logger)
+	if lastErr != nil {
+		t.Fatalf("no backup: %v", lastErr)
+	}
+
+	// statement added after backup must be removed by restore
+	config.set([]string{"system {", "    host-name lab1;", "    domain-name added.example;", "}"})
+
+	change := conf.Change{When: time.Now(), By: "tester"}
+	if _, err := Restore(tab, logger, "lab1", filepath.Base(last), false, "lab1", change, repo, stage, logPrefix, opt.Get(), ft); err != nil {
+		t.Errorf("restore: %v", err)
+	}
+
+	if got := strings.Join(config.get(), "\n"); got != strings.Join(saved, "\n") {
+		t.Errorf("restored config: wanted=%q got=%q", strings.Join(saved, "\n"), got)
+	}
+
+	s.close() // shutdown server
+
+	<-s.done // wait termination of accept loop goroutine
+}
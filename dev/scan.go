@@ -31,13 +31,38 @@ func Spawner(tab DeviceUpdater, logger hasPrintf, reqChan chan FetchRequest, rep
 			continue
 		}
 
-		opt := options.Get()                                                      // get current global data
-		go d.Fetch(tab, logger, replyChan, 0, repository, logPathPrefix, opt, ft) // spawn per-request goroutine
+		opt := options.Get()                                                                    // get current global data
+		go fetchPooled(tab, logger, d, req.Pool, replyChan, repository, logPathPrefix, opt, ft) // spawn per-request goroutine
 	}
 
 	logger.Printf("Spawner: exiting")
 }
 
+// poolPollInterval is how often Scan checks pools held full by requests from elsewhere, like manual backups.
+const poolPollInterval = time.Second
+
+// fetchPooled runs Fetch within the device concurrency pool and the global session limit,
+// then releases both slots before replying.
+// Requests not holding a pool slot wait for one.
+func fetchPooled(tab DeviceUpdater, logger hasPrintf, d *Device, pool string, replyChan chan FetchResult, repository, logPathPrefix string, opt *conf.AppConfig, ft *FilterTable) {
+	if pool == "" {
+		pool = d.concurrencyPool()
+		tab.AcquirePool(pool, PoolLimit(opt, pool), true)
+	}
+	tab.AcquireSession(opt.MaxConcurrency) // shared with ad-hoc commands and restores
+
+	resultCh := make(chan FetchResult, 1)
+	d.Fetch(tab, logger, resultCh, 0, repository, logPathPrefix, opt, ft)
+	result := <-resultCh
+
+	tab.ReleaseSession()
+	tab.ReleasePool(pool)
+
+	if replyChan != nil {
+		replyChan <- result
+	}
+}
+
 // Scan scans the list of devices dispatching backup requests to the Spawner thru the request channel reqChan.
 func Scan(tab DeviceUpdater, devices []*Device, logger hasPrintf, opt *conf.AppConfig, reqChan chan FetchRequest) (int, int, int) {
 
@@ -48,18 +73,48 @@ func Scan(tab DeviceUpdater, devices []*Device, logger hasPrintf, opt *conf.AppC
 	}
 
 	begin := time.Now()
-	wait := 0       // requests pending
-	nextDevice := 0 // device iterator
+	wait := 0                    // requests pending
+	nextDevice := 0              // device iterator
+	var held []*Device           // due devices waiting for a slot in their concurrency pool
+	pools := map[string]string{} // device id => concurrency pool
 	req := FetchRequest{ReplyChan: make(chan FetchResult)}
 	maxConcurrency := opt.MaxConcurrency // alias
-	holdtime := opt.Holdtime             // alias
 	elapMax := 0 * time.Second
 	elapMin := 24 * time.Hour
 	success := 0
 	skipped := 0
 	deleted := 0
 
-	for nextDevice < deviceCount || wait > 0 {
+	// launch sends a request for a due device, unless its concurrency pool is full
+	launch := func(d *Device) bool {
+		pool, found := pools[d.ID]
+		if !found {
+			pool = d.concurrencyPool()
+			pools[d.ID] = pool
+		}
+		if !tab.AcquirePool(pool, PoolLimit(opt, pool), false) {
+			return false
+		}
+
+		req.ID = d.ID
+		req.Pool = pool
+		reqChan <- req
+
+		wait++ // launched
+		logger.Printf("Scan: launched: %s count=%d/%d wait=%d max=%d pool=%s held=%d", req.ID, nextDevice, deviceCount, wait, maxConcurrency, pool, len(held))
+		return true
+	}
+
+	for nextDevice < deviceCount || len(held) > 0 || wait > 0 {
+		// retry devices held by full pools, keeping table order
+		remain := held[:0]
+		for _, d := range held {
+			if (maxConcurrency > 0 && wait >= maxConcurrency) || !launch(d) {
+				remain = append(remain, d)
+			}
+		}
+		held = remain
+
 		// launch requests
 		for ; nextDevice < deviceCount; nextDevice++ {
 			if maxConcurrency > 0 && wait >= maxConcurrency {
@@ -73,21 +128,34 @@ func Scan(tab DeviceUpdater, devices []*Device, logger hasPrintf, opt *conf.AppC
 				continue
 			}
 
-			if h := d.Holdtime(time.Now(), holdtime); h > 0 {
-				// do not handle device yet (holdtime not expired)
-				logger.Printf("Scan: %s skipping due to holdtime=%s", d.ID, h)
+			if d.Quarantined() {
+				// do not handle device until released
+				logger.Printf("Scan: %s skipping quarantined device: failures=%d", d.ID, d.Failures())
 				skipped++
 				continue
 			}
 
-			req.ID = d.ID
-			reqChan <- req
+			now := time.Now()
+			next, schedErr := d.NextRun(now, opt)
+			if schedErr != nil {
+				logger.Printf("Scan: %s schedule: %v", d.ID, schedErr)
+			}
+			if next.After(now) {
+				// do not handle device yet (not due)
+				logger.Printf("Scan: %s skipping until next run=%s", d.ID, next.Format(time.RFC3339))
+				skipped++
+				continue
+			}
 
-			wait++ // launched
-			logger.Printf("Scan: launched: %s count=%d/%d wait=%d max=%d", req.ID, nextDevice, deviceCount, wait, maxConcurrency)
+			if !launch(d) {
+				held = append(held, d) // pool full
+			}
 		}
 
 		if wait < 1 {
+			if len(held) > 0 {
+				time.Sleep(poolPollInterval) // pools filled by requests from elsewhere
+			}
 			continue
 		}
 
@@ -120,7 +188,7 @@ func Scan(tab DeviceUpdater, devices []*Device, logger hasPrintf, opt *conf.AppC
 	return success, deviceCount - success, skipped + deleted
 }
 
-func updateDeviceStatus(tab DeviceUpdater, devID string, good bool, last time.Time, elapsed time.Duration, logger hasPrintf, holdtime time.Duration) {
+func updateDeviceStatus(tab DeviceUpdater, devID string, good bool, last time.Time, elapsed time.Duration, logger hasPrintf, opt *conf.AppConfig, logPathPrefix string) {
 	d, getErr := tab.GetDevice(devID)
 	if getErr != nil {
 		logger.Printf("updateDeviceStatus: '%s' not found: %v", devID, getErr)
@@ -128,6 +196,7 @@ func updateDeviceStatus(tab DeviceUpdater, devID string, good bool, last time.Ti
 	}
 
 	now := time.Now()
+	holdtime := opt.Holdtime // alias
 	h1 := d.Holdtime(now, holdtime)
 
 	d.lastTry = last
@@ -136,9 +205,19 @@ func updateDeviceStatus(tab DeviceUpdater, devID string, good bool, last time.Ti
 	if d.lastStatus {
 		d.lastSuccess = d.lastTry
 	}
+	changed, quarantined := d.countFailure(good, last, opt.QuarantineAfter)
 
 	tab.UpdateDevice(d)
 
+	if changed {
+		if saveErr := saveFailureState(logPathPrefix, d); saveErr != nil {
+			logger.Printf("updateDeviceStatus: device %s: %v", devID, saveErr)
+		}
+	}
+	if quarantined {
+		logger.Printf("updateDeviceStatus: device %s quarantined after %d consecutive failures", devID, d.failures)
+	}
+
 	h2 := d.Holdtime(now, holdtime)
 	logger.Printf("updateDeviceStatus: device %s holdtime: old=%v new=%v", devID, h1, h2)
 }
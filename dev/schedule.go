@@ -0,0 +1,269 @@
+package dev
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+// cronSchedule is a parsed cron expression: minute hour day-of-month month day-of-week.
+// Every field is a bit set of allowed values.
+type cronSchedule struct {
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool // day-of-month unrestricted
+	dowStar  bool // day-of-week unrestricted
+	location *time.Location
+}
+
+// cronDescriptors are shortcuts for common cron expressions.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes the range of values for a cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7}, // both 0 and 7 are sunday
+}
+
+// ValidateSchedule checks a cron expression and its timezone. Empty expression is valid.
+func ValidateSchedule(spec, timezone string) error {
+	if spec == "" {
+		if _, err := loadTimezone(timezone); err != nil {
+			return err
+		}
+		return nil
+	}
+	_, err := parseSchedule(spec, timezone)
+	return err
+}
+
+// parseSchedule parses a cron expression, like "*/30 8-17 * * 1-5", or a descriptor, like "@daily".
+// Empty timezone means local time.
+func parseSchedule(spec, timezone string) (*cronSchedule, error) {
+	loc, locErr := loadTimezone(timezone)
+	if locErr != nil {
+		return nil, locErr
+	}
+
+	expr := strings.TrimSpace(spec)
+	if d, found := cronDescriptors[expr]; found {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("bad schedule '%s': expected %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("bad schedule '%s': %v", spec, err)
+		}
+		sets[i] = set
+	}
+
+	s := &cronSchedule{
+		minute:   sets[0],
+		hour:     sets[1],
+		dom:      sets[2],
+		month:    sets[3],
+		dow:      sets[4],
+		domStar:  strings.HasPrefix(fields[2], "*"),
+		dowStar:  strings.HasPrefix(fields[4], "*"),
+		location: loc,
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is sunday
+	}
+
+	return s, nil
+}
+
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("bad schedule timezone '%s': %v", timezone, err)
+	}
+	return loc, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges and steps: 1,5-10,*/15
+func parseCronField(field string, r cronField) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("%s: bad step in '%s'", r.name, item)
+			}
+			step = s
+			item = item[:i]
+		}
+
+		first, last := r.min, r.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err1, err2 error
+			first, err1 = strconv.Atoi(bounds[0])
+			last, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || first > last {
+				return 0, fmt.Errorf("%s: bad range '%s'", r.name, item)
+			}
+		default:
+			v, err := strconv.Atoi(item)
+			if err != nil {
+				return 0, fmt.Errorf("%s: bad value '%s'", r.name, item)
+			}
+			first = v
+			if step == 1 {
+				last = v // single value
+			}
+		}
+
+		if first < r.min || last > r.max {
+			return 0, fmt.Errorf("%s: '%s' out of range %d-%d", r.name, item, r.min, r.max)
+		}
+
+		for v := first; v <= last; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func cronHas(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// dayMatches applies the classic cron rule: when both day-of-month and day-of-week are restricted, either one matches.
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cronHas(s.dom, t.Day())
+	dowMatch := cronHas(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// next finds the first scheduled time after t.
+// Zero time means the schedule never fires, like february 30.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := s.location
+	t = t.In(loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !cronHas(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !cronHas(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !cronHas(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// schedule gets the effective cron schedule for the device:
+// device attributes (including profiles), then global options.
+// Nil schedule means the device is driven by holdtime.
+func (d *Device) schedule(opt *conf.AppConfig) (*cronSchedule, error) {
+	spec, timezone := d.attr.Schedule, d.attr.ScheduleTimezone
+	if spec == "" {
+		spec = opt.Schedule
+	}
+	if timezone == "" {
+		timezone = opt.ScheduleTimezone
+	}
+	if spec == "" {
+		return nil, nil
+	}
+	return parseSchedule(spec, timezone)
+}
+
+// NextRun informs when the device is due for backup. Time not after now means due now.
+// Scheduled devices are due at the first scheduled time after the last backup attempt.
+// Other devices are due when holdtime expires after the last successful backup.
+// Failing devices are further delayed by exponential backoff.
+// A bad schedule is reported, along with the holdtime-based time.
+func (d *Device) NextRun(now time.Time, opt *conf.AppConfig) (time.Time, error) {
+	next, err := d.nextScheduled(now, opt)
+	if retry := d.retryAfter(opt.FailureBackoff, opt.FailureBackoffMax); retry.After(next) {
+		next = retry
+	}
+	return next, err
+}
+
+// nextScheduled informs when the device is due for backup, disregarding failures.
+func (d *Device) nextScheduled(now time.Time, opt *conf.AppConfig) (time.Time, error) {
+	holdtimeNext := now.Add(d.Holdtime(now, opt.Holdtime))
+
+	s, schedErr := d.schedule(opt)
+	if schedErr != nil {
+		return holdtimeNext, schedErr
+	}
+	if s == nil {
+		return holdtimeNext, nil
+	}
+
+	last := d.lastTry
+	if d.lastSuccess.After(last) {
+		last = d.lastSuccess // last try is not persisted
+	}
+	if d.lastFailure.After(last) {
+		last = d.lastFailure // persisted along with failure counters
+	}
+	if last.IsZero() {
+		return now, nil // never tried
+	}
+
+	next := s.next(last)
+	if next.IsZero() {
+		return holdtimeNext, fmt.Errorf("schedule never fires")
+	}
+
+	return next, nil
+}
@@ -0,0 +1,120 @@
+package dev
+
+import (
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+)
+
+func TestScheduleNext(t *testing.T) {
+	table := []struct {
+		spec     string
+		timezone string
+		after    string // RFC3339
+		want     string // RFC3339
+	}{
+		{"0 */2 * * *", "UTC", "2024-03-04T10:00:00Z", "2024-03-04T12:00:00Z"},
+		{"0 */2 * * *", "UTC", "2024-03-04T11:59:59Z", "2024-03-04T12:00:00Z"},
+		{"0 2 * * *", "America/Sao_Paulo", "2024-03-04T10:00:00Z", "2024-03-05T05:00:00Z"}, // 02:00 local time
+		{"*/30 8-17 * * 1-5", "UTC", "2024-03-08T17:30:00Z", "2024-03-11T08:00:00Z"},       // friday evening => monday morning
+		{"*/30 8-17 * * 1-5", "UTC", "2024-03-11T08:10:00Z", "2024-03-11T08:30:00Z"},
+		{"0 0 1 * 0", "UTC", "2024-03-04T00:00:00Z", "2024-03-10T00:00:00Z"}, // day-of-month or day-of-week
+		{"0 0 29 2 *", "UTC", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"@daily", "UTC", "2024-12-31T23:59:00Z", "2025-01-01T00:00:00Z"},
+		{"15 3 * * 7", "UTC", "2024-03-04T00:00:00Z", "2024-03-10T03:15:00Z"}, // 7 is sunday
+	}
+
+	for _, data := range table {
+		s, err := parseSchedule(data.spec, data.timezone)
+		if err != nil {
+			t.Errorf("parse '%s': %v", data.spec, err)
+			continue
+		}
+		after, _ := time.Parse(time.RFC3339, data.after)
+		want, _ := time.Parse(time.RFC3339, data.want)
+		if got := s.next(after); !got.Equal(want) {
+			t.Errorf("'%s' tz=%s after %s: wanted=%s got=%s", data.spec, data.timezone, data.after, want, got.UTC())
+		}
+	}
+
+	if s, _ := parseSchedule("0 0 30 2 *", "UTC"); !s.next(time.Now()).IsZero() {
+		t.Errorf("february 30 should never fire")
+	}
+}
+
+func TestScheduleBad(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "x * * * *", "@often"} {
+		if err := ValidateSchedule(spec, ""); err == nil {
+			t.Errorf("bad schedule '%s': expected error", spec)
+		}
+	}
+	if err := ValidateSchedule("0 2 * * *", "Nowhere/Bogus"); err == nil {
+		t.Errorf("bad timezone: expected error")
+	}
+	if err := ValidateSchedule("", ""); err != nil {
+		t.Errorf("empty schedule: %v", err)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+
+	if err := tab.SetProfiles([]conf.Profile{{Name: "firewalls", Attr: conf.AttrOverrides{"schedule": "*/30 8-17 * * 1-5", "scheduletimezone": "UTC"}}}); err != nil {
+		t.Fatalf("set profiles: %v", err)
+	}
+
+	newDev := func(id string, profiles []string, attr conf.AttrOverrides) *Device {
+		cfg := conf.DevConfig{Model: "cisco-ios", ID: id, HostPort: "localhost", Profiles: profiles, Attr: attr}
+		d, _, err := NewDeviceFromConf(tab, logger, &cfg)
+		if err != nil {
+			t.Fatalf("new device %s: %v", id, err)
+		}
+		return d
+	}
+
+	opt := &conf.AppConfig{Holdtime: 12 * time.Hour, Schedule: "0 2 * * *", ScheduleTimezone: "UTC"}
+
+	now, _ := time.Parse(time.RFC3339, "2024-03-04T10:00:00Z") // monday
+	last := now.Add(-time.Minute)
+
+	global := newDev("global", nil, nil)
+	fw := newDev("fw", []string{"firewalls"}, nil)
+	core := newDev("core", []string{"firewalls"}, conf.AttrOverrides{"schedule": "0 */2 * * *"}) // device overrides profile
+
+	table := []struct {
+		d    *Device
+		want string
+	}{
+		{global, "2024-03-05T02:00:00Z"},
+		{fw, "2024-03-04T10:00:00Z"},
+		{core, "2024-03-04T10:00:00Z"},
+	}
+	for _, data := range table {
+		data.d.lastTry = last
+		next, err := data.d.NextRun(now, opt)
+		want, _ := time.Parse(time.RFC3339, data.want)
+		if err != nil || !next.Equal(want) {
+			t.Errorf("%s: wanted=%s got=%s err=%v", data.d.ID, want, next.UTC(), err)
+		}
+	}
+
+	// never tried: due now
+	if next, _ := newDev("new", nil, nil).NextRun(now, opt); next.After(now) {
+		t.Errorf("never tried: next=%s", next)
+	}
+
+	// no schedule: holdtime
+	global.lastSuccess = last
+	if next, _ := global.NextRun(now, &conf.AppConfig{Holdtime: time.Hour}); !next.Equal(last.Add(time.Hour)) {
+		t.Errorf("holdtime: next=%s", next)
+	}
+
+	// bad device schedule rejected
+	cfg := conf.DevConfig{Model: "cisco-ios", ID: "bad", HostPort: "localhost", Attr: conf.AttrOverrides{"schedule": "0 25 * * *"}}
+	if _, _, err := NewDeviceFromConf(tab, logger, &cfg); err == nil {
+		t.Errorf("bad device schedule: expected error")
+	}
+}
@@ -0,0 +1,159 @@
+package dev
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/udhos/jazigo/store"
+)
+
+// Secret references may replace literal credentials in device properties:
+//
+//	env:NAME                  value of environment variable NAME
+//	file:/run/secrets/x       contents of file (trailing newline removed)
+//	exec:/path/to/prog args   stdout of external program (trailing newline removed)
+//
+// Any other value is taken literally.
+const (
+	secretPrefixEnv  = "env:"
+	secretPrefixFile = "file:"
+	secretPrefixExec = "exec:"
+)
+
+const (
+	secretMaxFileSize = 100000           // refuse to load huge secret files
+	secretExecTimeout = 10 * time.Second // time allowed for external secret program
+)
+
+type secretEntry struct {
+	value   string
+	expires time.Time
+}
+
+// secretCache keeps resolved secret references for a limited period.
+// Cached values are never logged.
+type secretCache struct {
+	entries map[string]secretEntry // cache key => resolved value
+	lock    sync.Mutex
+}
+
+func newSecretCache() *secretCache {
+	return &secretCache{entries: map[string]secretEntry{}}
+}
+
+var secrets = newSecretCache()
+
+// isSecretRef reports whether a credential value is a reference to an external secret.
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretPrefixEnv) ||
+		strings.HasPrefix(value, secretPrefixFile) ||
+		strings.HasPrefix(value, secretPrefixExec)
+}
+
+// secretKey identifies a cached secret. Program output might depend on the device, so exec references are cached per device.
+func secretKey(devID, ref string) string {
+	if strings.HasPrefix(ref, secretPrefixExec) {
+		return devID + "\x00" + ref
+	}
+	return ref
+}
+
+// get resolves a secret reference, possibly from cache.
+// ttl <= 0 disables caching.
+func (c *secretCache) get(devID, ref string, ttl time.Duration) (string, error) {
+	if !isSecretRef(ref) {
+		return ref, nil // literal value
+	}
+
+	now := time.Now()
+	key := secretKey(devID, ref)
+
+	c.lock.Lock()
+	e, found := c.entries[key]
+	c.lock.Unlock()
+
+	if found && now.Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := resolveSecret(devID, ref)
+	if err != nil {
+		return "", err
+	}
+
+	if ttl > 0 {
+		c.lock.Lock()
+		c.entries[key] = secretEntry{value: value, expires: now.Add(ttl)}
+		c.lock.Unlock()
+	}
+
+	return value, nil
+}
+
+// flush forgets all cached secrets.
+func (c *secretCache) flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = map[string]secretEntry{}
+}
+
+// FlushSecrets discards all cached secret values, forcing new resolution on next fetch.
+func FlushSecrets() {
+	secrets.flush()
+}
+
+// resolveSecret fetches the value for a secret reference.
+// Error messages mention the reference, never the value.
+func resolveSecret(devID, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretPrefixEnv):
+		name := strings.TrimPrefix(ref, secretPrefixEnv)
+		value, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("resolveSecret: '%s': environment variable not defined", ref)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretPrefixFile):
+		path := strings.TrimPrefix(ref, secretPrefixFile)
+		b, readErr := store.FileRead(path, secretMaxFileSize)
+		if readErr != nil {
+			return "", fmt.Errorf("resolveSecret: '%s': %v", ref, readErr)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	case strings.HasPrefix(ref, secretPrefixExec):
+		args := strings.Fields(strings.TrimPrefix(ref, secretPrefixExec))
+		if len(args) < 1 {
+			return "", fmt.Errorf("resolveSecret: '%s': missing program", ref)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+		defer cancel()
+		c := exec.CommandContext(ctx, args[0], args[1:]...)
+		c.Env = append(os.Environ(), "JAZIGO_DEV_ID="+devID)
+		out, runErr := c.Output()
+		if runErr != nil {
+			return "", fmt.Errorf("resolveSecret: '%s': %v", ref, runErr)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+	return ref, nil
+}
+
+// resolveSecrets replaces secret references in device credentials with actual values.
+// It must be called on a private copy of the device, since resolved values must not
+// reach the device table nor the saved configuration.
+func (d *Device) resolveSecrets(ttl time.Duration) error {
+	fields := []*string{&d.LoginPassword, &d.EnablePassword, &d.SSHKeyPassphrase}
+	for _, f := range fields {
+		value, err := secrets.get(d.ID, *f, ttl)
+		if err != nil {
+			return err
+		}
+		*f = value
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package dev
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/temp"
+)
+
+func TestSecretLiteral(t *testing.T) {
+	c := newSecretCache()
+	expectSecret(t, c, "lab1", "plain", "plain")
+	expectSecret(t, c, "lab1", "", "")
+}
+
+func TestSecretEnv(t *testing.T) {
+	os.Setenv("JAZIGO_TEST_SECRET", "s3cr3t")
+	defer os.Unsetenv("JAZIGO_TEST_SECRET")
+
+	c := newSecretCache()
+	expectSecret(t, c, "lab1", "env:JAZIGO_TEST_SECRET", "s3cr3t")
+
+	if _, err := c.get("lab1", "env:JAZIGO_TEST_SECRET_UNDEFINED", time.Minute); err == nil {
+		t.Errorf("undefined env var: expected error")
+	}
+}
+
+func TestSecretFile(t *testing.T) {
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	path := filepath.Join(repo, "secret")
+	if err := os.WriteFile(path, []byte("filepass\n"), 0600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+
+	c := newSecretCache()
+	expectSecret(t, c, "lab1", "file:"+path, "filepass")
+
+	if _, err := c.get("lab1", "file:"+path+".missing", time.Minute); err == nil {
+		t.Errorf("missing file: expected error")
+	}
+}
+
+func TestSecretExecCache(t *testing.T) {
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	// stub script: prints its argument and device id, counts invocations
+	counter := filepath.Join(repo, "count")
+	script := filepath.Join(repo, "getsecret")
+	stub := "#!/bin/sh\necho x >> " + counter + "\necho \"$1-$JAZIGO_DEV_ID\"\n"
+	if err := os.WriteFile(script, []byte(stub), 0700); err != nil {
+		t.Fatalf("write stub script: %v", err)
+	}
+
+	c := newSecretCache()
+	ref := "exec:" + script + " key"
+
+	expectSecret(t, c, "lab1", ref, "key-lab1")
+	expectSecret(t, c, "lab1", ref, "key-lab1") // cached
+
+	b, _ := os.ReadFile(counter)
+	if len(b) != 2 {
+		t.Errorf("cache: expected single stub invocation, got %d", len(b)/2)
+	}
+
+	// ttl=0 disables cache
+	c.flush()
+	if _, err := c.get("lab1", ref, 0); err != nil {
+		t.Errorf("ttl=0: %v", err)
+	}
+	if _, err := c.get("lab1", ref, 0); err != nil {
+		t.Errorf("ttl=0: %v", err)
+	}
+	b, _ = os.ReadFile(counter)
+	if len(b) != 6 {
+		t.Errorf("ttl=0: expected 3 stub invocations, got %d", len(b)/2)
+	}
+
+	// program output depends on device: never shared between devices
+	expectSecret(t, c, "lab1", ref, "key-lab1")
+	expectSecret(t, c, "lab2", ref, "key-lab2")
+	expectSecret(t, c, "lab1", ref, "key-lab1") // cached
+	b, _ = os.ReadFile(counter)
+	if len(b) != 10 {
+		t.Errorf("per-device cache: expected 5 stub invocations, got %d", len(b)/2)
+	}
+
+	if _, err := c.get("lab1", "exec:/bin/false", time.Minute); err == nil {
+		t.Errorf("failing program: expected error")
+	}
+}
+
+func TestSecretFetchError(t *testing.T) {
+	logger := &testLogger{t}
+	tab := NewDeviceTable()
+	RegisterModels(logger, tab)
+	CreateDevice(tab, logger, "cisco-ios", "lab1", "localhost:2001", "telnet", "lab", "env:JAZIGO_TEST_SECRET_UNDEFINED", "en", false, nil)
+
+	d, getErr := tab.GetDevice("lab1")
+	if getErr != nil {
+		t.Fatalf("get device: %v", getErr)
+	}
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	result := d.fetch(logger, 0, repo, &conf.AppConfig{MaxConfigFiles: 10, SecretCacheTTL: time.Minute}, NewFilterTable(logger))
+	if result.Code != fetchErrSecret {
+		t.Errorf("expected code=%d got code=%d msg=[%s]", fetchErrSecret, result.Code, result.Msg)
+	}
+}
+
+func expectSecret(t *testing.T, c *secretCache, devID, ref, want string) {
+	got, err := c.get(devID, ref, time.Minute)
+	if err != nil {
+		t.Errorf("secret '%s': unexpected error: %v", ref, err)
+		return
+	}
+	if got != want {
+		t.Errorf("secret '%s': wanted=[%s] got=[%s]", ref, want, got)
+	}
+}
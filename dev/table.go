@@ -2,29 +2,56 @@ package dev
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+
+	"github.com/udhos/jazigo/conf"
 )
 
 // DeviceTable is goroutine concurrency-safe list of devices.
 // Data is fully copied when either entering or leaving DeviceTable.
 // Data is not shared with pointers.
 type DeviceTable struct {
-	models  map[string]*Model  // label => model
-	devices map[string]*Device // id => device
-	lock    sync.RWMutex
+	models   map[string]*Model        // label => model
+	profiles map[string]*conf.Profile // name => profile
+	devices  map[string]*Device       // id => device
+	lock     sync.RWMutex
+
+	policies   []*policy                    // compliance policies
+	compliance map[string]*ComplianceReport // id => latest compliance report
+	facts      map[string]*Facts            // id => latest device facts
+
+	changeHook func(conf.Change) // called when the device table changes device configs on its own
+
+	pools    map[string]int // pool name => device sessions open
+	sessions int            // device sessions open: backups, ad-hoc commands and restores
+	poolLock sync.Mutex
+	poolCond *sync.Cond // signals released pool and session slots
 }
 
 // DeviceUpdater is helper interface for a device store which can provide and update device information.
 type DeviceUpdater interface {
+	GetModel(modelName string) (*Model, error)
+	GetProfile(name string) (*conf.Profile, error)
 	GetDevice(id string) (*Device, error)
 	UpdateDevice(d *Device) error
+	ListModels() []string
+	SetDeviceModel(id, modelName string, change conf.Change) error
+	CheckCompliance(id, repository string, opt *conf.AppConfig, logger hasPrintf) (*ComplianceReport, error)
+	LoadFacts(id, repository string, opt *conf.AppConfig, logger hasPrintf) (*Facts, error)
+	AcquirePool(pool string, limit int, wait bool) bool
+	ReleasePool(pool string)
+	AcquireSession(limit int)
+	ReleaseSession()
 }
 
 // NewDeviceTable creates a device table.
 func NewDeviceTable() *DeviceTable {
-	return &DeviceTable{models: map[string]*Model{}, devices: map[string]*Device{}, lock: sync.RWMutex{}}
+	t := &DeviceTable{models: map[string]*Model{}, profiles: map[string]*conf.Profile{}, devices: map[string]*Device{}, compliance: map[string]*ComplianceReport{}, facts: map[string]*Facts{}, lock: sync.RWMutex{}, pools: map[string]int{}}
+	t.poolCond = sync.NewCond(&t.poolLock)
+	return t
 }
 
 // GetModel looks up a model in the device table.
@@ -57,6 +84,128 @@ func (t *DeviceTable) SetModel(m *Model, logger hasPrintf) error {
 	return nil
 }
 
+// ReplaceCustomModels atomically swaps all user-defined models in the device table.
+// Built-in models are never replaced.
+func (t *DeviceTable) ReplaceCustomModels(models []*Model, logger hasPrintf) error {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, m := range models {
+		if old, found := t.models[m.name]; found && !old.custom {
+			return fmt.Errorf("DeviceTable.ReplaceCustomModels: model '%s' collides with built-in model", m.name)
+		}
+	}
+
+	for name, m := range t.models {
+		if m.custom {
+			delete(t.models, name)
+		}
+	}
+
+	for _, m := range models {
+		logger.Printf("DeviceTable.ReplaceCustomModels: registering model: '%s'", m.name)
+		m1 := *m // force copy data
+		m1.custom = true
+		t.models[m1.name] = &m1
+	}
+
+	return nil
+}
+
+// ListCustomModels gets the list of user-defined models.
+func (t *DeviceTable) ListCustomModels() []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var models []string
+	for name, m := range t.models {
+		if m.custom {
+			models = append(models, name)
+		}
+	}
+	return models
+}
+
+// GetProfile looks up a profile in the device table.
+func (t *DeviceTable) GetProfile(name string) (*conf.Profile, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if p, found := t.profiles[name]; found {
+		p1 := *p // force copy data
+		return &p1, nil
+	}
+
+	return nil, fmt.Errorf("DeviceTable.GetProfile: not found")
+}
+
+// SetProfiles validates and replaces the full set of profiles in the device table.
+// Profiles still used by devices cannot be removed.
+func (t *DeviceTable) SetProfiles(profiles []conf.Profile) error {
+	if err := conf.ValidateProfiles(profiles); err != nil {
+		return fmt.Errorf("DeviceTable.SetProfiles: %v", err)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	keep := map[string]bool{}
+	for _, p := range profiles {
+		keep[p.Name] = true
+	}
+	for name := range t.profiles {
+		if keep[name] {
+			continue
+		}
+		var members []string
+		for id, d := range t.devices {
+			if d.HasProfile(name) {
+				members = append(members, id)
+			}
+		}
+		if len(members) > 0 {
+			sort.Strings(members)
+			return fmt.Errorf("DeviceTable.SetProfiles: profile '%s' still used by devices: %s", name, strings.Join(members, ","))
+		}
+	}
+
+	t.profiles = map[string]*conf.Profile{}
+	for _, p := range profiles {
+		p1 := p // force copy data
+		t.profiles[p1.Name] = &p1
+	}
+	return nil
+}
+
+// ListProfiles gets the list of profiles sorted by name.
+func (t *DeviceTable) ListProfiles() []conf.Profile {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	profiles := make([]conf.Profile, 0, len(t.profiles))
+	for _, p := range t.profiles {
+		profiles = append(profiles, *p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
+
+// ListProfileMembers gets the IDs of devices using a profile.
+func (t *DeviceTable) ListProfileMembers(name string) []string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var members []string
+	for id, d := range t.devices {
+		if d.HasProfile(name) {
+			members = append(members, id)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
 // GetDevice finds a device in the device table.
 func (t *DeviceTable) GetDevice(id string) (*Device, error) {
 	t.lock.RLock()
@@ -118,6 +267,44 @@ func (t *DeviceTable) UpdateDevice(d *Device) error {
 	return nil
 }
 
+// SetChangeHook registers a function to be called whenever the device table
+// itself changes device configs, as in model autodetection.
+func (t *DeviceTable) SetChangeHook(hook func(conf.Change)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.changeHook = hook
+}
+
+// SetDeviceModel switches the model of a device in the device table, recording the change.
+func (t *DeviceTable) SetDeviceModel(id, modelName string, change conf.Change) error {
+	d, getErr := t.GetDevice(id)
+	if getErr != nil {
+		return fmt.Errorf("DeviceTable.SetDeviceModel: %v", getErr)
+	}
+
+	cfg := d.DevConfig
+	cfg.Model = modelName
+	cfg.LastChange = change
+	if cfgErr := d.SetDevConfig(t, &cfg); cfgErr != nil {
+		return fmt.Errorf("DeviceTable.SetDeviceModel: %v", cfgErr)
+	}
+
+	if updateErr := t.UpdateDevice(d); updateErr != nil {
+		return fmt.Errorf("DeviceTable.SetDeviceModel: %v", updateErr)
+	}
+
+	t.lock.RLock()
+	hook := t.changeHook
+	t.lock.RUnlock()
+
+	if hook != nil {
+		hook(change)
+	}
+
+	return nil
+}
+
 // ListDevices gets the list of devices.
 func (t *DeviceTable) ListDevices() []*Device {
 	t.lock.RLock()
@@ -209,7 +209,7 @@ func openPipe(logger hasPrintf, modelName, devID, hostPort, transports, user, pa
 	return s, nil
 }
 
-func openTransport(logger hasPrintf, modelName, devID, hostPort, transports, user, pass string,
+func openTransport(logger hasPrintf, modelName, devID, hostPort, transports, user, pass, sshKeyFile, sshKeyPassphrase string,
 	sshClearCiphers bool, sshAddCiphers []string) (transp, string, bool, error) {
 	tList := strings.Split(transports, ",")
 	if len(tList) < 1 {
@@ -224,7 +224,7 @@ func openTransport(logger hasPrintf, modelName, devID, hostPort, transports, use
 		switch t {
 		case "ssh":
 			hp := forceHostPort(hostPort, "22")
-			s, err := openSSH(logger, modelName, devID, hp, timeout, user, pass,
+			s, err := openSSH(logger, modelName, devID, hp, timeout, user, pass, sshKeyFile, sshKeyPassphrase,
 				sshClearCiphers, sshAddCiphers)
 			if err == nil {
 				return s, t, true, nil
@@ -264,9 +264,29 @@ func hostKeyCheck(hostname string, remote net.Addr, key ssh.PublicKey) error {
 	return nil // FIXME hostKeyCheck accept anything
 }
 
-func openSSH(logger hasPrintf, modelName, devID, hostPort string, timeout time.Duration, user, pass string,
+func sshKeySigner(keyFile, passphrase string) (ssh.Signer, error) {
+	key, readErr := os.ReadFile(keyFile)
+	if readErr != nil {
+		return nil, fmt.Errorf("sshKeySigner: %v", readErr)
+	}
+	if passphrase == "" {
+		return ssh.ParsePrivateKey(key)
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+}
+
+func openSSH(logger hasPrintf, modelName, devID, hostPort string, timeout time.Duration, user, pass, sshKeyFile, sshKeyPassphrase string,
 	sshClearCiphers bool, sshAddCiphers []string) (transp, error) {
 
+	auth := []ssh.AuthMethod{ssh.Password(pass)}
+	if sshKeyFile != "" {
+		signer, keyErr := sshKeySigner(sshKeyFile, sshKeyPassphrase)
+		if keyErr != nil {
+			return nil, fmt.Errorf("openSSH: key: %s %s %s - %v", modelName, devID, hostPort, keyErr)
+		}
+		auth = append([]ssh.AuthMethod{ssh.PublicKeys(signer)}, auth...)
+	}
+
 	conn, dialErr := net.DialTimeout("tcp", hostPort, timeout)
 	if dialErr != nil {
 		return nil, fmt.Errorf("openSSH: Dial: %s %s %s - %v", modelName, devID, hostPort, dialErr)
@@ -280,11 +300,9 @@ func openSSH(logger hasPrintf, modelName, devID, hostPort string, timeout time.D
 	conf.Ciphers = append(conf.Ciphers, sshAddCiphers...)
 
 	config := &ssh.ClientConfig{
-		Config: *conf,
-		User:   user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(pass),
-		},
+		Config:          *conf,
+		User:            user,
+		Auth:            auth,
 		Timeout:         timeout,
 		HostKeyCallback: hostKeyCheck,
 	}
@@ -3,17 +3,24 @@ module github.com/udhos/jazigo
 require (
 	github.com/aws/aws-sdk-go v1.44.266
 	github.com/icza/gowut v1.3.0
+	github.com/openconfig/gnmi v0.10.0
 	github.com/udhos/difflib v0.0.0-20170223180222-9237ff6aafff
 	github.com/udhos/equalfile v0.0.0-20180725151512-a22d6261a8df
 	github.com/udhos/lockfile v0.0.0-20160928001432-1d49c987357a
 	golang.org/x/crypto v0.9.0
+	google.golang.org/grpc v1.56.3
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
 )
 
 go 1.20
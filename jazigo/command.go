@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/udhos/jazigo/dev"
+	"github.com/udhos/jazigo/store"
+)
+
+// commandJob tracks an ad-hoc command run across a set of devices.
+type commandJob struct {
+	id       string
+	commands []string
+	results  map[string]dev.CommandResult // devID => latest result
+	done     bool
+	exported []string // export file names under runPath
+	lock     sync.Mutex
+}
+
+func newCommandJob(commands []string) *commandJob {
+	return &commandJob{
+		id:       time.Now().Format("20060102-150405.000"),
+		commands: commands,
+		results:  map[string]dev.CommandResult{},
+	}
+}
+
+func (j *commandJob) update(r dev.CommandResult) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.results[r.DevID] = r
+}
+
+// snapshot gets current results sorted by device ID.
+func (j *commandJob) snapshot() ([]dev.CommandResult, bool, []string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	results := make([]dev.CommandResult, 0, len(j.results))
+	for _, r := range j.results {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].DevID < results[j].DevID })
+
+	return results, j.done, j.exported
+}
+
+// run sends job commands to devices, then exports results under runPath.
+func (j *commandJob) run(jaz *app, devices []*dev.Device) []dev.CommandResult {
+	jaz.logf("commandJob %s: %d devices: %q", j.id, len(devices), j.commands)
+
+	results := dev.RunCommands(jaz.table, jaz.logger, devices, j.commands, jaz.options.Get(), j.update)
+
+	var exported []string
+	for _, ext := range []string{".json", ".zip"} {
+		name := j.id + ext
+		if err := exportCommandResults(filepath.Join(jaz.runPath, name), results); err != nil {
+			jaz.logf("commandJob %s: %v", j.id, err)
+			continue
+		}
+		exported = append(exported, name)
+	}
+
+	j.lock.Lock()
+	j.done = true
+	j.exported = exported
+	j.lock.Unlock()
+
+	jaz.logf("commandJob %s: done", j.id)
+
+	return results
+}
+
+// exportCommandResults saves results as JSON or zip, according to path extension.
+func exportCommandResults(path string, results []dev.CommandResult) error {
+	if err := store.MkDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("exportCommandResults: %v", err)
+	}
+
+	f, createErr := os.Create(path)
+	if createErr != nil {
+		return fmt.Errorf("exportCommandResults: %v", createErr)
+	}
+
+	var exportErr error
+	if strings.HasSuffix(path, ".zip") {
+		exportErr = dev.ExportCommandResultsZip(f, results)
+	} else {
+		exportErr = dev.ExportCommandResultsJSON(f, results)
+	}
+
+	if closeErr := f.Close(); exportErr == nil {
+		exportErr = closeErr
+	}
+
+	return exportErr
+}
+
+// stringList is a flag accepting multiple values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ";")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// cliRunCommands runs ad-hoc commands from command line, printing outputs as devices finish.
+func cliRunCommands(jaz *app, w io.Writer, commands []string, model, id, host, export string) error {
+	devices := dev.FilterDevices(jaz.table.ListDevices(), model, id, host)
+	if len(devices) < 1 {
+		return fmt.Errorf("runCommand: no device matched filters model=[%s] id=[%s] host=[%s]", model, id, host)
+	}
+
+	job := newCommandJob(commands)
+
+	progress := func(r dev.CommandResult) {
+		job.update(r)
+		if r.Status == dev.CommandRunning {
+			return
+		}
+		fmt.Fprintf(w, "=== %s %s %s status=%s elapsed=%v %s\n%s\n", r.DevID, r.Model, r.HostPort, r.Status, r.End.Sub(r.Begin), r.Msg, r.Output)
+	}
+
+	results := dev.RunCommands(jaz.table, jaz.logger, devices, commands, jaz.options.Get(), progress)
+
+	if export != "" {
+		if err := exportCommandResults(export, results); err != nil {
+			return err
+		}
+		jaz.logf("runCommand: results exported to: %s", export)
+	}
+
+	var failed int
+	for _, r := range results {
+		if r.Status != dev.CommandOK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("runCommand: %d of %d devices failed", failed, len(results))
+	}
+
+	return nil
+}
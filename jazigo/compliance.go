@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/dev"
+	"github.com/udhos/jazigo/store"
+)
+
+// loadPolicies replaces compliance policies with those found in policies directory.
+func loadPolicies(jaz *app) error {
+	policies, dirErr := conf.LoadPolicyDir(jaz.policyPath, jaz.options.Get().MaxConfigLoadSize)
+	if dirErr != nil {
+		return fmt.Errorf("loadPolicies: %v", dirErr)
+	}
+
+	jaz.logf("loadPolicies: %d policies (%s)", len(policies), jaz.policyPath)
+
+	if err := jaz.table.SetPolicies(policies); err != nil {
+		return fmt.Errorf("loadPolicies: %v", err)
+	}
+
+	return nil
+}
+
+// checkCompliance evaluates policies for all devices.
+func checkCompliance(jaz *app) {
+	failed := dev.CheckAllCompliance(jaz.table, jaz.logger, jaz.repositoryPath, jaz.options.Get())
+	jaz.logf("checkCompliance: %d reports, %d devices could not be checked", len(jaz.table.ListCompliance()), failed)
+}
+
+// exportCompliance saves the fleet compliance report as CSV or JSON, according to path extension.
+func exportCompliance(path string, reports []dev.ComplianceReport) error {
+	if err := store.MkDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("exportCompliance: %v", err)
+	}
+
+	f, createErr := os.Create(path)
+	if createErr != nil {
+		return fmt.Errorf("exportCompliance: %v", createErr)
+	}
+
+	var exportErr error
+	if strings.HasSuffix(path, ".csv") {
+		exportErr = dev.ExportComplianceCSV(f, reports)
+	} else {
+		exportErr = dev.ExportComplianceJSON(f, reports)
+	}
+
+	if closeErr := f.Close(); exportErr == nil {
+		exportErr = closeErr
+	}
+
+	return exportErr
+}
+
+// cliComplianceReport checks all devices, then exports the fleet report.
+func cliComplianceReport(jaz *app, path string) error {
+	checkCompliance(jaz)
+
+	reports := jaz.table.ListCompliance()
+
+	if err := exportCompliance(path, reports); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range reports {
+		if !r.Pass {
+			failed++
+		}
+	}
+
+	jaz.logf("complianceReport: %d devices, %d non-compliant, exported to: %s", len(reports), failed, path)
+
+	return nil
+}
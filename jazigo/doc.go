@@ -11,6 +11,8 @@ Usage:
 
 Flags are:
 
+	-complianceReport string
+	      check compliance for all devices, save report into this file (.csv or .json), then exit
 	-configPathPrefix string
 	      configuration path prefix
 	-deviceDelete
@@ -23,6 +25,10 @@ Flags are:
 	      purge devices specified in stdin
 	-disableStdoutLog
 	      disable logging to stdout
+	-filtersPath string
+	      directory for user-defined line filters
+	-inventoryReport string
+	      save device facts for all devices into this file (.csv or .json), then exit
 	-logCheckInterval duration
 	      interval for checking log file size
 	-logMaxFiles int
@@ -31,10 +37,34 @@ Flags are:
 	      size limit for log file
 	-logPathPrefix string
 	      log path prefix
+	-modelsPath string
+	      directory for user-defined device models
+	-policyPath string
+	      directory for compliance policies
 	-repositoryPath string
 	      repository path
+	-restoreConfirm string
+	      confirm actual restore by repeating the device id
+	-restoreDevice string
+	      restore saved config into device, then exit (dry run unless -restoreConfirm is given)
+	-restoreFile string
+	      saved file to restore with -restoreDevice
+	-restoreStagePath string
+	      directory for configs staged for devices restoring thru restoreurl
+	-runCommand value
+	      send ad-hoc command to devices, then exit (might be repeated)
+	-runExport string
+	      save -runCommand results into this file (.zip or .json)
+	-runHost string
+	      send -runCommand only to devices whose host contains this string
+	-runID string
+	      send -runCommand only to devices whose id contains this string
+	-runModel string
+	      send -runCommand only to devices whose model contains this string
 	-runOnce
 	      exit after scanning all devices once
+	-runPath string
+	      directory for ad-hoc command results
 	-s3region string
 	      AWS S3 region
 	-webListen string
@@ -46,7 +76,12 @@ By default, jazigo looks for these path prefixes under $JAZIGO_HOME:
 
 	etc/jazigo.conf. (can be overridden with -configPathPrefix)
 	log/jazigo.log.  (can be overridden with -logPathPrefix)
+	etc/filters      (can be overridden with -filtersPath)
+	etc/models       (can be overridden with -modelsPath)
+	etc/policies     (can be overridden with -policyPath)
 	repo             (can be overridden with -repositoryPath)
+	restore          (can be overridden with -restoreStagePath)
+	run              (can be overridden with -runPath)
 	www              (can be overridden with -wwwStaticPath)
 
 If $JAZIGO_HOME is not defined, jazigo home defaults to /var/jazigo.
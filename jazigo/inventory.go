@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/udhos/jazigo/dev"
+	"github.com/udhos/jazigo/store"
+)
+
+// loadFacts loads latest device facts for all devices.
+func loadFacts(jaz *app) {
+	failed := dev.LoadAllFacts(jaz.table, jaz.logger, jaz.repositoryPath, jaz.options.Get())
+	jaz.logf("loadFacts: %d devices with facts, %d devices could not be loaded", len(jaz.table.ListFacts()), failed)
+}
+
+// exportInventory saves the fleet inventory as CSV or JSON, according to path extension.
+func exportInventory(path string, facts []dev.Facts) error {
+	if err := store.MkDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("exportInventory: %v", err)
+	}
+
+	f, createErr := os.Create(path)
+	if createErr != nil {
+		return fmt.Errorf("exportInventory: %v", createErr)
+	}
+
+	var exportErr error
+	if strings.HasSuffix(path, ".csv") {
+		exportErr = dev.ExportInventoryCSV(f, facts)
+	} else {
+		exportErr = dev.ExportInventoryJSON(f, facts)
+	}
+
+	if closeErr := f.Close(); exportErr == nil {
+		exportErr = closeErr
+	}
+
+	return exportErr
+}
+
+// cliInventoryReport loads facts for all devices, then exports the fleet inventory.
+func cliInventoryReport(jaz *app, path string) error {
+	loadFacts(jaz)
+
+	facts := jaz.table.ListFacts()
+
+	if err := exportInventory(path, facts); err != nil {
+		return err
+	}
+
+	jaz.logf("inventoryReport: %d devices exported to: %s", len(facts), path)
+
+	return nil
+}
@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/icza/gowut/gwu"
@@ -28,6 +30,11 @@ type app struct {
 	configPathPrefix string
 	repositoryPath   string // filesystem
 	logPathPrefix    string
+	modelsPath       string // directory for user-defined models
+	filtersPath      string // directory for user-defined line filters
+	policyPath       string // directory for compliance policies
+	runPath          string // directory for ad-hoc command results, apart from repository
+	restoreStagePath string // directory for configs staged for restore, apart from repository
 	configLock       lockfile.Lockfile
 	repositoryLock   lockfile.Lockfile
 	logLock          lockfile.Lockfile
@@ -44,18 +51,31 @@ type app struct {
 
 	cssPath    string
 	repoPath   string // www
+	runWebPath string // www
 	staticPath string // www
 
+	sessions *webSessions // for handlers serving files only to logged users
+
 	logger *log.Logger
 
-	filterModel string
-	filterID    string
-	filterHost  string
+	filterModel   string
+	filterID      string
+	filterHost    string
+	filterProfile string
 
 	priority    chan string
 	requestChan chan dev.FetchRequest
 
 	filterTable *dev.FilterTable
+
+	configModels []conf.ModelConfig // user-defined models kept in main config
+	modelsLock   sync.Mutex
+
+	configFilters []conf.FilterConfig // user-defined line filters kept in main config
+	filtersLock   sync.Mutex
+
+	saveLock sync.Mutex // serializes saveConfig
+	saveErr  error      // config partially loaded: saving would drop data from disk
 }
 
 type hasPrintf interface {
@@ -74,6 +94,7 @@ func newApp() *app {
 		priority:    make(chan string),
 		requestChan: make(chan dev.FetchRequest),
 		repoPath:    "repo",   // www
+		runWebPath:  "runs",   // www
 		staticPath:  "static", // www
 	}
 
@@ -122,26 +143,56 @@ func main() {
 	var webListen string
 	var s3region string
 	var version bool
+	var restoreDev string
+	var restoreFile string
+	var restoreConfirm string
+	var runCommands stringList
+	var runModel string
+	var runID string
+	var runHost string
+	var runExport string
+	var complianceReport string
+	var inventoryReport string
 
 	defaultHome := defaultHomeDir()
 	defaultConfigPrefix := filepath.Join(defaultHome, "etc", "jazigo.conf.")
 	defaultRepo := filepath.Join(defaultHome, "repo")
 	defaultLogPrefix := filepath.Join(defaultHome, "log", "jazigo.log.")
 	defaultStaticDir := filepath.Join(defaultHome, "www")
+	defaultModelsPath := filepath.Join(defaultHome, "etc", "models")
+	defaultFiltersPath := filepath.Join(defaultHome, "etc", "filters")
+	defaultRunPath := filepath.Join(defaultHome, "run")
+	defaultPolicyPath := filepath.Join(defaultHome, "etc", "policies")
+	defaultRestoreStagePath := filepath.Join(defaultHome, "restore")
 
 	flag.StringVar(&jaz.configPathPrefix, "configPathPrefix", defaultConfigPrefix, "configuration path prefix")
 	flag.StringVar(&jaz.repositoryPath, "repositoryPath", defaultRepo, "repository path")
 	flag.StringVar(&jaz.logPathPrefix, "logPathPrefix", defaultLogPrefix, "log path prefix")
+	flag.StringVar(&jaz.modelsPath, "modelsPath", defaultModelsPath, "directory for user-defined device models")
+	flag.StringVar(&jaz.filtersPath, "filtersPath", defaultFiltersPath, "directory for user-defined line filters")
+	flag.StringVar(&jaz.policyPath, "policyPath", defaultPolicyPath, "directory for compliance policies")
+	flag.StringVar(&jaz.runPath, "runPath", defaultRunPath, "directory for ad-hoc command results")
+	flag.StringVar(&jaz.restoreStagePath, "restoreStagePath", defaultRestoreStagePath, "directory for configs staged for devices restoring thru restoreurl")
 	flag.StringVar(&staticDir, "wwwStaticPath", defaultStaticDir, "directory for static www content")
 	flag.StringVar(&webListen, "webListen", ":8080", "address:port for web UI")
 	flag.StringVar(&s3region, "s3region", defaultRegionName(), "AWS S3 region")
 	flag.BoolVar(&runOnce, "runOnce", false, "exit after scanning all devices once")
+	flag.Var(&runCommands, "runCommand", "send ad-hoc command to devices, then exit (might be repeated)")
+	flag.StringVar(&runModel, "runModel", "", "send -runCommand only to devices whose model contains this string")
+	flag.StringVar(&runID, "runID", "", "send -runCommand only to devices whose id contains this string")
+	flag.StringVar(&runHost, "runHost", "", "send -runCommand only to devices whose host contains this string")
+	flag.StringVar(&runExport, "runExport", "", "save -runCommand results into this file (.zip or .json)")
+	flag.StringVar(&complianceReport, "complianceReport", "", "check compliance for all devices, save report into this file (.csv or .json), then exit")
+	flag.StringVar(&inventoryReport, "inventoryReport", "", "save device facts for all devices into this file (.csv or .json), then exit")
 	flag.BoolVar(&deviceDelete, "deviceDelete", false, "delete devices specified in stdin")
 	flag.BoolVar(&devicePurge, "devicePurge", false, "purge devices specified in stdin")
 	flag.BoolVar(&deviceImport, "deviceImport", false, "import devices from stdin")
 	flag.BoolVar(&deviceList, "deviceList", false, "list devices to stdout")
 	flag.BoolVar(&disableStdoutLog, "disableStdoutLog", false, "disable logging to stdout")
 	flag.BoolVar(&version, "version", false, "show version and exit")
+	flag.StringVar(&restoreDev, "restoreDevice", "", "restore saved config into device, then exit (dry run unless -restoreConfirm is given)")
+	flag.StringVar(&restoreFile, "restoreFile", "", "saved file to restore with -restoreDevice")
+	flag.StringVar(&restoreConfirm, "restoreConfirm", "", "confirm actual restore by repeating the device id")
 	flag.IntVar(&logMaxFiles, "logMaxFiles", 20, "number of log files to keep")
 	flag.Int64Var(&logMaxSize, "logMaxSize", 10000000, "size limit for log file")
 	flag.DurationVar(&logCheckInterval, "logCheckInterval", time.Hour, "interval for checking log file size")
@@ -196,6 +247,15 @@ func main() {
 	// load config
 	loadConfig(jaz, maxMainConfigLoadSize)
 
+	if policyErr := loadPolicies(jaz); policyErr != nil {
+		jaz.logf("main: %v", policyErr)
+	}
+
+	// persist device changes made by the device table itself (model autodetection)
+	jaz.table.SetChangeHook(func(change conf.Change) {
+		saveConfig(jaz, change)
+	})
+
 	jaz.logf("runOnce: %v", runOnce)
 	opt := jaz.options.Get()
 	jaz.logf("scan interval: %s", opt.ScanInterval)
@@ -208,7 +268,40 @@ func main() {
 		return
 	}
 
+	if restoreDev != "" {
+		if err := cliRestore(jaz, restoreDev, restoreFile, restoreConfirm); err != nil {
+			jaz.logf("main: %v", err)
+		}
+		return
+	}
+
+	if len(runCommands) > 0 {
+		if err := cliRunCommands(jaz, os.Stdout, runCommands, runModel, runID, runHost, runExport); err != nil {
+			jaz.logf("main: %v", err)
+		}
+		return
+	}
+
+	if complianceReport != "" {
+		if err := cliComplianceReport(jaz, complianceReport); err != nil {
+			jaz.logf("main: %v", err)
+		}
+		return
+	}
+
+	if inventoryReport != "" {
+		if err := cliInventoryReport(jaz, inventoryReport); err != nil {
+			jaz.logf("main: %v", err)
+		}
+		return
+	}
+
 	dev.UpdateLastSuccess(jaz.table, jaz.logger, jaz.repositoryPath)
+	dev.LoadFailureStates(jaz.table, jaz.logger, jaz.logPathPrefix)
+
+	checkCompliance(jaz)
+
+	loadFacts(jaz)
 
 	serverName := fmt.Sprintf("%s application", appName)
 
@@ -230,6 +323,13 @@ func main() {
 	jaz.logf("static dir: path=[%s] mapped to dir=[%s]", repoPathFull, jaz.repositoryPath)
 	server.AddStaticDir(repoPath, jaz.repositoryPath)
 
+	// exports might hold device output: serve them only to logged users
+	jaz.sessions = newWebSessions()
+	server.AddSHandler(jaz.sessions)
+	runPathFull := fmt.Sprintf("/%s/%s/", appName, jaz.runWebPath)
+	jaz.logf("logged users dir: path=[%s] mapped to dir=[%s]", runPathFull, jaz.runPath)
+	http.HandleFunc(runPathFull, loggedFileServer(jaz.sessions, runPathFull, jaz.runPath))
+
 	buildPublicWins(jaz, server)
 
 	go dev.Spawner(jaz.table, jaz.logger, jaz.requestChan, jaz.repositoryPath, jaz.logPathPrefix, jaz.options, jaz.filterTable)
@@ -287,18 +387,124 @@ func loadConfig(jaz *app, maxSize int64) {
 
 	jaz.options.Set(&cfg.Options)
 
+	if modelsErr := loadModels(jaz, cfg.Models); modelsErr != nil {
+		jaz.logf("loadConfig: %v", modelsErr)
+		jaz.configModels = cfg.Models // devices using custom models are not loaded, keep them on disk
+		jaz.saveErr = modelsErr
+	}
+
+	if filtersErr := loadFilters(jaz, cfg.Filters); filtersErr != nil {
+		jaz.logf("loadConfig: %v", filtersErr)
+		jaz.configFilters = cfg.Filters // keep them on disk
+	}
+
+	if profErr := jaz.table.SetProfiles(cfg.Profiles); profErr != nil {
+		jaz.logf("loadConfig: %v", profErr)
+		jaz.saveErr = profErr // saving would drop profiles from disk
+	}
+
+	migrated := 0
+	failed := 0
+
 	for _, c := range cfg.Devices {
-		d, newErr := dev.NewDeviceFromConf(jaz.table, jaz.logger, &c)
+		d, m, newErr := dev.NewDeviceFromConf(jaz.table, jaz.logger, &c)
 		if newErr != nil {
 			jaz.logger.Printf("loadConfig: failure creating device '%s': %v", c.ID, newErr)
+			failed++
 			continue
 		}
 		if addErr := jaz.table.SetDevice(d); addErr != nil {
 			jaz.logger.Printf("loadConfig: failure adding device '%s': %v", c.ID, addErr)
+			failed++
 			continue
 		}
+		if m {
+			migrated++
+		}
 		jaz.logger.Printf("loadConfig: loaded device '%s'", c.ID)
 	}
+
+	if migrated > 0 {
+		if failed > 0 {
+			// saving now would discard devices which failed to load
+			jaz.logf("loadConfig: %d devices with attributes migrated to minimal overrides, NOT saving due to %d failed devices", migrated, failed)
+			return
+		}
+		jaz.logf("loadConfig: %d devices with attributes migrated to minimal overrides, saving", migrated)
+		saveConfig(jaz, conf.Change{When: time.Now(), By: "migration"})
+	}
+}
+
+// loadCustom registers user-defined items (models, line filters) from main config plus directory dir.
+// register loads the directory and registers all items, reporting how many came from the directory.
+// It must keep previous items, including those from main config, when registration fails.
+func loadCustom(jaz *app, caller string, lock *sync.Mutex, dir string, configCount int, register func(maxSize int64) (int, error)) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	dirCount, regErr := register(jaz.options.Get().MaxConfigLoadSize)
+	if regErr != nil {
+		return fmt.Errorf("%s: %v", caller, regErr)
+	}
+
+	jaz.logf("%s: config=%d directory=%d (%s)", caller, configCount, dirCount, dir)
+
+	return nil
+}
+
+// reloadCustom reloads user-defined items from last saved config, handing it to load. Missing config means no items from main config.
+func reloadCustom(jaz *app, caller string, load func(cfg *conf.Config) error) error {
+	lastConfig, lastErr := store.FindLastConfig(jaz.configPathPrefix, jaz.logger)
+	if lastErr != nil {
+		return load(&conf.Config{})
+	}
+	cfg, loadErr := conf.Load(lastConfig, jaz.options.Get().MaxConfigLoadSize)
+	if loadErr != nil {
+		return fmt.Errorf("%s: could not load config: '%s': %v", caller, lastConfig, loadErr)
+	}
+	return load(cfg)
+}
+
+// loadModels registers user-defined models from main config plus models directory.
+func loadModels(jaz *app, configModels []conf.ModelConfig) error {
+	return loadCustom(jaz, "loadModels", &jaz.modelsLock, jaz.modelsPath, len(configModels), func(maxSize int64) (int, error) {
+		dirModels, dirErr := conf.LoadModelDir(jaz.modelsPath, maxSize)
+		if dirErr != nil {
+			return 0, dirErr
+		}
+		all := append(append([]conf.ModelConfig{}, configModels...), dirModels...)
+		if regErr := dev.RegisterCustomModels(jaz.logger, jaz.table, all); regErr != nil {
+			return 0, regErr // keep previous models
+		}
+		jaz.configModels = configModels
+		return len(dirModels), nil
+	})
+}
+
+// reloadModels reloads user-defined models from last saved config and models directory.
+func reloadModels(jaz *app) error {
+	return reloadCustom(jaz, "reloadModels", func(cfg *conf.Config) error { return loadModels(jaz, cfg.Models) })
+}
+
+// loadFilters registers user-defined line filters from main config plus filters directory.
+func loadFilters(jaz *app, configFilters []conf.FilterConfig) error {
+	return loadCustom(jaz, "loadFilters", &jaz.filtersLock, jaz.filtersPath, len(configFilters), func(maxSize int64) (int, error) {
+		dirFilters, dirErr := conf.LoadFilterDir(jaz.filtersPath, maxSize)
+		if dirErr != nil {
+			return 0, dirErr
+		}
+		all := append(append([]conf.FilterConfig{}, configFilters...), dirFilters...)
+		if regErr := jaz.filterTable.ReplaceCustomFilters(jaz.logger, all); regErr != nil {
+			return 0, regErr // keep previous filters
+		}
+		jaz.configFilters = configFilters
+		return len(dirFilters), nil
+	})
+}
+
+// reloadFilters reloads user-defined line filters from last saved config and filters directory.
+func reloadFilters(jaz *app) error {
+	return reloadCustom(jaz, "reloadFilters", func(cfg *conf.Config) error { return loadFilters(jaz, cfg.Filters) })
 }
 
 func manageDeviceList(jaz *app, imp, del, purge, list bool) error {
@@ -535,6 +741,14 @@ func exclusiveUnlock(jaz *app) {
 
 func saveConfig(jaz *app, change conf.Change) {
 
+	jaz.saveLock.Lock()
+	defer jaz.saveLock.Unlock()
+
+	if jaz.saveErr != nil {
+		jaz.logger.Printf("main: NOT saving config, fix it and restart: config loaded with error: %v", jaz.saveErr)
+		return
+	}
+
 	devices := jaz.table.ListDevices()
 
 	var cfg conf.Config
@@ -542,6 +756,16 @@ func saveConfig(jaz *app, change conf.Change) {
 	cfg.Options.LastChange = change  // record change
 	jaz.options.Set(&cfg.Options)    // update
 
+	jaz.modelsLock.Lock()
+	cfg.Models = jaz.configModels
+	jaz.modelsLock.Unlock()
+
+	jaz.filtersLock.Lock()
+	cfg.Filters = jaz.configFilters
+	jaz.filtersLock.Unlock()
+
+	cfg.Profiles = jaz.table.ListProfiles()
+
 	// copy devices from device table
 	cfg.Devices = make([]conf.DevConfig, len(devices))
 	for i, d := range devices {
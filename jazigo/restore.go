@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/dev"
+)
+
+func restoreDevice(jaz *app, devID, file string, dryRun bool, confirm string, change conf.Change) (*dev.RestoreReport, error) {
+	jaz.logf("restore: device=%s file=%s dryRun=%v by=%s from=%s", devID, file, dryRun, change.By, change.From)
+	return dev.Restore(jaz.table, jaz.logger, devID, file, dryRun, confirm, change, jaz.repositoryPath, jaz.restoreStagePath, jaz.logPathPrefix, jaz.options.Get(), jaz.filterTable)
+}
+
+// formatRestoreReport renders restore results as text for the user.
+func formatRestoreReport(report *dev.RestoreReport, err error) string {
+	var buf bytes.Buffer
+
+	if err != nil {
+		fmt.Fprintf(&buf, "error: %v\n", err)
+	} else {
+		buf.WriteString("result: success\n")
+	}
+
+	if report == nil {
+		return buf.String()
+	}
+
+	if report.Audit != "" {
+		fmt.Fprintf(&buf, "audit record: %s\n", report.Audit)
+	}
+
+	if report.DryRun {
+		buf.WriteString("\ncommands to be sent to device:\n")
+	} else {
+		buf.WriteString("\ncommands sent to device:\n")
+	}
+	for _, c := range report.Plan {
+		fmt.Fprintf(&buf, "%s\n", c)
+	}
+
+	if report.DryRun {
+		fmt.Fprintf(&buf, "\ndiff (-saved +live): %d lines\n", len(report.Diff))
+		for _, l := range report.Diff {
+			fmt.Fprintf(&buf, "%s\n", l)
+		}
+	} else {
+		buf.WriteString("\ntranscript:\n")
+		buf.Write(report.Transcript)
+	}
+
+	return buf.String()
+}
+
+// cliRestore runs a restore from command line. Without confirmation, it is a dry run.
+func cliRestore(jaz *app, devID, file, confirm string) error {
+	dryRun := confirm == ""
+	change := conf.Change{When: time.Now(), By: os.Getenv("USER"), From: "cli"}
+	report, err := restoreDevice(jaz, devID, file, dryRun, confirm, change)
+	fmt.Print(formatRestoreReport(report, err))
+	return err
+}
@@ -1,7 +1,17 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/icza/gowut/gwu"
+
+	"github.com/udhos/jazigo/conf"
+	"github.com/udhos/jazigo/dev"
 )
 
 func TestSplitBufLines(t *testing.T) {
@@ -26,3 +36,143 @@ func split(t *testing.T, input string, wantLineCount int) {
 		t.Errorf("splitBufLines: input=%v expected=%d got=%d", input, wantLineCount, count)
 	}
 }
+
+func TestHideVolatileLines(t *testing.T) {
+	patterns, err := dev.CompileIgnorePatterns([]string{`uptime`})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	lines, nums := hideVolatileLines([]string{"a", "uptime 1", "b"}, patterns)
+	if len(lines) != 2 || lines[1] != "b" || nums[1] != 3 {
+		t.Errorf("hideVolatileLines: lines=%v nums=%v", lines, nums)
+	}
+}
+
+func TestPoolsString(t *testing.T) {
+	s := poolsString([]dev.PoolStatus{{Name: "bastion", Busy: 2, Limit: 5}, {Name: "10.0.0.1", Busy: 1}})
+	if want := "bastion=2/5 10.0.0.1=1/unlimited"; s != want {
+		t.Errorf("poolsString: wanted=[%s] got=[%s]", want, s)
+	}
+}
+
+func TestLoadModelsKeepsPrevious(t *testing.T) {
+	jaz := newApp()
+	jaz.modelsPath = t.TempDir()
+
+	good := []conf.ModelConfig{{Name: "acme-os", Attr: conf.NewDevAttr()}}
+	if err := loadModels(jaz, good); err != nil {
+		t.Fatalf("load good models: %v", err)
+	}
+
+	bad := []conf.ModelConfig{{Name: "acme-os", Attr: conf.NewDevAttr()}, {Name: "acme-os", Attr: conf.NewDevAttr()}}
+	if err := loadModels(jaz, bad); err == nil {
+		t.Errorf("duplicate models: expected error")
+	}
+
+	if len(jaz.configModels) != 1 {
+		t.Errorf("bad models replaced config models: %v", jaz.configModels)
+	}
+	if _, err := jaz.table.GetModel("acme-os"); err != nil {
+		t.Errorf("previous model dropped: %v", err)
+	}
+}
+
+func TestLoadFiltersKeepsPrevious(t *testing.T) {
+	jaz := newApp()
+	jaz.filterTable = dev.NewFilterTable(jaz.logger)
+	jaz.filtersPath = t.TempDir()
+
+	good := []conf.FilterConfig{{Name: "no-banner", Rules: []conf.FilterRule{{Action: "drop", Pattern: `^banner `}}}}
+	if err := loadFilters(jaz, good); err != nil {
+		t.Fatalf("load good filters: %v", err)
+	}
+
+	bad := []conf.FilterConfig{{Name: "no-banner", Rules: []conf.FilterRule{{Action: "drop", Pattern: `(`}}}}
+	if err := loadFilters(jaz, bad); err == nil {
+		t.Errorf("bad pattern: expected error")
+	}
+
+	if len(jaz.configFilters) != 1 || jaz.configFilters[0].Rules[0].Pattern != `^banner ` {
+		t.Errorf("bad filters replaced config filters: %v", jaz.configFilters)
+	}
+	if list := jaz.filterTable.ListCustomFilters(); len(list) != 1 || list[0] != "no-banner" {
+		t.Errorf("previous filter dropped: %v", list)
+	}
+}
+
+// fakeSession is a gowut session with only ID, Private and Attr.
+type fakeSession struct {
+	gwu.Session
+	id   string
+	user string
+}
+
+func (s *fakeSession) ID() string                   { return s.id }
+func (s *fakeSession) Private() bool                { return true }
+func (s *fakeSession) Attr(name string) interface{} { return s.user }
+
+func TestLoggedFileServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "job1.json"), []byte("show run"), 0640); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	ws := newWebSessions()
+	ws.Created(&fakeSession{id: "guest"})
+	ws.Created(&fakeSession{id: "admin", user: "admin"})
+	handler := loggedFileServer(ws, "/jazigo/runs/", dir)
+
+	get := func(sessid string) int {
+		r := httptest.NewRequest("GET", "/jazigo/runs/job1.json", nil)
+		if sessid != "" {
+			r.AddCookie(&http.Cookie{Name: gwuSessidCookie, Value: sessid})
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := get(""); code != http.StatusForbidden {
+		t.Errorf("no session: code=%d", code)
+	}
+	if code := get("guest"); code != http.StatusForbidden {
+		t.Errorf("guest session: code=%d", code)
+	}
+	if code := get("admin"); code != http.StatusOK {
+		t.Errorf("logged session: code=%d", code)
+	}
+
+	ws.Removed(&fakeSession{id: "admin"})
+	if code := get("admin"); code != http.StatusForbidden {
+		t.Errorf("removed session: code=%d", code)
+	}
+}
+
+func TestRedactProfiles(t *testing.T) {
+	profiles := []conf.Profile{
+		{Name: "lab", LoginUser: "admin", LoginPassword: "secret1", EnablePassword: "secret2", SSHKeyPassphrase: "secret3"},
+		{Name: "empty", LoginUser: "guest"},
+	}
+
+	list := conf.ProfileList{Profiles: redactProfiles(profiles)}
+	b, dumpErr := list.Dump()
+	if dumpErr != nil {
+		t.Fatalf("dump: %v", dumpErr)
+	}
+	dump := string(b)
+
+	for _, secret := range []string{"secret1", "secret2", "secret3"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("redacted dump exposes %s: %s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, "admin") {
+		t.Errorf("redacted dump lost login user: %s", dump)
+	}
+	if p := list.Profiles[1]; p.LoginPassword != "" || p.EnablePassword != "" || p.SSHKeyPassphrase != "" {
+		t.Errorf("empty secrets should stay empty: %v", p)
+	}
+	if profiles[0].LoginPassword != "secret1" {
+		t.Errorf("redaction modified original profile: %v", profiles[0])
+	}
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/icza/gowut/gwu"
@@ -138,6 +141,20 @@ func splitBufLines(b []byte) []string {
 	return list
 }
 
+// hideVolatileLines removes lines ignored for comparison, returning remaining lines with their original line numbers.
+func hideVolatileLines(lines []string, patterns []*regexp.Regexp) ([]string, []int) {
+	var kept []string
+	var nums []int
+	for i, line := range lines {
+		if dev.IgnoredLine(patterns, []byte(line)) {
+			continue
+		}
+		kept = append(kept, line)
+		nums = append(nums, i+1)
+	}
+	return kept, nums
+}
+
 func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 	winName := deviceWinName(devID)
 	s := e.Session()
@@ -169,23 +186,62 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 	propText := gwu.NewTextBox("Text Box")
 	propText.SetRows(40)
 	propText.SetCols(100)
+	propEffective := gwu.NewTextBox("")
+	propEffective.SetRows(40)
+	propEffective.SetCols(100)
+	propEffective.SetReadOnly(true)
 	propPanel.Add(propButtonReset)
 	propPanel.Add(propButtonSave)
 	propPanel.Add(propMsg)
 	propPanel.Add(propText)
+	propPanel.Add(gwu.NewLabel("Effective attributes (read-only):"))
+	propPanel.Add(propEffective)
 
 	showPanel := gwu.NewPanel()
 	logPanel := gwu.NewPanel()
 	diffPanel := gwu.NewPanel()
-
-	panel.Add(gwu.NewLabel("Files"), filesPanel)      // tab 0
-	panel.Add(gwu.NewLabel("View Config"), showPanel) // tab 1
-	panel.Add(gwu.NewLabel("Properties"), propPanel)  // tab 2
-	panel.Add(gwu.NewLabel("Error Log"), logPanel)    // tab 3
-	panel.Add(gwu.NewLabel("Diff"), diffPanel)        // tab 4
-
-	const tabShow = 1 // index
-	const tabDiff = 4 // index
+	diffHide := gwu.NewCheckBox("Hide volatile lines")
+	var diffFrom, diffTo string // files currently compared
+
+	restorePanel := gwu.NewPanel()
+	restoreFile := "" // file selected for restore
+	restoreFileLabel := gwu.NewLabel("File: (choose one under Files tab)")
+	restoreButtonDry := gwu.NewButton("Dry run")
+	restoreConfirm := gwu.NewTextBox("")
+	restoreButtonRun := gwu.NewButton("Restore")
+	restoreMsg := gwu.NewLabel("")
+	restoreOut := gwu.NewTextBox("")
+	restoreOut.SetRows(40)
+	restoreOut.SetCols(100)
+	restoreOut.SetReadOnly(true)
+	restorePanel.Add(restoreFileLabel)
+	restorePanel.Add(restoreButtonDry)
+	restorePanel.Add(gwu.NewLabel("Type device id to confirm actual restore:"))
+	restorePanel.Add(restoreConfirm)
+	restorePanel.Add(restoreButtonRun)
+	restorePanel.Add(restoreMsg)
+	restorePanel.Add(restoreOut)
+
+	compliancePanel := gwu.NewPanel()
+	complianceButtonCheck := gwu.NewButton("Check now")
+	complianceMsg := gwu.NewLabel("")
+	complianceTab := gwu.NewTable()
+	complianceTab.Style().AddClass("device_files_table")
+	compliancePanel.Add(complianceButtonCheck)
+	compliancePanel.Add(complianceMsg)
+	compliancePanel.Add(complianceTab)
+
+	panel.Add(gwu.NewLabel("Files"), filesPanel)           // tab 0
+	panel.Add(gwu.NewLabel("View Config"), showPanel)      // tab 1
+	panel.Add(gwu.NewLabel("Properties"), propPanel)       // tab 2
+	panel.Add(gwu.NewLabel("Error Log"), logPanel)         // tab 3
+	panel.Add(gwu.NewLabel("Diff"), diffPanel)             // tab 4
+	panel.Add(gwu.NewLabel("Restore"), restorePanel)       // tab 5
+	panel.Add(gwu.NewLabel("Compliance"), compliancePanel) // tab 6
+
+	const tabShow = 1    // index
+	const tabDiff = 4    // index
+	const tabRestore = 5 // index
 
 	devPrefix := dev.DeviceFullPrefix(jaz.repositoryPath, devID)
 	showFile, lastErr := store.FindLastConfig(devPrefix, jaz.logger)
@@ -205,7 +261,7 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 		if getErr != nil {
 			logPanel.Add(gwu.NewLabel(fmt.Sprintf("Get device error: %v", getErr)))
 		} else {
-			maxSize = 1000 * int64(d.Attr.ErrlogHistSize) // max 1000 bytes per line
+			maxSize = 1000 * int64(d.Attributes().ErrlogHistSize) // max 1000 bytes per line
 		}
 
 		b, readErr := store.FileRead(logPath, maxSize)
@@ -249,7 +305,10 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 
 		jaz.logger.Printf("diff: from=%s to=%s", from, to)
 
+		diffFrom, diffTo = from, to
+
 		diffPanel.Clear()
+		diffPanel.Add(diffHide)
 		diffPanel.Add(gwu.NewLabel("From: " + from))
 		diffPanel.Add(gwu.NewLabel("To: " + to))
 
@@ -267,6 +326,33 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 
 		seqFrom := splitBufLines(bufFrom)
 		seqTo := splitBufLines(bufTo)
+
+		// line numbers shown for each line
+		numFrom := make([]int, len(seqFrom))
+		for i := range numFrom {
+			numFrom[i] = i + 1
+		}
+		numTo := make([]int, len(seqTo))
+		for i := range numTo {
+			numTo[i] = i + 1
+		}
+
+		if diffHide.State() {
+			var patterns []*regexp.Regexp
+			d, getErr := jaz.table.GetDevice(devID)
+			if getErr != nil {
+				diffPanel.Add(gwu.NewLabel(fmt.Sprintf("Get device error: %v", getErr)))
+			} else {
+				var compErr error
+				patterns, compErr = dev.CompileIgnorePatterns(d.Attributes().IgnoreForComparison)
+				if compErr != nil {
+					diffPanel.Add(gwu.NewLabel(fmt.Sprintf("Volatile lines: %v", compErr)))
+				}
+			}
+			seqFrom, numFrom = hideVolatileLines(seqFrom, patterns)
+			seqTo, numTo = hideVolatileLines(seqTo, patterns)
+		}
+
 		diff := difflib.Diff(seqFrom, seqTo)
 
 		diffBox := gwu.NewTable()
@@ -283,7 +369,7 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 
 			switch d.Delta {
 			case difflib.LeftOnly:
-				diffBox.Add(gwu.NewLabel(strconv.Itoa(f+1)), f, colLineNumFrom)
+				diffBox.Add(gwu.NewLabel(strconv.Itoa(numFrom[f])), f, colLineNumFrom)
 				diffBox.CellFmt(f, colLineNumFrom).Style().AddClass("diffbox_linenum")
 				lab := gwu.NewLabel(d.Payload)
 				diffBox.Add(lab, f, colLineTextFrom)
@@ -291,7 +377,7 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 				diffBox.CellFmt(f, colLineTextFrom).Style().AddClass("diffbox_text_cell")
 				f++
 			case difflib.RightOnly:
-				diffBox.Add(gwu.NewLabel(strconv.Itoa(t+1)), t, colLineNumTo)
+				diffBox.Add(gwu.NewLabel(strconv.Itoa(numTo[t])), t, colLineNumTo)
 				diffBox.CellFmt(t, colLineNumTo).Style().AddClass("diffbox_linenum")
 				lab := gwu.NewLabel(d.Payload)
 				diffBox.Add(lab, t, colLineTextTo)
@@ -299,9 +385,9 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 				diffBox.CellFmt(t, colLineTextTo).Style().AddClass("diffbox_text_cell")
 				t++
 			case difflib.Common:
-				diffBox.Add(gwu.NewLabel(strconv.Itoa(f+1)), f, colLineNumFrom)
+				diffBox.Add(gwu.NewLabel(strconv.Itoa(numFrom[f])), f, colLineNumFrom)
 				diffBox.CellFmt(f, colLineNumFrom).Style().AddClass("diffbox_linenum")
-				diffBox.Add(gwu.NewLabel(strconv.Itoa(t+1)), t, colLineNumTo)
+				diffBox.Add(gwu.NewLabel(strconv.Itoa(numTo[t])), t, colLineNumTo)
 				diffBox.CellFmt(t, colLineNumTo).Style().AddClass("diffbox_linenum")
 				labF := gwu.NewLabel(d.Payload)
 				labT := gwu.NewLabel(d.Payload)
@@ -318,6 +404,10 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 		e.MarkDirty(panel)
 	}
 
+	diffHide.AddEHandlerFunc(func(e gwu.Event) {
+		loadDiff(e, diffFrom, diffTo)
+	}, gwu.ETypeClick)
+
 	{
 		// Preload diff panel
 		prefix := dev.DeviceFullPrefix(jaz.repositoryPath, devID)
@@ -353,7 +443,7 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 
 		filesTab.Clear()
 
-		const COLS = 6
+		const COLS = 7
 
 		row := 0
 
@@ -364,6 +454,7 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 		filesTab.Add(gwu.NewLabel("Time"), row, 3)
 		filesTab.Add(gwu.NewLabel("Diff From"), row, 4)
 		filesTab.Add(gwu.NewLabel("Compare"), row, 5)
+		filesTab.Add(gwu.NewLabel("Restore"), row, 6)
 
 		row++
 
@@ -425,6 +516,19 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 			filesTab.Add(listDiffSrc, row, 4)
 			filesTab.Add(buttonDiff, row, 5)
 
+			buttonRestore := gwu.NewButton("Restore...")
+			restoreName := m
+			buttonRestore.AddEHandlerFunc(func(e gwu.Event) {
+				restoreFile = restoreName
+				restoreFileLabel.SetText("File: " + restoreName)
+				restoreConfirm.SetText("")
+				restoreMsg.SetText("Run a dry run first to compare the saved file against the live config.")
+				restoreOut.SetText("")
+				panel.SetSelected(tabRestore)
+				e.MarkDirty(panel)
+			}, gwu.ETypeClick)
+			filesTab.Add(buttonRestore, row, 6)
+
 			row++
 		}
 
@@ -454,14 +558,114 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 
 		propText.SetText(string(b))
 
+		eff, effErr := conf.DumpAttrWithOrigin(d.Attributes(), d.AttributesOrigin())
+		if effErr != nil {
+			propEffective.SetText(fmt.Sprintf("Effective attributes error: %v", effErr))
+		} else {
+			propEffective.SetText(string(eff))
+		}
+
 		e.MarkDirty(propPanel)
 	}
 
+	runRestore := func(e gwu.Event, dryRun bool) {
+		defer e.MarkDirty(restorePanel)
+
+		if !userIsLogged(e.Session()) {
+			restoreMsg.SetText("Login required.")
+			return
+		}
+
+		if restoreFile == "" {
+			restoreMsg.SetText("No file selected.")
+			return
+		}
+
+		change := conf.Change{From: eventRemoteAddress(e), By: sessionUsername(e.Session()), When: time.Now()}
+		report, err := restoreDevice(jaz, devID, restoreFile, dryRun, restoreConfirm.Text(), change)
+		if err != nil {
+			restoreMsg.SetText(fmt.Sprintf("Restore failed: %v", err))
+		} else if dryRun {
+			restoreMsg.SetText(fmt.Sprintf("Dry run done: %d lines differ.", len(report.Diff)))
+		} else {
+			restoreMsg.SetText("Restore done.")
+		}
+		restoreOut.SetText(formatRestoreReport(report, err))
+		restoreConfirm.SetText("")
+	}
+
+	restoreButtonDry.AddEHandlerFunc(func(e gwu.Event) { runRestore(e, true) }, gwu.ETypeClick)
+	restoreButtonRun.AddEHandlerFunc(func(e gwu.Event) { runRestore(e, false) }, gwu.ETypeClick)
+
+	showCompliance := func(report *dev.ComplianceReport) {
+		complianceTab.Clear()
+
+		if report == nil {
+			complianceMsg.SetText("No compliance policy applies to this device.")
+			return
+		}
+
+		status := "COMPLIANT"
+		if !report.Pass {
+			status = fmt.Sprintf("NON-COMPLIANT: %d of %d rules failed", report.Failed(), len(report.Results))
+		}
+		complianceMsg.SetText(fmt.Sprintf("%s - file: %s checked: %s", status, report.File, timestampString(report.When)))
+
+		const COLS = 4
+
+		complianceTab.Add(gwu.NewLabel("Policy"), 0, 0)
+		complianceTab.Add(gwu.NewLabel("Rule"), 0, 1)
+		complianceTab.Add(gwu.NewLabel("Result"), 0, 2)
+		complianceTab.Add(gwu.NewLabel("Details"), 0, 3)
+
+		for i, r := range report.Results {
+			row := i + 1
+			result := "pass"
+			if !r.Pass {
+				result = "FAIL"
+			}
+			complianceTab.Add(gwu.NewLabel(r.Policy), row, 0)
+			complianceTab.Add(gwu.NewLabel(r.Rule), row, 1)
+			complianceTab.Add(gwu.NewLabel(result), row, 2)
+			complianceTab.Add(gwu.NewLabel(r.Msg), row, 3)
+		}
+
+		for r := 0; r <= len(report.Results); r++ {
+			for j := 0; j < COLS; j++ {
+				complianceTab.CellFmt(r, j).Style().AddClass("device_files_cell")
+			}
+		}
+	}
+
+	loadCompliance := func(e gwu.Event) {
+		report, getErr := jaz.table.GetCompliance(devID)
+		if getErr != nil {
+			report = nil // not checked yet, or no policy applies
+		}
+		showCompliance(report)
+		e.MarkDirty(compliancePanel)
+	}
+
+	complianceButtonCheck.AddEHandlerFunc(func(e gwu.Event) {
+		defer e.MarkDirty(compliancePanel)
+
+		report, checkErr := jaz.table.CheckCompliance(devID, jaz.repositoryPath, jaz.options.Get(), jaz.logger)
+		if checkErr != nil {
+			complianceTab.Clear()
+			complianceMsg.SetText(fmt.Sprintf("Check error: %v", checkErr))
+			return
+		}
+		showCompliance(report)
+	}, gwu.ETypeClick)
+
 	refresh := func(e gwu.Event) {
 		propButtonSave.SetEnabled(userIsLogged(e.Session()))
+		restoreButtonDry.SetEnabled(userIsLogged(e.Session()))
+		restoreButtonRun.SetEnabled(userIsLogged(e.Session()))
 		fileList(e)  // build file list
 		resetProp(e) // build file properties
 		loadLog(e)   // load log
+		loadCompliance(e)
 		e.MarkDirty(win)
 	}
 
@@ -495,7 +699,10 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 		c.LastChange.By = sessionUsername(e.Session())
 		c.LastChange.When = time.Now()
 
-		d.DevConfig = *c
+		if setErr := d.SetDevConfig(jaz.table, c); setErr != nil {
+			propMsg.SetText(fmt.Sprintf("Invalid device: %v", setErr))
+			return
+		}
 
 		updateErr := jaz.table.UpdateDevice(d)
 		if updateErr != nil {
@@ -521,21 +728,24 @@ func buildDeviceWindow(jaz *app, e gwu.Event, devID string) string {
 }
 
 func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
-	const COLS = 10
+	const COLS = 18
 
 	row := 0 // filter
 	filterModel := gwu.NewTextBox(jaz.filterModel)
 	filterID := gwu.NewTextBox(jaz.filterID)
 	filterHost := gwu.NewTextBox(jaz.filterHost)
+	filterProfile := gwu.NewTextBox(jaz.filterProfile)
 
 	inputCols := 10
 	filterModel.SetCols(inputCols)
 	filterID.SetCols(inputCols)
 	filterHost.SetCols(inputCols)
+	filterProfile.SetCols(inputCols)
 
 	filterModel.AddSyncOnETypes(gwu.ETypeKeyUp) // synchronize values during editing (while you type in characters)
 	filterID.AddSyncOnETypes(gwu.ETypeKeyUp)    // synchronize values during editing (while you type in characters)
 	filterHost.AddSyncOnETypes(gwu.ETypeKeyUp)  // synchronize values during editing (while you type in characters)
+	filterProfile.AddSyncOnETypes(gwu.ETypeKeyUp)
 
 	filterModel.AddEHandlerFunc(func(e gwu.Event) {
 		jaz.filterModel = filterModel.Text()
@@ -552,16 +762,29 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 		refreshDeviceTable(jaz, t, tabSumm, e)
 	}, gwu.ETypeChange)
 
+	filterProfile.AddEHandlerFunc(func(e gwu.Event) {
+		jaz.filterProfile = filterProfile.Text()
+		refreshDeviceTable(jaz, t, tabSumm, e)
+	}, gwu.ETypeChange)
+
 	t.Add(filterModel, row, 0)
 	t.Add(filterID, row, 1)
 	t.Add(filterHost, row, 2)
-	t.Add(gwu.NewLabel(""), row, 3)
+	t.Add(filterProfile, row, 3)
 	t.Add(gwu.NewLabel(""), row, 4)
 	t.Add(gwu.NewLabel(""), row, 5)
 	t.Add(gwu.NewLabel(""), row, 6)
 	t.Add(gwu.NewLabel(""), row, 7)
 	t.Add(gwu.NewLabel(""), row, 8)
 	t.Add(gwu.NewLabel(""), row, 9)
+	t.Add(gwu.NewLabel(""), row, 10)
+	t.Add(gwu.NewLabel(""), row, 11)
+	t.Add(gwu.NewLabel(""), row, 12)
+	t.Add(gwu.NewLabel(""), row, 13)
+	t.Add(gwu.NewLabel(""), row, 14)
+	t.Add(gwu.NewLabel(""), row, 15)
+	t.Add(gwu.NewLabel(""), row, 16)
+	t.Add(gwu.NewLabel(""), row, 17)
 
 	hostPort := gwu.NewLabel("Host:Port")
 	hostPort.SetAttr("title", "Part ':Port' is optional")
@@ -570,13 +793,21 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 	t.Add(gwu.NewLabel("Model"), row, 0)
 	t.Add(gwu.NewLabel("Device"), row, 1)
 	t.Add(hostPort, row, 2)
-	t.Add(gwu.NewLabel("Transport"), row, 3)
-	t.Add(gwu.NewLabel("Last Status"), row, 4)
-	t.Add(gwu.NewLabel("Elapsed"), row, 5)
-	t.Add(gwu.NewLabel("Last Try"), row, 6)
-	t.Add(gwu.NewLabel("Last Success"), row, 7)
-	t.Add(gwu.NewLabel("Holdtime"), row, 8)
-	t.Add(gwu.NewLabel("Run Now"), row, 9)
+	t.Add(gwu.NewLabel("Profiles"), row, 3)
+	t.Add(gwu.NewLabel("Transport"), row, 4)
+	t.Add(gwu.NewLabel("Last Status"), row, 5)
+	t.Add(gwu.NewLabel("Elapsed"), row, 6)
+	t.Add(gwu.NewLabel("Last Try"), row, 7)
+	t.Add(gwu.NewLabel("Last Success"), row, 8)
+	t.Add(gwu.NewLabel("Holdtime"), row, 9)
+	t.Add(gwu.NewLabel("Next Run"), row, 10)
+	t.Add(gwu.NewLabel("Failures"), row, 11)
+	t.Add(gwu.NewLabel("Hostname"), row, 12)
+	t.Add(gwu.NewLabel("Platform"), row, 13)
+	t.Add(gwu.NewLabel("Version"), row, 14)
+	t.Add(gwu.NewLabel("Serial"), row, 15)
+	t.Add(gwu.NewLabel("Uptime"), row, 16)
+	t.Add(gwu.NewLabel("Run Now"), row, 17)
 
 	devList := jaz.table.ListDevices()
 	sort.Sort(sortByID{data: devList})
@@ -597,6 +828,10 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 		if !strings.Contains(d.HostPort, filterHost.Text()) {
 			continue
 		}
+		profiles := strings.Join(d.Profiles, ",")
+		if !strings.Contains(profiles, filterProfile.Text()) {
+			continue
+		}
 
 		labMod := gwu.NewLabel(d.Model())
 
@@ -611,6 +846,7 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 		}, gwu.ETypeClick)
 
 		labHost := gwu.NewLabel(d.HostPort)
+		labProfiles := gwu.NewLabel(profiles)
 		labTransport := gwu.NewLabel(d.Transports)
 		var imageLastStatus gwu.Image
 		if d.LastStatus() {
@@ -626,6 +862,27 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 			h = 0
 		}
 		labHoldtime := gwu.NewLabel(durationSecString(h))
+		labNextRun := gwu.NewLabel(nextRunString(d, now, options))
+
+		facts, factsErr := jaz.table.GetFacts(d.ID)
+		if factsErr != nil {
+			facts = &dev.Facts{} // no facts
+		}
+
+		var failures gwu.Comp
+		if d.Quarantined() {
+			buttonRelease := gwu.NewButton(fmt.Sprintf("Release (%d)", d.Failures()))
+			buttonRelease.SetAttr("title", "Quarantined device: click to bring it back into scans")
+			buttonRelease.AddEHandlerFunc(func(e gwu.Event) {
+				if _, relErr := dev.ReleaseDevice(jaz.table, devID, jaz.logger, jaz.logPathPrefix); relErr != nil {
+					jaz.logger.Printf("release device %s error: %v", devID, relErr)
+				}
+				refreshDeviceTable(jaz, t, tabSumm, e)
+			}, gwu.ETypeClick)
+			failures = buttonRelease
+		} else {
+			failures = gwu.NewLabel(strconv.Itoa(d.Failures()))
+		}
 
 		buttonRun := gwu.NewButton("Run")
 		id := d.ID
@@ -637,13 +894,21 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 		t.Add(labMod, row, 0)
 		t.Add(buttonID, row, 1)
 		t.Add(labHost, row, 2)
-		t.Add(labTransport, row, 3)
-		t.Add(imageLastStatus, row, 4)
-		t.Add(labElapsed, row, 5)
-		t.Add(labLastTry, row, 6)
-		t.Add(labLastSuccess, row, 7)
-		t.Add(labHoldtime, row, 8)
-		t.Add(buttonRun, row, 9)
+		t.Add(labProfiles, row, 3)
+		t.Add(labTransport, row, 4)
+		t.Add(imageLastStatus, row, 5)
+		t.Add(labElapsed, row, 6)
+		t.Add(labLastTry, row, 7)
+		t.Add(labLastSuccess, row, 8)
+		t.Add(labHoldtime, row, 9)
+		t.Add(labNextRun, row, 10)
+		t.Add(failures, row, 11)
+		t.Add(gwu.NewLabel(facts.Hostname), row, 12)
+		t.Add(gwu.NewLabel(facts.Platform), row, 13)
+		t.Add(gwu.NewLabel(facts.Version), row, 14)
+		t.Add(gwu.NewLabel(facts.Serial), row, 15)
+		t.Add(gwu.NewLabel(facts.Uptime), row, 16)
+		t.Add(buttonRun, row, 17)
 
 		row++
 	}
@@ -656,6 +921,22 @@ func buildDeviceTable(jaz *app, s gwu.Session, t gwu.Table, tabSumm gwu.Panel) {
 
 	tabSumm.Clear()
 	tabSumm.Add(gwu.NewLabel(fmt.Sprintf("Filter: %d selected from %d total devices", row-2, len(devList))))
+	if pools := jaz.table.ListPools(options); len(pools) > 0 {
+		tabSumm.Add(gwu.NewLabel("Concurrency pools: " + poolsString(pools)))
+	}
+}
+
+// poolsString shows occupancy for concurrency pools: console1=2/5
+func poolsString(pools []dev.PoolStatus) string {
+	list := make([]string, 0, len(pools))
+	for _, p := range pools {
+		limit := "unlimited"
+		if p.Limit > 0 {
+			limit = strconv.Itoa(p.Limit)
+		}
+		list = append(list, fmt.Sprintf("%s=%d/%s", p.Name, p.Busy, limit))
+	}
+	return strings.Join(list, " ")
 }
 
 func runPriority(jaz *app, id string) {
@@ -707,6 +988,8 @@ func buildHomeWin(jaz *app, s gwu.Session) {
 	refreshButton.AddEHandlerFunc(refresh, gwu.ETypeClick)
 	win.Add(refreshButton)
 
+	win.Add(gwu.NewLink("Run Command", "run"))
+
 	win.AddEHandlerFunc(refresh, gwu.ETypeWinLoad)
 
 	createDevExpander := gwu.NewExpander()
@@ -862,6 +1145,21 @@ func timestampString(ts time.Time) string {
 	return ts.Format("2006-01-02 15:04:05")
 }
 
+// nextRunString shows when the device is due for backup.
+func nextRunString(d *dev.Device, now time.Time, opt *conf.AppConfig) string {
+	if d.Quarantined() {
+		return "quarantined"
+	}
+	next, err := d.NextRun(now, opt)
+	if err != nil {
+		return fmt.Sprintf("bad schedule: %v", err)
+	}
+	if !next.After(now) {
+		return "due"
+	}
+	return next.Format("2006-01-02 15:04:05 MST")
+}
+
 func durationSecString(d time.Duration) string {
 	return fmt.Sprintf("%.3fs", d.Seconds())
 }
@@ -994,6 +1292,7 @@ func buildPublicWins(jaz *app, s gwu.Session) {
 
 	buildLoginWin(jaz, s)
 	buildAdminWin(jaz, s)
+	buildRunWin(jaz, s)
 	buildHomeWin(jaz, s)
 }
 
@@ -1005,6 +1304,7 @@ func buildPrivateWins(jaz *app, s gwu.Session) {
 
 	buildLogoutWin(jaz, s)
 	buildAdminWin(jaz, s) // this is needed for access to admin win within PRIVATE session
+	buildRunWin(jaz, s)   // this is needed for access to run win within PRIVATE session
 	buildHomeWin(jaz, s)  // this is needed for access to home win within PRIVATE session
 }
 
@@ -1035,6 +1335,57 @@ func userIsLogged(s gwu.Session) bool {
 	return sessionUsername(s) != ""
 }
 
+// gwuSessidCookie is the cookie holding the gowut session ID.
+const gwuSessidCookie = "gwu-sessid"
+
+// webSessions tracks gowut sessions, so that plain HTTP handlers can check for logged users.
+type webSessions struct {
+	lock     sync.Mutex
+	sessions map[string]gwu.Session
+}
+
+func newWebSessions() *webSessions {
+	return &webSessions{sessions: map[string]gwu.Session{}}
+}
+
+// Created implements gwu.SessionHandler.
+func (ws *webSessions) Created(s gwu.Session) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	ws.sessions[s.ID()] = s
+}
+
+// Removed implements gwu.SessionHandler.
+func (ws *webSessions) Removed(s gwu.Session) {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	delete(ws.sessions, s.ID())
+}
+
+// logged informs whether the request comes from a logged user.
+func (ws *webSessions) logged(r *http.Request) bool {
+	c, cookieErr := r.Cookie(gwuSessidCookie)
+	if cookieErr != nil {
+		return false
+	}
+	ws.lock.Lock()
+	s, found := ws.sessions[c.Value]
+	ws.lock.Unlock()
+	return found && userIsLogged(s)
+}
+
+// loggedFileServer serves files under dir at path, only to logged users.
+func loggedFileServer(ws *webSessions, path, dir string) http.HandlerFunc {
+	handler := http.StripPrefix(path, http.FileServer(http.Dir(dir)))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ws.logged(r) {
+			http.Error(w, "login required", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}
+}
+
 func buildLogoutWin(jaz *app, s gwu.Session) {
 	winName := fmt.Sprintf("%s logout", appName)
 
@@ -1145,6 +1496,10 @@ func buildAdminWin(jaz *app, s gwu.Session) {
 			settingsMsg.SetText("Nil parsing error")
 			return
 		}
+		if schedErr := dev.ValidateSchedule(opt.Schedule, opt.ScheduleTimezone); schedErr != nil {
+			settingsMsg.SetText(fmt.Sprintf("Invalid schedule: %v", schedErr))
+			return
+		}
 
 		// overwrite change record
 		opt.LastChange.From = eventRemoteAddress(e)
@@ -1163,9 +1518,517 @@ func buildAdminWin(jaz *app, s gwu.Session) {
 
 	win.Add(settingsPanel)
 
+	win.Add(buildProfilesPanel(jaz, s))
+
+	modelsPanel, modelsRefresh := buildCustomPanel(s, "User-defined Models", jaz.modelsPath, jaz.table.ListCustomModels, func() error { return reloadModels(jaz) })
+	filtersPanel, filtersRefresh := buildCustomPanel(s, "User-defined Line Filters", jaz.filtersPath, jaz.filterTable.ListCustomFilters, func() error { return reloadFilters(jaz) })
+
+	win.Add(modelsPanel)
+
+	win.Add(filtersPanel)
+
+	win.Add(buildCompliancePanel(jaz, s))
+
+	win.Add(buildInventoryPanel(jaz, s))
+
+	win.AddEHandlerFunc(modelsRefresh, gwu.ETypeWinLoad)
+	win.AddEHandlerFunc(filtersRefresh, gwu.ETypeWinLoad)
+
 	win.AddEHandlerFunc(refresh, gwu.ETypeWinLoad)
 
 	s.AddWin(win)
 
 	jaz.winAdmin = win
 }
+
+// buildCustomPanel shows user-defined items (models, line filters) loaded from directory dir, with a reload button.
+// The returned handler refreshes the panel on window load.
+func buildCustomPanel(s gwu.Session, title, dir string, list func() []string, reload func() error) (gwu.Panel, func(gwu.Event)) {
+	panel := gwu.NewPanel()
+	buttonReload := gwu.NewButton("Reload")
+	msg := gwu.NewLabel("No error")
+	loaded := gwu.NewLabel("")
+	panel.Add(gwu.NewLabel(title))
+	panel.Add(gwu.NewLabel(fmt.Sprintf("Directory: %s", dir)))
+	panel.Add(buttonReload)
+	panel.Add(msg)
+	panel.Add(loaded)
+
+	listItems := func() {
+		items := list()
+		sort.Strings(items)
+		loaded.SetText(fmt.Sprintf("Loaded: %s", strings.Join(items, " ")))
+	}
+
+	listItems() // first run
+
+	buttonReload.SetEnabled(userIsLogged(s))
+
+	buttonReload.AddEHandlerFunc(func(e gwu.Event) {
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to reload
+		}
+
+		defer e.MarkDirty(panel)
+
+		if reloadErr := reload(); reloadErr != nil {
+			msg.SetText(fmt.Sprintf("Reload error: %v", reloadErr))
+		} else {
+			msg.SetText("Reloaded.")
+		}
+
+		listItems()
+
+	}, gwu.ETypeClick)
+
+	refresh := func(e gwu.Event) {
+		buttonReload.SetEnabled(userIsLogged(e.Session()))
+		listItems()
+		e.MarkDirty(panel)
+	}
+
+	return panel, refresh
+}
+
+func buildCompliancePanel(jaz *app, s gwu.Session) gwu.Panel {
+	compliancePanel := gwu.NewPanel()
+	complianceButtonRefresh := gwu.NewButton("Refresh")
+	complianceButtonReload := gwu.NewButton("Reload policies")
+	complianceButtonCheck := gwu.NewButton("Check all")
+	complianceButtonExport := gwu.NewButton("Export")
+	complianceMsg := gwu.NewLabel("No error")
+	complianceSumm := gwu.NewLabel("")
+	complianceLinks := gwu.NewPanel()
+	compliancePanel.Add(gwu.NewLabel("Compliance Policies"))
+	compliancePanel.Add(gwu.NewLabel(fmt.Sprintf("Directory: %s", jaz.policyPath)))
+	compliancePanel.Add(complianceButtonRefresh)
+	compliancePanel.Add(complianceButtonReload)
+	compliancePanel.Add(complianceButtonCheck)
+	compliancePanel.Add(complianceButtonExport)
+	compliancePanel.Add(complianceMsg)
+	compliancePanel.Add(complianceSumm)
+	compliancePanel.Add(complianceLinks)
+
+	summary := func() {
+		reports := jaz.table.ListCompliance()
+		var failed int
+		for _, r := range reports {
+			if !r.Pass {
+				failed++
+			}
+		}
+		complianceSumm.SetText(fmt.Sprintf("Policies: %s - devices checked: %d non-compliant: %d",
+			strings.Join(jaz.table.ListPolicies(), " "), len(reports), failed))
+	}
+
+	summary() // first run
+
+	refresh := func(e gwu.Event) {
+		logged := userIsLogged(e.Session())
+		complianceButtonReload.SetEnabled(logged)
+		complianceButtonExport.SetEnabled(logged)
+
+		defer e.MarkDirty(compliancePanel)
+
+		summary()
+	}
+
+	complianceButtonReload.SetEnabled(userIsLogged(s))
+	complianceButtonExport.SetEnabled(userIsLogged(s))
+
+	complianceButtonRefresh.AddEHandlerFunc(refresh, gwu.ETypeClick)
+
+	complianceButtonReload.AddEHandlerFunc(func(e gwu.Event) {
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to reload
+		}
+
+		defer e.MarkDirty(compliancePanel)
+
+		if reloadErr := loadPolicies(jaz); reloadErr != nil {
+			complianceMsg.SetText(fmt.Sprintf("Reload error: %v", reloadErr))
+		} else {
+			checkCompliance(jaz)
+			complianceMsg.SetText("Reloaded.")
+		}
+
+		summary()
+
+	}, gwu.ETypeClick)
+
+	complianceButtonCheck.AddEHandlerFunc(func(e gwu.Event) {
+		defer e.MarkDirty(compliancePanel)
+		checkCompliance(jaz)
+		complianceMsg.SetText("Checked.")
+		summary()
+	}, gwu.ETypeClick)
+
+	complianceButtonExport.AddEHandlerFunc(func(e gwu.Event) {
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to export
+		}
+
+		defer e.MarkDirty(compliancePanel)
+
+		reports := jaz.table.ListCompliance()
+		id := "compliance-" + time.Now().Format("20060102-150405")
+
+		complianceLinks.Clear()
+		for _, ext := range []string{".csv", ".json"} {
+			name := id + ext
+			if err := exportCompliance(filepath.Join(jaz.runPath, name), reports); err != nil {
+				complianceMsg.SetText(fmt.Sprintf("Export error: %v", err))
+				return
+			}
+			complianceLinks.Add(gwu.NewLink(name, fmt.Sprintf("%s/%s", jaz.runWebPath, name)))
+		}
+		complianceMsg.SetText("Exported.")
+	}, gwu.ETypeClick)
+
+	return compliancePanel
+}
+
+func buildInventoryPanel(jaz *app, s gwu.Session) gwu.Panel {
+	inventoryPanel := gwu.NewPanel()
+	inventoryButtonExport := gwu.NewButton("Export")
+	inventoryMsg := gwu.NewLabel("No error")
+	inventoryLinks := gwu.NewPanel()
+	inventoryPanel.Add(gwu.NewLabel("Device Inventory"))
+	inventoryPanel.Add(inventoryButtonExport)
+	inventoryPanel.Add(inventoryMsg)
+	inventoryPanel.Add(inventoryLinks)
+
+	inventoryButtonExport.SetEnabled(userIsLogged(s))
+
+	inventoryButtonExport.AddEHandlerFunc(func(e gwu.Event) {
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to export
+		}
+
+		defer e.MarkDirty(inventoryPanel)
+
+		facts := jaz.table.ListFacts()
+		id := "inventory-" + time.Now().Format("20060102-150405")
+
+		inventoryLinks.Clear()
+		for _, ext := range []string{".csv", ".json"} {
+			name := id + ext
+			if err := exportInventory(filepath.Join(jaz.runPath, name), facts); err != nil {
+				inventoryMsg.SetText(fmt.Sprintf("Export error: %v", err))
+				return
+			}
+			inventoryLinks.Add(gwu.NewLink(name, fmt.Sprintf("%s/%s", jaz.runWebPath, name)))
+		}
+		inventoryMsg.SetText(fmt.Sprintf("Exported %d devices.", len(facts)))
+	}, gwu.ETypeClick)
+
+	return inventoryPanel
+}
+
+// redactedSecret replaces profile secrets shown to anonymous sessions.
+const redactedSecret = "<redacted>"
+
+// redactProfiles returns copies of profiles with passwords and key passphrase replaced by redactedSecret.
+func redactProfiles(profiles []conf.Profile) []conf.Profile {
+	redacted := make([]conf.Profile, len(profiles))
+	for i, p := range profiles {
+		if p.LoginPassword != "" {
+			p.LoginPassword = redactedSecret
+		}
+		if p.EnablePassword != "" {
+			p.EnablePassword = redactedSecret
+		}
+		if p.SSHKeyPassphrase != "" {
+			p.SSHKeyPassphrase = redactedSecret
+		}
+		redacted[i] = p
+	}
+	return redacted
+}
+
+func buildProfilesPanel(jaz *app, s gwu.Session) gwu.Panel {
+	profilesPanel := gwu.NewPanel()
+	profilesButtonRefresh := gwu.NewButton("Refresh")
+	profilesButtonSave := gwu.NewButton("Save")
+	profilesMsg := gwu.NewLabel("No error")
+	profilesText := gwu.NewTextBox("")
+	profilesText.SetRows(20)
+	profilesText.SetCols(70)
+	profilesPanel.Add(gwu.NewLabel("Device Profiles"))
+	profilesPanel.Add(profilesButtonRefresh)
+	profilesPanel.Add(profilesButtonSave)
+	profilesPanel.Add(profilesMsg)
+	profilesPanel.Add(profilesText)
+
+	bulkPanel := gwu.NewHorizontalPanel()
+	bulkList := gwu.NewListBox(nil)
+	bulkButtonRun := gwu.NewButton("Run all members now")
+	bulkMsg := gwu.NewLabel("")
+	bulkPanel.Add(gwu.NewLabel("Profile:"))
+	bulkPanel.Add(bulkList)
+	bulkPanel.Add(bulkButtonRun)
+	bulkPanel.Add(bulkMsg)
+	profilesPanel.Add(bulkPanel)
+
+	load := func(logged bool) {
+		profiles := jaz.table.ListProfiles()
+
+		shown := profiles
+		if !logged {
+			shown = redactProfiles(profiles) // anonymous sessions must not see credentials
+		}
+
+		list := conf.ProfileList{Profiles: shown}
+		b, dumpErr := list.Dump()
+		if dumpErr != nil {
+			profilesText.SetText(fmt.Sprintf("Could not get profiles: %v", dumpErr))
+		} else {
+			profilesText.SetText(string(b))
+		}
+
+		names := make([]string, len(profiles))
+		for i, p := range profiles {
+			names[i] = p.Name
+		}
+		bulkList.SetValues(names)
+		if len(names) > 0 {
+			bulkList.SetSelected(0, true)
+		}
+	}
+
+	load(userIsLogged(s)) // first run
+
+	refresh := func(e gwu.Event) {
+		logged := userIsLogged(e.Session())
+		profilesButtonSave.SetEnabled(logged)
+		bulkButtonRun.SetEnabled(logged)
+
+		defer e.MarkDirty(profilesPanel)
+
+		load(logged)
+	}
+
+	profilesButtonSave.SetEnabled(userIsLogged(s))
+	bulkButtonRun.SetEnabled(userIsLogged(s))
+
+	profilesButtonRefresh.AddEHandlerFunc(refresh, gwu.ETypeClick)
+
+	profilesButtonSave.AddEHandlerFunc(func(e gwu.Event) {
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to save
+		}
+
+		defer e.MarkDirty(profilesPanel)
+
+		list, parseErr := conf.NewProfileListFromString(profilesText.Text())
+		if parseErr != nil {
+			profilesMsg.SetText(fmt.Sprintf("Parsing error: %v", parseErr))
+			return
+		}
+
+		if setErr := jaz.table.SetProfiles(list.Profiles); setErr != nil {
+			profilesMsg.SetText(fmt.Sprintf("Invalid profiles: %v", setErr))
+			return
+		}
+
+		change := conf.Change{
+			From: eventRemoteAddress(e),
+			By:   sessionUsername(e.Session()),
+			When: time.Now(),
+		}
+
+		saveConfig(jaz, change)
+
+		refresh(e)
+
+		profilesMsg.SetText("Saved.")
+
+	}, gwu.ETypeClick)
+
+	bulkButtonRun.AddEHandlerFunc(func(e gwu.Event) {
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to run
+		}
+
+		defer e.MarkDirty(bulkMsg)
+
+		name := bulkList.SelectedValue()
+		members := jaz.table.ListProfileMembers(name)
+
+		// run in a goroutine to not block the UI on channel write
+		go func() {
+			for _, id := range members {
+				runPriority(jaz, id)
+			}
+		}()
+
+		bulkMsg.SetText(fmt.Sprintf("Profile '%s': %d devices scheduled.", name, len(members)))
+
+	}, gwu.ETypeClick)
+
+	return profilesPanel
+}
+
+func buildRunWin(jaz *app, s gwu.Session) {
+	winName := fmt.Sprintf("%s run command", appName)
+	win := newWin(jaz, "run", winName)
+
+	win.Style().SetFullWidth()
+	win.SetCellPadding(2)
+
+	l := gwu.NewLabel(winName)
+	l.Style().SetFontWeight(gwu.FontWeightBold).SetFontSize("130%")
+	win.Add(l)
+	win.Add(gwu.NewLabel("Outputs are not saved as configuration backups."))
+
+	filterPanel := gwu.NewHorizontalPanel()
+	filterModel := gwu.NewTextBox("")
+	filterID := gwu.NewTextBox("")
+	filterHost := gwu.NewTextBox("")
+	inputCols := 10
+	filterModel.SetCols(inputCols)
+	filterID.SetCols(inputCols)
+	filterHost.SetCols(inputCols)
+	filterPanel.Add(gwu.NewLabel("Model"))
+	filterPanel.Add(filterModel)
+	filterPanel.Add(gwu.NewLabel("Device"))
+	filterPanel.Add(filterID)
+	filterPanel.Add(gwu.NewLabel("Host:Port"))
+	filterPanel.Add(filterHost)
+	win.Add(filterPanel)
+
+	win.Add(gwu.NewLabel("Commands (one per line):"))
+	commandsText := gwu.NewTextBox("")
+	commandsText.SetRows(5)
+	commandsText.SetCols(70)
+	win.Add(commandsText)
+
+	runButton := gwu.NewButton("Run")
+	runMsg := gwu.NewLabel("")
+	exportPanel := gwu.NewHorizontalPanel()
+	resultTab := gwu.NewTable()
+	resultTab.Style().AddClass("device_table")
+	timer := gwu.NewTimer(2 * time.Second)
+	timer.SetRepeat(true)
+	timer.SetActive(false)
+
+	win.Add(runButton)
+	win.Add(runMsg)
+	win.Add(exportPanel)
+	win.Add(resultTab)
+	win.Add(timer)
+
+	var job *commandJob // current job for this session
+
+	showResults := func(e gwu.Event) {
+		defer e.MarkDirty(win)
+
+		if job == nil {
+			return
+		}
+
+		results, done, exported := job.snapshot()
+
+		const COLS = 7
+
+		resultTab.Clear()
+		resultTab.Add(gwu.NewLabel("Device"), 0, 0)
+		resultTab.Add(gwu.NewLabel("Model"), 0, 1)
+		resultTab.Add(gwu.NewLabel("Host:Port"), 0, 2)
+		resultTab.Add(gwu.NewLabel("Status"), 0, 3)
+		resultTab.Add(gwu.NewLabel("Elapsed"), 0, 4)
+		resultTab.Add(gwu.NewLabel("Message"), 0, 5)
+		resultTab.Add(gwu.NewLabel("Output"), 0, 6)
+
+		now := time.Now()
+		var finished int
+
+		for i, r := range results {
+			row := i + 1
+			end := r.End
+			if r.Status == dev.CommandRunning {
+				end = now
+			} else {
+				finished++
+			}
+			out := gwu.NewTextBox(r.Output)
+			out.SetRows(6)
+			out.SetCols(80)
+			out.SetReadOnly(true)
+			resultTab.Add(gwu.NewLabel(r.DevID), row, 0)
+			resultTab.Add(gwu.NewLabel(r.Model), row, 1)
+			resultTab.Add(gwu.NewLabel(r.HostPort), row, 2)
+			resultTab.Add(gwu.NewLabel(r.Status), row, 3)
+			resultTab.Add(gwu.NewLabel(durationSecString(end.Sub(r.Begin))), row, 4)
+			resultTab.Add(gwu.NewLabel(r.Msg), row, 5)
+			resultTab.Add(out, row, 6)
+		}
+
+		for r := 0; r <= len(results); r++ {
+			for j := 0; j < COLS; j++ {
+				resultTab.CellFmt(r, j).Style().AddClass("device_table_cell")
+			}
+		}
+
+		if !done {
+			runMsg.SetText(fmt.Sprintf("Job %s: %d devices finished", job.id, finished))
+			return
+		}
+
+		runMsg.SetText(fmt.Sprintf("Job %s: done: %d devices", job.id, finished))
+		timer.SetActive(false)
+		runButton.SetEnabled(userIsLogged(e.Session()))
+		exportPanel.Clear()
+		for _, name := range exported {
+			exportPanel.Add(gwu.NewLink("Export "+filepath.Ext(name), fmt.Sprintf("%s/%s", jaz.runWebPath, name)))
+		}
+	}
+
+	timer.AddEHandlerFunc(showResults, gwu.ETypeStateChange)
+
+	runButton.AddEHandlerFunc(func(e gwu.Event) {
+		defer e.MarkDirty(win)
+
+		if !userIsLogged(e.Session()) {
+			return // refuse to run
+		}
+
+		var commands []string
+		for _, c := range strings.Split(commandsText.Text(), "\n") {
+			if c = strings.TrimSpace(c); c != "" {
+				commands = append(commands, c)
+			}
+		}
+		if len(commands) < 1 {
+			runMsg.SetText("No command.")
+			return
+		}
+
+		devices := dev.FilterDevices(jaz.table.ListDevices(), filterModel.Text(), filterID.Text(), filterHost.Text())
+		if len(devices) < 1 {
+			runMsg.SetText("No device matched filters.")
+			return
+		}
+
+		job = newCommandJob(commands)
+		exportPanel.Clear()
+		runButton.SetEnabled(false)
+		go job.run(jaz, devices)
+
+		timer.SetActive(true)
+		showResults(e)
+	}, gwu.ETypeClick)
+
+	win.AddEHandlerFunc(func(e gwu.Event) {
+		runButton.SetEnabled(userIsLogged(e.Session()) && (job == nil || !timer.Active()))
+		e.MarkDirty(runButton)
+	}, gwu.ETypeWinLoad)
+
+	s.AddWin(win)
+}
@@ -331,8 +331,18 @@ func writeFile(path string, writeFunc func(HasWrite) error, contentType string)
 	return nil
 }
 
+// NormalizeFunc rewrites file content before comparison, usually removing volatile lines.
+type NormalizeFunc func([]byte) []byte
+
 // SaveNewConfig saves data to a new file. The function writeFunc must be provided to issue the actual data.
 func SaveNewConfig(configPathPrefix string, maxFiles int, logger hasPrintf, writeFunc func(HasWrite) error, changesOnly bool, contentType string) (string, error) {
+	return SaveNewConfigNormalized(configPathPrefix, maxFiles, logger, writeFunc, changesOnly, contentType, nil, 0)
+}
+
+// SaveNewConfigNormalized is like SaveNewConfig, but with changesOnly it compares normalized contents.
+// The new file still holds the full content issued by writeFunc.
+// maxSize limits the amount of data loaded from each file for normalized comparison.
+func SaveNewConfigNormalized(configPathPrefix string, maxFiles int, logger hasPrintf, writeFunc func(HasWrite) error, changesOnly bool, contentType string, normalize NormalizeFunc, maxSize int64) (string, error) {
 
 	// get tmp file
 
@@ -365,7 +375,7 @@ func SaveNewConfig(configPathPrefix string, maxFiles int, logger hasPrintf, writ
 	}
 
 	if changesOnly && previousFound {
-		equal, equalErr := fileCompare(lastConfig, tmpPath)
+		equal, equalErr := fileCompareNormalized(lastConfig, tmpPath, normalize, maxSize)
 		if equalErr == nil {
 			if equal {
 				logger.Printf("SaveNewConfig: refusing to create identical new file: [%s]", tmpPath)
@@ -419,6 +429,40 @@ func SaveNewConfig(configPathPrefix string, maxFiles int, logger hasPrintf, writ
 	return newFilepath, nil
 }
 
+// SaveConfigCopy saves data to the file for a given commit ID, then erases old files beyond maxFiles.
+// It keeps copies numbered just like the files created by SaveNewConfig.
+// Local directories and files are created accessible only by the owner.
+func SaveConfigCopy(configPathPrefix string, id, maxFiles int, logger hasPrintf, writeFunc func(HasWrite) error, contentType string) (string, error) {
+
+	path := getConfigPath(configPathPrefix, strconv.Itoa(id))
+
+	if !s3path(path) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", fmt.Errorf("SaveConfigCopy: mkdir: %v", err)
+		}
+	}
+
+	if fileExists(path) {
+		if err := fileRemove(path); err != nil {
+			return "", fmt.Errorf("SaveConfigCopy: could not replace '%s': %v", path, err)
+		}
+	}
+
+	if err := writeFile(path, writeFunc, contentType); err != nil {
+		return "", fmt.Errorf("SaveConfigCopy: %v", err)
+	}
+
+	if !s3path(path) {
+		if err := os.Chmod(path, 0600); err != nil {
+			return "", fmt.Errorf("SaveConfigCopy: chmod: %v", err)
+		}
+	}
+
+	eraseOldFiles(configPathPrefix, maxFiles, logger)
+
+	return path, nil
+}
+
 func eraseOldFiles(configPathPrefix string, maxFiles int, logger hasPrintf) {
 
 	if maxFiles < 1 {
@@ -474,6 +518,24 @@ func fileCompare(p1, p2 string) (bool, error) {
 	return cmp.CompareFile(p1, p2)
 }
 
+func fileCompareNormalized(p1, p2 string, normalize NormalizeFunc, maxSize int64) (bool, error) {
+	if normalize == nil {
+		return fileCompare(p1, p2)
+	}
+
+	b1, err1 := FileRead(p1, maxSize)
+	if err1 != nil {
+		return false, err1
+	}
+
+	b2, err2 := FileRead(p2, maxSize)
+	if err2 != nil {
+		return false, err2
+	}
+
+	return bytes.Equal(normalize(b1), normalize(b2)), nil
+}
+
 // MkDir creates a new directory.
 func MkDir(path string) error {
 
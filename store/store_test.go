@@ -108,3 +108,33 @@ func storeWrite(t *testing.T, prefix, content, expected string, maxFiles int, lo
 
 	return nil
 }
+
+func TestSaveNormalizedMaxSize(t *testing.T) {
+
+	repo := temp.MakeTempRepo()
+	defer temp.CleanupTempRepo()
+
+	logger := &testLogger{t}
+	prefix := filepath.Join(repo, "normalized-test.")
+	config := []byte("hostname lab1\nend")
+
+	writeFunc := func(w HasWrite) error {
+		_, err := w.Write(config)
+		return err
+	}
+	noop := func(b []byte) []byte { return b }
+
+	save := func(maxSize int64, expected string) {
+		path, err := SaveNewConfigNormalized(prefix, 10, logger, writeFunc, true, "", noop, maxSize)
+		if err != nil {
+			t.Fatalf("TestSaveNormalizedMaxSize: maxSize=%d: %v", maxSize, err)
+		}
+		if path != expected {
+			t.Errorf("TestSaveNormalizedMaxSize: maxSize=%d: got=%s wanted=%s", maxSize, path, expected)
+		}
+	}
+
+	save(1000, prefix+"0")
+	save(1000, prefix+"0") // identical
+	save(4, prefix+"1")    // unable to compare beyond maxSize: save anyway
+}